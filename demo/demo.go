@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -16,13 +17,13 @@ func main() {
     "部门": "技术部",
 }
 
-step1 = beiluo.test1.devops.devops_script_create(string 用户名, int 手机号, string 邮箱) -> (string 工号, string 用户名, err 是否失败);
-step2 = beiluo.test1.crm.crm_interview_schedule(string 用户名, string 部门) -> (string 面试时间, string 面试官名称, err 是否失败);
-step3 = beiluo.test1.notification.send_email(string 邮箱, string 内容) -> (err 是否失败);
+step1 = beiluo.test1.devops.devops_script_create(username: string "用户名", phone: int "手机号", email: string "邮箱") -> (workId: string "工号", username: string "用户名", err: error "是否失败");
+step2 = beiluo.test1.crm.crm_interview_schedule(username: string "用户名", department: string "部门") -> (interviewTime: string "面试时间", interviewer: string "面试官名称", err: error "是否失败");
+step3 = beiluo.test1.notification.send_email(email: string "邮箱", content: string "内容") -> (err: error "是否失败");
 
 func main() {
     fmt.Println("🚀 开始用户注册和面试安排流程...")
-    
+
     // 创建用户
     工号, 用户名, step1Err := step1(input["用户名"], input["手机号"], input["邮箱"]){retry:3, timeout:5000, priority:"high"}
     if step1Err != nil {
@@ -30,7 +31,7 @@ func main() {
         return
     }
     fmt.Printf("✅ 用户创建成功，工号: %s\n", 工号)
-    
+
     // 安排面试
     面试时间, 面试官名称, step2Err := step2(用户名, input["部门"]){retry:2, timeout:3000, priority:"normal"}
     if step2Err != nil {
@@ -38,7 +39,7 @@ func main() {
         return
     }
     fmt.Printf("✅ 面试安排成功，时间: %s，面试官: %s\n", 面试时间, 面试官名称)
-    
+
     // 发送通知邮件
     通知内容 := "你收到了:{{用户名}},时间：{{面试时间}}的面试安排，请关注"
     step3Err := step3(input["邮箱"], 通知内容){retry:1, timeout:2000, priority:"low"}
@@ -47,20 +48,50 @@ func main() {
     } else {
         fmt.Printf("✅ 邮件发送成功\n")
     }
-    
+
     fmt.Printf("🎉 流程完成！工号: %s，面试时间: %s\n", 工号, 面试时间)
 }`
 
+	// 解析工作流
+	result := workflow.NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		fmt.Printf("❌ 解析失败: %s\n", result.Error)
+		return
+	}
+
+	// 注册步骤函数，参数和返回值按步骤定义的顺序对应
+	functions := &workflow.ReflectionFunctionExecutor{Functions: map[string]interface{}{
+		"beiluo.test1.devops.devops_script_create": func(username string, phone int, email string) (string, string, error) {
+			return "EMP001", username, nil
+		},
+		"beiluo.test1.crm.crm_interview_schedule": func(username, department string) (string, string, error) {
+			return "2024-01-15 14:00", "李四", nil
+		},
+		"beiluo.test1.notification.send_email": func(email, content string) error {
+			return nil
+		},
+	}}
+	registry := workflow.NewFunctionRegistry()
+	for name := range functions.Functions {
+		registry.Register(name, functions)
+	}
+
 	// 创建执行引擎
-	executor := workflow.NewWorkflowExecutor()
+	executor := workflow.NewExecutor()
+	executor.OnFunctionCall = registry.OnFunctionCall
 
 	// 执行工作流
 	fmt.Println(strings.Repeat("=", 60))
 	fmt.Println("AI工作流编排语言 - 执行引擎演示")
 	fmt.Println(strings.Repeat("=", 60))
 
-	result := executor.ExecuteWorkflow(code)
+	if err := executor.Start(context.Background(), result); err != nil {
+		fmt.Printf("❌ 执行失败: %v\n", err)
+	}
 
 	// 打印执行结果
-	result.Print()
+	fmt.Printf("流程状态: %s\n", result.Status)
+	for _, log := range result.GlobalLogs {
+		fmt.Printf("[%s] %s\n", log.Level, log.Message)
+	}
 }
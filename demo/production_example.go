@@ -1,97 +1,67 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/yunhanshu-net/pkg/workflow"
 )
 
 func main() {
-	// 创建类型回调执行器
-	callbackExecutor := workflow.NewTypeCallbackExecutor()
+	// 创建执行引擎
+	executor := workflow.NewExecutor()
 
-	// 注册 function-call 类型回调 - 处理HTTP接口调用
-	callbackExecutor.RegisterType("function-call", func(ctx *workflow.StatementContext) *workflow.StatementCallbackResult {
-		fmt.Printf("🌐 [HTTP调用] %s - 参数: %v\n", ctx.Function, ctx.Args)
+	// 步骤回调 - 处理HTTP接口调用
+	executor.OnFunctionCall = func(ctx context.Context, step workflow.SimpleStep, in *workflow.ExecutorIn) (*workflow.ExecutorOut, error) {
+		fmt.Printf("🌐 [HTTP调用] %s - 参数: %v\n", step.Function, in.RealInput)
 
 		// 1. 查询数据库获取步骤配置
-		stepConfig, err := queryStepConfig(ctx.Function)
+		stepConfig, err := queryStepConfig(step.Name)
 		if err != nil {
-			return &workflow.StatementCallbackResult{
-				Success: false,
-				Error:   fmt.Errorf("查询步骤配置失败: %v", err),
-			}
+			return nil, fmt.Errorf("查询步骤配置失败: %v", err)
 		}
 
 		// 2. 准备HTTP请求参数
 		requestData := map[string]interface{}{
-			"function": ctx.Function,
-			"args":     ctx.Args,
-			"metadata": ctx.Metadata,
+			"function": step.Function,
+			"args":     in.RealInput,
+			"options":  in.Options,
 		}
 
 		// 3. 调用HTTP接口
-		response, err := callHTTPAPI(stepConfig.URL, requestData)
+		response, err := callHTTPAPI(ctx, stepConfig.URL, requestData)
 		if err != nil {
-			return &workflow.StatementCallbackResult{
-				Success: false,
-				Error:   fmt.Errorf("HTTP调用失败: %v", err),
-			}
+			return &workflow.ExecutorOut{Success: false, Error: fmt.Sprintf("HTTP调用失败: %v", err)}, nil
+		}
+		if response.Error != nil {
+			return &workflow.ExecutorOut{Success: false, Error: response.Error.Error()}, nil
 		}
 
-		// 4. 解析响应并返回结果
-		outputArgs := make([]interface{}, len(ctx.Returns))
-		for i := range ctx.Returns {
+		// 4. 解析响应，返回值按位置对应步骤定义的输出参数
+		output := make(map[string]interface{}, len(in.WantParams))
+		for i, param := range in.WantParams {
 			if i < len(response.OutputArgs) {
-				outputArgs[i] = response.OutputArgs[i]
+				output[param.Name] = response.OutputArgs[i]
 			} else {
-				outputArgs[i] = nil
+				output[param.Name] = nil
 			}
 		}
+		return &workflow.ExecutorOut{Success: response.Success, WantOutput: output}, nil
+	}
 
-		return &workflow.StatementCallbackResult{
-			Success:    response.Success,
-			Error:      response.Error,
-			OutputArgs: outputArgs,
-			Duration:   time.Since(ctx.StartTime).Milliseconds(),
-		}
-	})
-
-	// 注册 if 类型回调 - 处理条件判断
-	callbackExecutor.RegisterType("if", func(ctx *workflow.StatementContext) *workflow.StatementCallbackResult {
-		fmt.Printf("🔀 [条件判断] %s\n", ctx.Condition)
-
-		// 根据条件判断是否跳过执行
-		shouldSkip := evaluateCondition(ctx.Condition, ctx.Variables, ctx.InputVars)
-
-		return &workflow.StatementCallbackResult{
-			Success:    true,
-			Error:      nil,
-			ShouldSkip: shouldSkip,
-			Duration:   time.Since(ctx.StartTime).Milliseconds(),
-		}
-	})
-
-	// 注册 var 类型回调 - 处理变量赋值
-	callbackExecutor.RegisterType("var", func(ctx *workflow.StatementContext) *workflow.StatementCallbackResult {
-		fmt.Printf("📝 [变量赋值] %s\n", ctx.Content)
-
-		// 处理变量赋值逻辑
-		// 这里可以添加变量验证、类型转换等逻辑
-
-		return &workflow.StatementCallbackResult{
-			Success:  true,
-			Error:    nil,
-			Duration: time.Since(ctx.StartTime).Milliseconds(),
+	// 条件判断和变量赋值由执行引擎处理，通过执行事件观察
+	executor.Subscribe(func(ctx context.Context, event workflow.Event) {
+		switch {
+		case event.Type == workflow.EventStatementStarted && event.StatementType == "if":
+			fmt.Printf("🔀 [条件判断] %s\n", event.Content)
+		case event.Type == workflow.EventVariableSet:
+			// 这里可以添加变量审计、脱敏等逻辑
+			fmt.Printf("📝 [变量赋值] %s = %v\n", event.Variable.Name, event.Variable.Value)
 		}
 	})
 
-	// 创建执行引擎
-	executor := workflow.NewWorkflowExecutorWithCallback(callbackExecutor)
-
 	// 工作流代码
 	code := `var input = map[string]interface{}{
     "用户名": "张三",
@@ -100,9 +70,9 @@ func main() {
     "部门": "技术部",
 }
 
-step1 = beiluo.test1.devops.devops_script_create(string 用户名, int 手机号, string 邮箱) -> (string 工号, string 用户名, err 是否失败);
-step2 = beiluo.test1.crm.crm_interview_schedule(string 用户名, string 部门) -> (string 面试时间, string 面试官名称, err 是否失败);
-step3 = beiluo.test1.notification.send_email(string 邮箱, string 内容) -> (err 是否失败);
+step1 = beiluo.test1.devops.devops_script_create(username: string "用户名", phone: int "手机号", email: string "邮箱") -> (workId: string "工号", username: string "用户名", err: error "是否失败");
+step2 = beiluo.test1.crm.crm_interview_schedule(username: string "用户名", department: string "部门") -> (interviewTime: string "面试时间", interviewer: string "面试官名称", err: error "是否失败");
+step3 = beiluo.test1.notification.send_email(email: string "邮箱", content: string "内容") -> (err: error "是否失败");
 
 func main() {
     fmt.Println("🚀 开始用户注册和面试安排流程...")
@@ -140,10 +110,20 @@ func main() {
 	fmt.Println("AI工作流编排语言 - 生产环境演示")
 	fmt.Println("============================================================")
 
-	result := executor.ExecuteWorkflow(code)
+	result := workflow.NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		fmt.Printf("❌ 解析失败: %s\n", result.Error)
+		return
+	}
+	if err := executor.Start(context.Background(), result); err != nil {
+		fmt.Printf("❌ 执行失败: %v\n", err)
+	}
 
 	// 打印执行结果
-	result.Print()
+	fmt.Printf("流程状态: %s\n", result.Status)
+	for _, log := range result.GlobalLogs {
+		fmt.Printf("[%s] %s\n", log.Level, log.Message)
+	}
 }
 
 // 步骤配置
@@ -194,53 +174,31 @@ type APIResponse struct {
 }
 
 // 调用HTTP接口
-func callHTTPAPI(url string, data map[string]interface{}) (*APIResponse, error) {
+func callHTTPAPI(ctx context.Context, url string, data map[string]interface{}) (*APIResponse, error) {
 	// 模拟HTTP调用
 	jsonData, _ := json.Marshal(data)
 
 	// 这里应该是真实的HTTP调用
-	// resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	// req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
 
 	fmt.Printf("   📡 调用接口: %s\n", url)
 	fmt.Printf("   📤 请求数据: %s\n", string(jsonData))
 
-	// 模拟响应
-	time.Sleep(100 * time.Millisecond)
-
-	return &APIResponse{
-		Success: true,
-		Error:   nil,
-		OutputArgs: []interface{}{
-			"真实工号结果",
-			"真实用户名结果",
-			nil,
-		},
-	}, nil
-}
-
-// 评估条件
-func evaluateCondition(condition string, variables map[string]interface{}, inputVars map[string]interface{}) bool {
-	// 简单的条件评估逻辑
-	if condition == "" {
-		return false
+	// 模拟响应，步骤超时或流程取消时立即返回
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 
-	// 检查错误条件
-	if strings.Contains(condition, "Err != nil") {
-		// 提取错误变量名
-		parts := strings.Split(condition, "Err != nil")
-		if len(parts) > 0 {
-			errVarName := strings.TrimSpace(parts[0])
-			if errVarName == "" {
-				errVarName = "step1Err" // 默认
-			}
-
-			// 从变量中查找错误值
-			if err, exists := variables[errVarName]; exists {
-				return err != nil
-			}
-		}
+	responses := map[string][]interface{}{
+		"http://api.example.com/devops/create-user":      {"真实工号结果", "真实用户名结果", nil},
+		"http://api.example.com/crm/schedule-interview":  {"真实面试时间结果", "真实面试官结果", nil},
+		"http://api.example.com/notification/send-email": {nil},
 	}
-
-	return false
+	return &APIResponse{
+		Success:    true,
+		Error:      nil,
+		OutputArgs: responses[url],
+	}, nil
 }
@@ -13,3 +13,16 @@ type RunFunctionReq struct {
 	Body     interface{}           `json:"body"`
 	UrlQuery string                `json:"url_query"`
 }
+
+// RunFunctionResp runner 执行函数后的回复，Code 为0表示成功
+type RunFunctionResp struct {
+	MetaData   map[string]interface{} `json:"meta_data"`
+	Headers    map[string]string      `json:"headers"`
+	Code       int                    `json:"code"`
+	Msg        string                 `json:"msg"`
+	TraceID    string                 `json:"trace_id"`
+	RenderType string                 `json:"render_type"`
+	Data       interface{}            `json:"data"`
+	DataList   []interface{}          `json:"data_list"`
+	Multiple   bool                   `json:"multiple"`
+}
@@ -0,0 +1,1048 @@
+package workflow
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ParseError 解析错误，行号和列号从1开始，列号按字符计算
+type ParseError struct {
+	Line   int    `json:"line"`   // 行号
+	Column int    `json:"column"` // 列号
+	Msg    string `json:"msg"`    // 错误描述
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("第%d行第%d列: %s", e.Line, e.Column, e.Msg)
+}
+
+// main 函数交给 go/parser 解析时补在前面的包声明
+const goSourcePrefix = "package workflow\n"
+
+// dslToken 词法单元，off/end 为在源码中的字节偏移
+type dslToken struct {
+	tok token.Token
+	lit string
+	off int
+	end int
+}
+
+// goToken 交给 go/parser 的词法单元，at/off 为在 go/parser 源码和原始源码中的偏移
+type goToken struct {
+	at  int
+	off int
+}
+
+// metadataBlock 步骤调用后的元数据 {retry: 1}，交给 go/parser 时跳过
+type metadataBlock struct {
+	text string
+	end  int
+}
+
+// dslParser 一次解析的状态
+//
+// 解析分两步：先用 go/scanner 对整个源码分词，在顶层识别工作流指令、input、步骤声明和 main 函数；
+// 再把 main 函数去掉注释和调用后的元数据的词法单元交给 go/parser 解析，
+// 最后把 Go 语法树转换为 SimpleStatement。
+type dslParser struct {
+	p      *SimpleParser
+	code   string
+	starts []int // 每行开头的字节偏移
+	toks   []dslToken
+	result *SimpleParseResult
+	errors []ParseError
+
+	mainStart, mainEnd int // main 函数在源码中的范围，没有 main 函数时为 -1
+	scanErrors         scanner.ErrorList
+	goFile             *token.File
+	goTokens           []goToken             // 交给 go/parser 的 main 函数中的词法单元
+	metadata           map[int]metadataBlock // 调用的右括号偏移 -> 元数据
+	topLevel           []topLevelItem        // 按源码顺序的顶层注释、声明和 main 函数，供 Format 使用
+}
+
+// 顶层项的类型
+const (
+	topLevelComment = "comment" // 注释
+	topLevelDecl    = "decl"    // input 或步骤声明
+	topLevelGroup   = "group"   // var ( ... )，items 为其中的注释和声明
+	topLevelMain    = "main"    // main 函数
+)
+
+// topLevelItem 顶层的一项，from/to 为词法单元范围 [from, to)
+type topLevelItem struct {
+	kind     string
+	from, to int
+	items    []topLevelItem
+}
+
+// 解析工作流
+func (p *SimpleParser) ParseWorkflow(code string) *SimpleParseResult {
+	result := &SimpleParseResult{
+		FlowID:     generateFlowID(),
+		Success:    true,
+		InputVars:  make(map[string]interface{}),
+		Steps:      []*SimpleStep{},
+		MainFunc:   &SimpleMainFunc{Statements: []*SimpleStatement{}},
+		Variables:  make(map[string]VariableInfo),
+		GlobalLogs: make([]*StepLog, 0),
+
+		variablesMu: &sync.RWMutex{},
+	}
+
+	// 检查空代码
+	code = strings.TrimSpace(code)
+	if code == "" {
+		result.Success = false
+		result.Error = "代码为空"
+		return result
+	}
+
+	d := newDSLParser(p, code, result)
+	d.tokenize()
+	d.parseTopLevel()
+	for _, err := range d.scanErrors {
+		// main 函数内的词法错误由 go/parser 报告
+		if d.mainStart < 0 || err.Pos.Offset < d.mainStart || err.Pos.Offset >= d.mainEnd {
+			d.errorf(err.Pos.Offset, "%s", err.Msg)
+		}
+	}
+	if d.mainStart >= 0 {
+		d.parseMain()
+	}
+
+	if len(d.errors) > 0 {
+		sort.SliceStable(d.errors, func(i, j int) bool {
+			if d.errors[i].Line != d.errors[j].Line {
+				return d.errors[i].Line < d.errors[j].Line
+			}
+			return d.errors[i].Column < d.errors[j].Column
+		})
+		messages := make([]string, len(d.errors))
+		for i, err := range d.errors {
+			messages[i] = err.Error()
+		}
+		result.Errors = d.errors
+		result.Success = false
+		result.Error = strings.Join(messages, "; ")
+		return result
+	}
+
+	// 检查是否有main函数
+	if len(result.MainFunc.Statements) == 0 {
+		result.Success = false
+		result.Error = "缺少main函数"
+		return result
+	}
+
+	return result
+}
+
+// newDSLParser 创建解析状态，code 为去掉首尾空白的源码
+func newDSLParser(p *SimpleParser, code string, result *SimpleParseResult) *dslParser {
+	d := &dslParser{
+		p:         p,
+		code:      code,
+		result:    result,
+		mainStart: -1,
+		mainEnd:   -1,
+		metadata:  make(map[int]metadataBlock),
+	}
+	d.starts = append(d.starts, 0)
+	for i := 0; i < len(code); i++ {
+		if code[i] == '\n' {
+			d.starts = append(d.starts, i+1)
+		}
+	}
+	return d
+}
+
+// position 把源码偏移换算为行号和按字符计算的列号
+func (d *dslParser) position(off int) (int, int) {
+	line := sort.Search(len(d.starts), func(i int) bool { return d.starts[i] > off })
+	start := d.starts[line-1]
+	return line, columnOf(d.code[start:], off-start)
+}
+
+func (d *dslParser) errorf(off int, format string, args ...interface{}) {
+	line, column := d.position(off)
+	msg := fmt.Sprintf(format, args...)
+	for _, err := range d.errors {
+		if err.Line == line && err.Column == column && err.Msg == msg {
+			return
+		}
+	}
+	d.errors = append(d.errors, ParseError{Line: line, Column: column, Msg: msg})
+}
+
+// lineText 返回第 line 行的源码，不含换行符
+func (d *dslParser) lineText(line int) string {
+	end := len(d.code)
+	if line < len(d.starts) {
+		end = d.starts[line] - 1
+	}
+	return d.code[d.starts[line-1]:end]
+}
+
+// directive 返回第 line 行之前紧邻的注释中形如 //key: value 的指令，中间可以有空行和其他注释
+func (d *dslParser) directive(line int, key string) string {
+	for l := line - 1; l >= 1; l-- {
+		text := strings.TrimSpace(d.lineText(l))
+		if text == "" {
+			continue
+		}
+		if !strings.HasPrefix(text, "//") {
+			break
+		}
+		if value, ok := strings.CutPrefix(text[2:], key); ok && strings.HasPrefix(value, ":") {
+			return strings.TrimSpace(value[1:])
+		}
+	}
+	return ""
+}
+
+// tokenize 对整个源码分词，main 函数之外的词法错误在这里报告
+func (d *dslParser) tokenize() {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(d.code))
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	s.Init(file, []byte(d.code), func(pos token.Position, msg string) { errs.Add(pos, msg) }, scanner.ScanComments)
+
+	// 按平均每个词法单元6字节预估容量，避免反复扩容
+	d.toks = make([]dslToken, 0, len(d.code)/6)
+
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		off := file.Offset(pos)
+		d.toks = append(d.toks, dslToken{tok: tok, lit: lit, off: off, end: d.tokenEnd(tok, lit, off)})
+	}
+	d.scanErrors = errs
+}
+
+// tokenEnd 返回词法单元在源码中的结束偏移
+//
+// 原始字符串和注释中的 \r 会被 go/scanner 去掉，非法字符的 lit 可能是替换字符，这些情况按源码重新计算。
+func (d *dslParser) tokenEnd(tok token.Token, lit string, off int) int {
+	rest := d.code[off:]
+	switch {
+	case tok == token.SEMICOLON && lit == "\n":
+		return off
+	case tok == token.ILLEGAL:
+		_, size := utf8.DecodeRuneInString(rest)
+		return off + size
+	case lit == "":
+		return off + len(tok.String())
+	case strings.HasPrefix(rest, "`"):
+		if i := strings.IndexByte(rest[1:], '`'); i >= 0 {
+			return off + i + 2
+		}
+		return len(d.code)
+	case strings.HasPrefix(rest, "/*"):
+		if i := strings.Index(rest, "*/"); i >= 0 {
+			return off + i + 2
+		}
+		return len(d.code)
+	case strings.HasPrefix(rest, "//"):
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			return off + i
+		}
+		return len(d.code)
+	}
+	return min(off+len(lit), len(d.code))
+}
+
+// statementEnd 返回从 i 开始的声明的结束位置（分号或末尾），括号内的换行不结束声明
+//
+// 括号未闭合时，遇到下一个顶层声明（func、var 或换行后的 name =）也结束，避免一个错误吞掉后面的声明。
+func (d *dslParser) statementEnd(i int) int {
+	depth := 0
+	for start := i; i < len(d.toks); i++ {
+		if depth > 0 && i > start && d.declarationStart(i) {
+			return i
+		}
+		switch d.toks[i].tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+			if depth < 0 {
+				return i
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// funcEnd 返回从 i 开始的函数之后的位置，只按大括号匹配函数体，函数体内括号不匹配不影响函数范围
+func (d *dslParser) funcEnd(i int) int {
+	depth := 0
+	for j := i; j < len(d.toks); j++ {
+		switch d.toks[j].tok {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(d.toks)
+}
+
+// next 返回 statementEnd 之后下一个声明的位置
+func (d *dslParser) next(end int) int {
+	if end < len(d.toks) && (d.toks[end].tok == token.FUNC || d.toks[end].tok == token.VAR) {
+		return end
+	}
+	return end + 1
+}
+
+// declarationStart 判断 i 处是否为新的顶层声明的开始
+func (d *dslParser) declarationStart(i int) bool {
+	switch d.toks[i].tok {
+	case token.FUNC, token.VAR:
+		return true
+	case token.SEMICOLON:
+		return d.toks[i].lit == "\n" && i+2 < len(d.toks) &&
+			d.toks[i+1].tok == token.IDENT && d.toks[i+2].tok == token.ASSIGN
+	}
+	return false
+}
+
+// matching 返回与 i 处的左括号匹配的右括号位置
+func (d *dslParser) matching(i int) int {
+	depth := 0
+	for j := i; j < len(d.toks); j++ {
+		switch d.toks[j].tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// tokenText 返回 [i, j) 的源码，多行合并为一行
+func (d *dslParser) tokenText(i, j int) string {
+	if i >= j {
+		return ""
+	}
+	text := d.code[d.toks[i].off:d.toks[j-1].end]
+	if !strings.Contains(text, "\n") {
+		return text
+	}
+	parts := strings.Split(text, "\n")
+	for k := range parts {
+		parts[k] = strings.TrimSpace(parts[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseTopLevel 识别工作流指令、input、步骤声明和 main 函数
+func (d *dslParser) parseTopLevel() {
+	// 工作流级别配置：//max_parallel: 4、//workflow_timeout: 5000
+	for _, t := range d.toks {
+		if t.tok != token.COMMENT {
+			continue
+		}
+		if value, ok := strings.CutPrefix(t.lit, "//max_parallel:"); ok {
+			if maxParallel, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				d.result.MaxParallel = maxParallel
+			}
+		}
+		if value, ok := strings.CutPrefix(t.lit, "//workflow_timeout:"); ok {
+			if timeoutMs, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				d.result.Timeout = time.Duration(timeoutMs) * time.Millisecond
+			}
+		}
+	}
+
+	var mainAt = -1
+	for i := 0; i < len(d.toks); {
+		t := d.toks[i]
+		switch {
+		case t.tok == token.COMMENT:
+			d.topLevel = append(d.topLevel, topLevelItem{kind: topLevelComment, from: i, to: i + 1})
+			i++
+		case t.tok == token.SEMICOLON:
+			i++
+		case t.tok == token.VAR && i+1 < len(d.toks) && d.toks[i+1].tok == token.LPAREN:
+			// var ( step1 = ...; step2 = ... )
+			end := d.matching(i + 1)
+			if end < 0 {
+				d.errorf(t.off, "var 声明缺少 )")
+				end = len(d.toks)
+			}
+			group := topLevelItem{kind: topLevelGroup, from: i, to: min(end+1, len(d.toks))}
+			for j := i + 2; j < end; {
+				if d.toks[j].tok == token.COMMENT {
+					group.items = append(group.items, topLevelItem{kind: topLevelComment, from: j, to: j + 1})
+				}
+				if d.toks[j].tok == token.COMMENT || d.toks[j].tok == token.SEMICOLON {
+					j++
+					continue
+				}
+				stmtEnd := d.statementEnd(j)
+				if stmtEnd > end {
+					stmtEnd = end
+				}
+				d.parseDeclaration(j, stmtEnd)
+				group.items = append(group.items, topLevelItem{kind: topLevelDecl, from: j, to: stmtEnd})
+				j = stmtEnd + 1
+			}
+			d.topLevel = append(d.topLevel, group)
+			i = end + 1
+		case t.tok == token.VAR:
+			end := d.statementEnd(i)
+			d.parseDeclaration(i+1, end)
+			d.topLevel = append(d.topLevel, topLevelItem{kind: topLevelDecl, from: i + 1, to: end})
+			i = d.next(end)
+		case t.tok == token.FUNC:
+			end := d.funcEnd(i)
+			if i+1 < len(d.toks) && d.toks[i+1].lit == "main" {
+				if mainAt >= 0 {
+					d.errorf(t.off, "main 函数重复定义")
+				}
+				mainAt = i
+				d.mainStart, d.mainEnd = t.off, d.toks[end-1].end
+				d.topLevel = append(d.topLevel, topLevelItem{kind: topLevelMain, from: i, to: end})
+			} else {
+				d.errorf(t.off, "只支持 main 函数")
+			}
+			i = end
+		case t.tok == token.PACKAGE || t.tok == token.IMPORT:
+			// 兼容完整的Go文件写法
+			i = d.next(d.statementEnd(i))
+		default:
+			end := d.statementEnd(i)
+			d.parseDeclaration(i, end)
+			d.topLevel = append(d.topLevel, topLevelItem{kind: topLevelDecl, from: i, to: end})
+			i = d.next(end)
+		}
+	}
+}
+
+// parseDeclaration 解析 [i, end) 的顶层声明：input 或步骤定义
+func (d *dslParser) parseDeclaration(i, end int) {
+	if i >= end {
+		return
+	}
+	t := d.toks[i]
+	if t.tok != token.IDENT || i+1 >= end || d.toks[i+1].tok != token.ASSIGN {
+		d.errorf(t.off, "无法识别的声明: %s", d.tokenText(i, end))
+		return
+	}
+
+	if t.lit == "input" {
+		d.parseInput(i+2, end)
+		return
+	}
+
+	step, off, err := d.parseStep(i, end)
+	if err != nil {
+		d.errorf(off, "%v", err)
+		return
+	}
+	d.result.Steps = append(d.result.Steps, step)
+}
+
+// parseStep 解析 [i, end) 的步骤定义：名称 = 函数(参数) -> (返回值) {元数据}，函数[用例ID] 为静态工作流
+//
+// 出错时返回错误所在的源码偏移。参数描述等字符串中的 = 和 -> 不影响解析。
+func (d *dslParser) parseStep(i, end int) (*SimpleStep, int, error) {
+	name := d.toks[i]
+	line, _ := d.position(name.off)
+	step := &SimpleStep{Name: name.lit, LineNumber: line, Logs: make([]*StepLog, 0)}
+
+	// 函数名：beiluo.test1.crm.create 或 workflow.名称.版本
+	j := i + 2
+	for j < end && d.functionToken(d.toks[j].tok) {
+		j++
+	}
+	if j == i+2 {
+		return nil, d.offsetAt(j, end), fmt.Errorf("步骤定义缺少函数名")
+	}
+	step.Function = d.code[d.toks[i+2].off:d.toks[j-1].end]
+	if d.toks[i+2].tok == token.PERIOD || d.toks[j-1].tok == token.PERIOD {
+		return nil, d.toks[i+2].off, fmt.Errorf("步骤定义的函数名格式错误: %s", step.Function)
+	}
+	ref, err := parseSubWorkflowRef(step.Function)
+	if err != nil {
+		return nil, d.toks[i+2].off, err
+	}
+	step.SubWorkflow = ref
+
+	// 参数 (name: type "描述", ...) 或静态工作流的 [用例ID]
+	if j >= end || (d.toks[j].tok != token.LPAREN && d.toks[j].tok != token.LBRACK) {
+		return nil, d.offsetAt(j, end), fmt.Errorf("步骤定义缺少参数列表")
+	}
+	closing := d.matching(j)
+	if closing < 0 || closing >= end {
+		return nil, d.toks[j].off, fmt.Errorf("步骤定义括号不匹配")
+	}
+	if d.toks[j].tok == token.LBRACK {
+		step.IsStatic = true
+		step.CaseID = strings.TrimSpace(d.tokenText(j+1, closing))
+		step.InputParams = []ParameterInfo{}
+		if step.CaseID == "" {
+			return nil, d.toks[j].off, fmt.Errorf("静态工作流缺少用例ID")
+		}
+	} else {
+		step.InputParams = d.parameters(j+1, closing)
+	}
+
+	// -> 由 - 和 > 两个相邻的词法单元组成
+	j = closing + 1
+	if j+1 >= end || d.toks[j].tok != token.SUB || d.toks[j+1].tok != token.GTR || d.toks[j+1].off != d.toks[j].end {
+		return nil, d.offsetAt(j, end), fmt.Errorf("步骤定义缺少 -> 分隔符")
+	}
+
+	// 返回值：(name: type "描述", ...) 或旧格式 bool 验证结果, err 是否失败，之后可以有元数据 {key: value}
+	from, to := j+2, end
+	for to > from && d.toks[to-1].tok == token.COMMENT {
+		to--
+	}
+	step.Metadata = make(map[string]interface{})
+	for k := from; k < to; k++ {
+		tok := d.toks[k].tok
+		if tok != token.LPAREN && tok != token.LBRACK && tok != token.LBRACE {
+			continue
+		}
+		closing := d.matching(k)
+		if closing < 0 || closing >= to {
+			return nil, d.toks[k].off, fmt.Errorf("步骤定义括号不匹配")
+		}
+		// 元数据是最后一个不属于类型（interface{}、struct{}）的大括号
+		if tok == token.LBRACE && closing == to-1 && k > from && d.toks[k-1].tok != token.INTERFACE && d.toks[k-1].tok != token.STRUCT {
+			step.Metadata = d.p.parseMetadata(d.tokenText(k, to))
+			to = k
+			break
+		}
+		k = closing
+	}
+	if from < to && d.toks[from].tok == token.LPAREN {
+		if closing := d.matching(from); closing != to-1 {
+			return nil, d.toks[closing+1].off, fmt.Errorf("步骤定义格式错误: %s", d.tokenText(closing+1, to))
+		}
+		from, to = from+1, to-1
+	}
+	if d.hasToken(from, to, token.COLON) && d.hasToken(from, to, token.STRING) {
+		step.OutputParams = d.parameters(from, to)
+	} else {
+		// 旧格式：bool 验证结果, err 是否失败
+		step.OutputParams = d.p.parseLegacyOutputPart(d.tokenText(from, to))
+	}
+
+	step.Desc = d.directive(line, "desc")
+	return step, 0, nil
+}
+
+// parameters 解析 [from, to) 中逗号分隔的参数定义 name: type "描述"，括号内的逗号不分割
+func (d *dslParser) parameters(from, to int) []ParameterInfo {
+	params := make([]ParameterInfo, 0)
+	depth, start := 0, from
+	for k := from; k <= to; k++ {
+		if k < to {
+			switch d.toks[k].tok {
+			case token.LPAREN, token.LBRACK, token.LBRACE:
+				depth++
+				continue
+			case token.RPAREN, token.RBRACK, token.RBRACE:
+				depth--
+				continue
+			case token.COMMA:
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if text := strings.TrimSpace(d.tokenText(start, k)); text != "" {
+			if param := d.p.parseSingleParameterDefinition(text); param != nil {
+				params = append(params, *param)
+			}
+		}
+		start = k + 1
+	}
+	return params
+}
+
+// hasToken 判断 [from, to) 中是否有 tok
+func (d *dslParser) hasToken(from, to int, tok token.Token) bool {
+	for k := from; k < to; k++ {
+		if d.toks[k].tok == tok {
+			return true
+		}
+	}
+	return false
+}
+
+// functionToken 判断是否可以是函数名的一部分：标识符、点号、数字和关键字（如 beiluo.test1.crm.type）
+func (d *dslParser) functionToken(tok token.Token) bool {
+	return tok == token.IDENT || tok == token.PERIOD || tok == token.INT || tok == token.FLOAT || tok.IsKeyword()
+}
+
+// offsetAt 返回第 j 个词法单元的偏移，j 到达声明末尾时返回前一个词法单元的结束位置
+func (d *dslParser) offsetAt(j, end int) int {
+	if j < end {
+		return d.toks[j].off
+	}
+	return d.toks[end-1].end
+}
+
+// parseInput 解析 input 的字面量，支持 map[string]interface{}{...} 和 {...}
+func (d *dslParser) parseInput(i, end int) {
+	open := -1
+	for j := i; j < end; j++ {
+		if d.toks[j].tok == token.LBRACE && (j == 0 || d.toks[j-1].tok != token.INTERFACE) {
+			open = j
+			break
+		}
+	}
+	if open < 0 {
+		d.errorf(d.toks[i-1].off, "输入变量定义格式错误")
+		return
+	}
+	closing := d.matching(open)
+	if closing < 0 || closing >= end {
+		d.errorf(d.toks[open].off, "输入变量定义括号不匹配")
+		return
+	}
+	content := d.code[d.toks[open].end:d.toks[closing].off]
+
+	// 按Go语法解析，列表等非Go语法的值回退到按行解析
+	expr, err := parser.ParseExpr("map[string]interface{}{" + content + "}")
+	lit, ok := expr.(*ast.CompositeLit)
+	if err != nil || !ok {
+		inputVars, _ := d.p.parseMapContent(content)
+		d.result.InputVars = inputVars
+		return
+	}
+	src := "map[string]interface{}{" + content + "}"
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key := src[kv.Key.Pos()-1 : kv.Key.End()-1]
+		if unquoted, err := strconv.Unquote(key); err == nil {
+			key = unquoted
+		}
+		d.result.InputVars[key] = inputValue(kv.Value, src[kv.Value.Pos()-1:kv.Value.End()-1])
+	}
+}
+
+// inputValue 输入参数的值：字面量（包括 -1、+1.5 和复合字面量）按 literalValue 计算，其他保留原始文本
+func inputValue(expr ast.Expr, text string) interface{} {
+	if value, _, ok := literalValue(expr, ""); ok {
+		return value
+	}
+	return text
+}
+
+// parseMain 用 go/parser 解析 main 函数并转换为语句
+//
+// 交给 go/parser 的源码由 main 函数的词法单元拼接而成，去掉注释、缩进和调用后的元数据，
+// 只保留换行（影响分号的自动插入），避免 go/parser 再扫描一遍这些内容。
+func (d *dslParser) parseMain() {
+	d.stripMetadata()
+
+	var src strings.Builder
+	src.Grow(len(goSourcePrefix) + d.mainEnd - d.mainStart)
+	src.WriteString(goSourcePrefix)
+	last := -1 // 上一个写入的词法单元的结束偏移
+	for i := d.mainToken(); i < len(d.toks) && d.toks[i].off < d.mainEnd; i++ {
+		t := d.toks[i]
+		if t.tok == token.COMMENT || (t.tok == token.SEMICOLON && t.lit == "\n") {
+			continue
+		}
+		if last >= 0 && last < t.off {
+			if strings.Contains(d.code[last:t.off], "\n") {
+				src.WriteByte('\n')
+			} else {
+				src.WriteByte(' ')
+			}
+		}
+		d.goTokens = append(d.goTokens, goToken{at: src.Len(), off: t.off})
+		src.WriteString(d.code[t.off:t.end])
+		last = t.end
+		if block, exists := d.metadata[t.off]; exists && t.tok == token.RPAREN {
+			for i+1 < len(d.toks) && d.toks[i+1].off < block.end {
+				i++
+			}
+		}
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src.String(), parser.AllErrors|parser.SkipObjectResolution)
+	if list, ok := err.(scanner.ErrorList); ok {
+		// 同一行只报告第一个错误，后面的通常是连带产生的
+		reported := make(map[int]bool)
+		for _, e := range list {
+			if !reported[e.Pos.Line] {
+				reported[e.Pos.Line] = true
+				d.errorf(d.sourceOffset(e.Pos.Offset), "%s", e.Msg)
+			}
+		}
+	} else if err != nil {
+		d.errorf(d.mainStart, "%v", err)
+	}
+	if err != nil {
+		return
+	}
+	d.goFile = fset.File(file.Pos())
+
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "main" && fn.Body != nil {
+			d.result.MainFunc.Statements = d.convertBlock(fn.Body.List)
+		}
+	}
+}
+
+// mainToken 返回 main 函数的第一个词法单元
+func (d *dslParser) mainToken() int {
+	return sort.Search(len(d.toks), func(i int) bool { return d.toks[i].off >= d.mainStart })
+}
+
+// stripMetadata 找出 main 函数中步骤调用后的元数据 step1(x){retry: 1}，按调用的右括号记录
+//
+// 调用的右括号后紧跟 { 且所在语句不是 if/for/switch/else 时，{} 内为元数据。
+func (d *dslParser) stripMetadata() {
+	first := token.ILLEGAL // 当前语句的第一个词法单元
+	depth := 0             // 当前语句内的括号深度
+	for i := d.mainToken(); i < len(d.toks) && d.toks[i].off < d.mainEnd; i++ {
+		t := d.toks[i]
+		if t.tok == token.COMMENT {
+			continue
+		}
+		if first == token.ILLEGAL {
+			first = t.tok
+		}
+		switch t.tok {
+		case token.LPAREN, token.LBRACK:
+			depth++
+		case token.RPAREN, token.RBRACK:
+			depth--
+			if t.tok != token.RPAREN || depth != 0 || i+1 >= len(d.toks) || d.toks[i+1].tok != token.LBRACE {
+				continue
+			}
+			switch first {
+			case token.IF, token.FOR, token.SWITCH, token.ELSE, token.RBRACE, token.FUNC:
+				continue
+			}
+			closing := d.matching(i + 1)
+			if closing < 0 {
+				continue
+			}
+			from, to := d.toks[i+1].off, d.toks[closing].end
+			d.metadata[t.off] = metadataBlock{text: d.code[from:to], end: to}
+			i = closing
+		case token.LBRACE, token.RBRACE, token.SEMICOLON:
+			if depth == 0 {
+				first = token.ILLEGAL
+			}
+			if t.tok == token.RBRACE && i+1 < len(d.toks) && d.toks[i+1].tok == token.ELSE {
+				first = token.ELSE
+			}
+		}
+	}
+}
+
+// offset 把 go/parser 的位置换算为源码偏移
+func (d *dslParser) offset(pos token.Pos) int {
+	return d.sourceOffset(d.goFile.Offset(pos))
+}
+
+// sourceOffset 把交给 go/parser 的源码中的偏移换算为源码偏移，词法单元之间的位置按前一个词法单元计算
+func (d *dslParser) sourceOffset(at int) int {
+	i := sort.Search(len(d.goTokens), func(i int) bool { return d.goTokens[i].at > at }) - 1
+	if i < 0 {
+		return d.mainStart
+	}
+	return min(d.goTokens[i].off+at-d.goTokens[i].at, len(d.code))
+}
+
+func (d *dslParser) line(pos token.Pos) int {
+	line, _ := d.position(d.offset(pos))
+	return line
+}
+
+// text 返回语法树节点之间的源码
+func (d *dslParser) text(from, to token.Pos) string {
+	return strings.TrimSpace(d.code[d.offset(from):d.offset(to)])
+}
+
+func (d *dslParser) convertBlock(list []ast.Stmt) []*SimpleStatement {
+	statements := []*SimpleStatement{}
+	for _, s := range list {
+		statements = append(statements, d.convertStmt(s)...)
+	}
+	return statements
+}
+
+// convertStmt 把Go语句转换为 SimpleStatement，单独的代码块 {} 展开为其中的语句
+func (d *dslParser) convertStmt(s ast.Stmt) []*SimpleStatement {
+	switch n := s.(type) {
+	case *ast.EmptyStmt:
+		return nil
+	case *ast.BlockStmt:
+		return d.convertBlock(n.List)
+	case *ast.IfStmt:
+		return []*SimpleStatement{d.convertIf(n)}
+	case *ast.ForStmt, *ast.RangeStmt:
+		return []*SimpleStatement{d.convertFor(n)}
+	case *ast.BranchStmt:
+		if n.Tok == token.BREAK || n.Tok == token.CONTINUE {
+			return []*SimpleStatement{d.simpleStatement(n, n.Tok.String(), n.End())}
+		}
+	case *ast.ReturnStmt:
+		return []*SimpleStatement{d.simpleStatement(n, "return", n.End())}
+	case *ast.ExprStmt:
+		if call, ok := n.X.(*ast.CallExpr); ok {
+			stmt := d.simpleStatement(n, "function-call", n.End())
+			if isPrintCall(call) {
+				stmt.Type = "print"
+				return []*SimpleStatement{stmt}
+			}
+			if isWaitCall(call) {
+				d.convertWait(stmt, call, nil)
+				return []*SimpleStatement{stmt}
+			}
+			d.convertCall(stmt, n, call, nil)
+			return []*SimpleStatement{stmt}
+		}
+	case *ast.AssignStmt:
+		return []*SimpleStatement{d.convertAssign(n)}
+	}
+	return []*SimpleStatement{d.simpleStatement(s, "other", s.End())}
+}
+
+// convertIf 转换 if 语句，else if / else 链展开为 Branches
+func (d *dslParser) convertIf(n *ast.IfStmt) *SimpleStatement {
+	stmt := d.simpleStatement(n, "if", n.Body.Lbrace+1)
+	stmt.Condition = d.text(n.If+2, n.Body.Lbrace)
+	stmt.Children = d.convertBlock(n.Body.List)
+
+	rbrace := n.Body.Rbrace
+	for els := n.Else; els != nil; {
+		branch := &SimpleStatement{
+			Type:       "branch",
+			LineNumber: d.line(rbrace),
+			Status:     StatusPending,
+			RetryCount: 0,
+		}
+		switch e := els.(type) {
+		case *ast.IfStmt:
+			branch.Content = d.text(rbrace, e.Body.Lbrace+1)
+			branch.Condition = d.text(e.If+2, e.Body.Lbrace)
+			branch.Children = d.convertBlock(e.Body.List)
+			rbrace, els = e.Body.Rbrace, e.Else
+		case *ast.BlockStmt:
+			branch.Content = d.text(rbrace, e.Lbrace+1)
+			branch.Children = d.convertBlock(e.List)
+			els = nil
+		}
+		stmt.Branches = append(stmt.Branches, branch)
+	}
+	return stmt
+}
+
+// convertFor 转换 for 循环：for k, v := range 列表 {} 和 for 条件 {}，三段式循环报告错误
+func (d *dslParser) convertFor(n ast.Stmt) *SimpleStatement {
+	var body *ast.BlockStmt
+	var stmt *SimpleStatement
+	switch f := n.(type) {
+	case *ast.RangeStmt:
+		body = f.Body
+		stmt = d.simpleStatement(n, "for", body.Lbrace+1)
+		stmt.Loop = &LoopInfo{Kind: LoopKindRange, RangeExpr: d.text(f.X.Pos(), f.X.End())}
+		if f.Key != nil {
+			stmt.Loop.KeyVar = d.text(f.Key.Pos(), f.Key.End())
+		}
+		if f.Value != nil {
+			stmt.Loop.ValueVar = d.text(f.Value.Pos(), f.Value.End())
+		}
+
+		// 建立循环变量映射
+		for _, name := range []string{stmt.Loop.KeyVar, stmt.Loop.ValueVar} {
+			if name == "" || name == "_" {
+				continue
+			}
+			d.result.Variables[name] = VariableInfo{
+				Name:    name,
+				Type:    "unknown",
+				Source:  "range",
+				LineNum: stmt.LineNumber,
+				IsInput: false,
+			}
+		}
+	case *ast.ForStmt:
+		// 执行器只支持 for 条件 {}，三段式循环的初始化和后置语句不会执行
+		if f.Init != nil {
+			d.errorf(d.offset(f.Init.Pos()), "不支持三段式 for 循环，请使用 for 条件 {} 或 for range")
+		} else if f.Post != nil {
+			d.errorf(d.offset(f.Post.Pos()), "不支持三段式 for 循环，请使用 for 条件 {} 或 for range")
+		}
+		body = f.Body
+		stmt = d.simpleStatement(n, "for", body.Lbrace+1)
+		stmt.Loop = &LoopInfo{Kind: LoopKindCond}
+		stmt.Condition = d.text(f.For+3, body.Lbrace)
+	}
+	stmt.Metadata = make(map[string]interface{})
+
+	// //max_iterations: 50 注释设置最大迭代次数
+	if value := d.directive(stmt.LineNumber, "max_iterations"); value != "" {
+		if maxIterations, err := strconv.Atoi(value); err == nil {
+			stmt.Metadata["max_iterations"] = maxIterations
+		}
+	}
+	stmt.Children = d.convertBlock(body.List)
+	return stmt
+}
+
+// isPrintCall 判断是否为打印语句：sys.Println(...)、step1.Printf(...)
+func isPrintCall(call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if _, ok := sel.X.(*ast.Ident); !ok {
+		return false
+	}
+	switch sel.Sel.Name {
+	case "Print", "Printf", "Println":
+		return true
+	}
+	return false
+}
+
+// isWaitCall 判断是否为等待信号语句：wait("manager_approval", "24h")
+func isWaitCall(call *ast.CallExpr) bool {
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && ident.Name == "wait"
+}
+
+// simpleStatement 创建语句，Content 为语句的源码
+func (d *dslParser) simpleStatement(node ast.Node, stmtType string, end token.Pos) *SimpleStatement {
+	line := d.line(node.Pos())
+	return &SimpleStatement{
+		Type:       stmtType,
+		Content:    d.text(node.Pos(), end),
+		LineNumber: line,
+		Status:     StatusPending,
+		RetryCount: 0,
+		Desc:       d.directive(line, "desc"),
+	}
+}
+
+// convertAssign 转换赋值语句：调用步骤为 function-call，:= 为 var，= 为 assign
+func (d *dslParser) convertAssign(n *ast.AssignStmt) *SimpleStatement {
+	var call *ast.CallExpr
+	if len(n.Rhs) == 1 {
+		call, _ = n.Rhs[0].(*ast.CallExpr)
+	}
+
+	switch {
+	case call != nil && n.Tok == token.DEFINE && isWaitCall(call):
+		stmt := d.simpleStatement(n, "wait", n.End())
+		vars := make([]string, len(n.Lhs))
+		for i, lhs := range n.Lhs {
+			vars[i] = d.text(lhs.Pos(), lhs.End())
+		}
+		d.convertWait(stmt, call, vars)
+		return stmt
+	case call != nil && (n.Tok == token.DEFINE || n.Tok == token.ASSIGN):
+		stmt := d.simpleStatement(n, "function-call", n.End())
+		var returns *string
+		if n.Tok == token.DEFINE {
+			lhs := d.text(n.Pos(), n.TokPos)
+			returns = &lhs
+		}
+		d.convertCall(stmt, n, call, returns)
+		return stmt
+	case n.Tok == token.DEFINE:
+		stmt := d.simpleStatement(n, "var", n.End())
+		varName := d.text(n.Pos(), n.TokPos)
+		d.result.Variables[varName] = VariableInfo{
+			Name:    varName,
+			Type:    d.assignType(n),
+			Source:  "assignment",
+			LineNum: stmt.LineNumber,
+			IsInput: false,
+		}
+		return stmt
+	case n.Tok == token.ASSIGN:
+		return d.simpleStatement(n, "assign", n.End())
+	default:
+		return d.simpleStatement(n, "other", n.End())
+	}
+}
+
+// assignType 推断 := 赋值的变量类型：字面量按值推断，引用已定义的变量时与该变量相同，其他表达式为 unknown
+func (d *dslParser) assignType(n *ast.AssignStmt) string {
+	if len(n.Rhs) != 1 {
+		return "unknown"
+	}
+	if _, t, ok := literalValue(n.Rhs[0], ""); ok {
+		return t
+	}
+	if ident, ok := n.Rhs[0].(*ast.Ident); ok {
+		if variable, exists := d.result.Variables[ident.Name]; exists {
+			return variable.Type
+		}
+	}
+	return "unknown"
+}
+
+// convertCall 填充步骤调用的函数名、参数、元数据和返回变量，node 为调用所在的语句，returns 为 := 左边的变量列表
+func (d *dslParser) convertCall(stmt *SimpleStatement, node ast.Stmt, call *ast.CallExpr, returns *string) {
+	stmt.Metadata = make(map[string]interface{})
+	if block, exists := d.metadata[d.offset(call.Rparen)]; exists {
+		// 语句可能与 if/for 等写在同一行，Content 从语句开始
+		stmt.Metadata = d.p.parseMetadata(block.text)
+		stmt.Content = strings.TrimSpace(d.code[d.offset(node.Pos()):block.end])
+	}
+
+	stmt.Function = d.text(call.Fun.Pos(), call.Fun.End())
+	// 参数直接使用语法树，描述来自步骤定义中对应的输入参数
+	var inputParams []ParameterInfo
+	for _, step := range d.result.Steps {
+		if step.Name == stmt.Function {
+			inputParams = step.InputParams
+			break
+		}
+	}
+	for i, arg := range call.Args {
+		var desc string
+		if i < len(inputParams) {
+			desc = inputParams[i].Desc
+		}
+		_, literalType, isLiteral := literalValue(arg, "")
+		stmt.Args = append(stmt.Args, d.p.newArgument(d.text(arg.Pos(), arg.End()), desc, literalType, isLiteral, d.result))
+	}
+	if returns != nil {
+		stmt.Returns = d.p.parseReturnVariables(*returns, stmt.Function, stmt.LineNumber, d.result)
+	}
+}
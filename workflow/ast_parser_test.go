@@ -0,0 +1,262 @@
+package workflow
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseWorkflow_ErrorPositions 测试一次报告多个错误及其行号列号
+func TestParseWorkflow_ErrorPositions(t *testing.T) {
+	code := `step1 = beiluo.test1.crm.create(name: string "姓名") -> (id: string "编号", err: error "是否失败");
+step2 = beiluo.test1.crm.update(name: string "姓名"
+step3 = beiluo.test1.crm.delete(id: string "编号") -> (err: error "是否失败");
+
+func main() {
+    编号, err := step1("张三"))
+    if err != nil {
+        return
+    }
+    消息 := "未结束
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if result.Success {
+		t.Fatal("应解析失败")
+	}
+
+	want := []ParseError{
+		{Line: 2, Column: 32, Msg: "步骤定义括号不匹配"},
+		{Line: 6, Column: 27, Msg: "expected statement, found ')'"},
+		{Line: 10, Column: 11, Msg: "string literal not terminated"},
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("错误数量不正确: 期望 %d, 实际 %d\n%v", len(want), len(result.Errors), result.Errors)
+	}
+	for i, w := range want {
+		e := result.Errors[i]
+		if e.Line != w.Line || e.Column != w.Column || !strings.Contains(e.Msg, w.Msg) {
+			t.Errorf("第%d个错误不正确: 期望 %d:%d %q, 实际 %s", i, w.Line, w.Column, w.Msg, e)
+		}
+	}
+	if !strings.HasPrefix(result.Error, "第2行第32列: ") {
+		t.Errorf("错误信息不正确: %s", result.Error)
+	}
+
+	// 出错的声明不影响后面的声明
+	if len(result.Steps) != 2 || result.Steps[1].Name != "step3" {
+		t.Errorf("步骤解析不正确: %+v", result.Steps)
+	}
+}
+
+// TestParseWorkflow_TopLevelErrors 测试main函数之外的错误
+func TestParseWorkflow_TopLevelErrors(t *testing.T) {
+	code := `step1 = beiluo.test1.crm.create(name: string "姓名") -> (err: error "是否失败");
+step1.run()
+
+func helper() {
+}
+
+func main() {
+    step1("张三")
+}
+
+func main() {
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	var got []string
+	for _, e := range result.Errors {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"第2行第1列: 无法识别的声明: step1.run()",
+		"第4行第1列: 只支持 main 函数",
+		"第11行第1列: main 函数重复定义",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("错误不正确:\n%s\n期望:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestParseWorkflow_StepDeclaration 测试按词法单元解析步骤定义，字符串中的 = 和 -> 不影响解析
+func TestParseWorkflow_StepDeclaration(t *testing.T) {
+	code := `step1 = beiluo.test1.crm.update(name: string "旧值 -> 新值", expr: string "a = b") -> (ok: bool "是否=成功") {retry: 1};
+step2 = beiluo.test1.crm.case[用例1] -> (err: error "是否失败");
+
+func main() {
+    ok := step1("张三", "x")
+    step2()
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	step := result.Steps[0]
+	if step.Function != "beiluo.test1.crm.update" || len(step.InputParams) != 2 || step.InputParams[0].Desc != "旧值 -> 新值" || step.InputParams[1].Desc != "a = b" {
+		t.Errorf("输入参数不正确: %s %+v", step.Function, step.InputParams)
+	}
+	if len(step.OutputParams) != 1 || step.OutputParams[0].Desc != "是否=成功" || step.Metadata["retry"] != 1 {
+		t.Errorf("输出参数不正确: %+v %v", step.OutputParams, step.Metadata)
+	}
+	if static := result.Steps[1]; !static.IsStatic || static.CaseID != "用例1" || static.Function != "beiluo.test1.crm.case" {
+		t.Errorf("静态工作流不正确: %+v", static)
+	}
+
+	// 错误报告在出错的词法单元上
+	result = NewSimpleParser().ParseWorkflow(`step1 = beiluo.test1.crm.update(name: string "旧值 -> 新值") (ok: bool "是否成功");
+step2 = (name: string "姓名") -> (ok: bool "是否成功");
+
+func main() {
+    step1("张三")
+}`)
+	var got []string
+	for _, e := range result.Errors {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"第1行第58列: 步骤定义缺少 -> 分隔符",
+		"第2行第9列: 步骤定义缺少函数名",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("错误不正确:\n%s\n期望:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// 单独解析步骤定义
+	parsed, err := NewSimpleParser().ParseStep(`step1 = beiluo.test1.crm.update(name: string "旧值 -> 新值") -> (ok: bool "是否成功")`)
+	if err != nil || parsed.Name != "step1" || parsed.InputParams[0].Desc != "旧值 -> 新值" || len(parsed.OutputParams) != 1 {
+		t.Errorf("ParseStep 不正确: %+v %v", parsed, err)
+	}
+}
+
+// TestParseWorkflow_MultiLine 测试跨行的步骤定义、调用和元数据
+func TestParseWorkflow_MultiLine(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "姓名": "张三",
+    "年龄": 30,
+    "在职": true,
+}
+
+step1 = beiluo.test1.crm.create(
+    name: string "姓名",
+    age: int "年龄",
+) -> (id: string "编号", err: error "是否失败");
+
+func main() {
+    //desc: 创建用户
+    编号, err := step1(
+        input["姓名"],
+        input["年龄"],
+    ){retry: 2, timeout: 1000}
+    if err != nil { return } else { sys.Println(编号) }
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if result.InputVars["姓名"] != "张三" || result.InputVars["年龄"] != 30 || result.InputVars["在职"] != true {
+		t.Errorf("输入参数不正确: %v", result.InputVars)
+	}
+	if len(result.Steps) != 1 || len(result.Steps[0].InputParams) != 2 || len(result.Steps[0].OutputParams) != 2 {
+		t.Fatalf("步骤定义不正确: %+v", result.Steps)
+	}
+
+	statements := result.MainFunc.Statements
+	if len(statements) != 2 {
+		t.Fatalf("语句数量不正确: %d", len(statements))
+	}
+	call := statements[0]
+	if call.Type != "function-call" || call.Function != "step1" || call.LineNumber != 14 || call.Desc != "创建用户" {
+		t.Errorf("步骤调用不正确: %+v", call)
+	}
+	if len(call.Args) != 2 || len(call.Returns) != 2 || call.GetRetryCount() != 2 {
+		t.Errorf("步骤调用参数不正确: args=%d returns=%d metadata=%v", len(call.Args), len(call.Returns), call.Metadata)
+	}
+	if !strings.HasSuffix(call.Content, "){retry: 2, timeout: 1000}") {
+		t.Errorf("语句内容不正确: %s", call.Content)
+	}
+
+	ifStmt := statements[1]
+	if ifStmt.Type != "if" || ifStmt.Condition != "err != nil" || len(ifStmt.Children) != 1 ||
+		len(ifStmt.Branches) != 1 || ifStmt.Branches[0].LineNumber != 18 || ifStmt.Branches[0].Children[0].Type != "print" {
+		t.Errorf("if语句不正确: %+v", ifStmt)
+	}
+}
+
+// FuzzParseWorkflow 任意输入都不应使解析器崩溃，解析成功时结果可以通过静态检查
+func FuzzParseWorkflow(f *testing.F) {
+	seeds := []string{
+		concurrencyTestCode,
+		`step1 = beiluo.test1.crm.create(name: string "姓名") -> (err: error "是否失败");
+func main() {
+    //max_iterations: 3
+    for i, 名字 := range 列表 {
+        if i > 1 { break } else if i == 0 { continue }
+        err := step1(名字){async: true}
+    }
+    for { return }
+}`,
+		`var (
+    input = {"姓名": "张三"}
+    step1 = beiluo.test1.crm.create[用例001] -> (err: error "是否失败")
+)
+func main() { step1() }`,
+		"func main() {\n\tx := `多行\n字符串`\n\tsys.Println(x)\n}",
+		"func main() { step1(){ }",
+		"step1 = a.b(",
+		"func main() {\n}\n}",
+		"var input = map[string]interface{}{\"a\": [1, 2}\nfunc main() {}",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, code string) {
+		result := NewSimpleParser().ParseWorkflow(code)
+		if result.Success {
+			Validate(result)
+			return
+		}
+		if result.Error == "" {
+			t.Error("解析失败时应有错误信息")
+		}
+		for _, e := range result.Errors {
+			if e.Line < 1 || e.Column < 1 {
+				t.Errorf("错误位置不正确: %v", e)
+			}
+		}
+	})
+}
+
+// TestParseWorkflow_SignedInputs 测试输入参数中带符号的数字按数值解析，可以直接参与比较
+func TestParseWorkflow_SignedInputs(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "n":    -1,
+    "比例": -0.5,
+    "增量": +1.5,
+    "标签": []string{"a", "b"},
+}
+
+func main() {
+    if input["n"] < 0 {
+        sys.Println("负数")
+    }
+}`
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	want := map[string]interface{}{"n": -1, "比例": -0.5, "增量": 1.5, "标签": []string{"a", "b"}}
+	if !reflect.DeepEqual(result.InputVars, want) {
+		t.Errorf("输入参数不正确: %#v", result.InputVars)
+	}
+	if err := NewExecutor().Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "负数" {
+		t.Errorf("负数输入参数比较不正确: %+v", result.GlobalLogs)
+	}
+}
@@ -0,0 +1,296 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// 一次工作流执行的运行时状态在上下文中的键
+type flowRunKey struct{}
+
+// 语句中的标识符，用于推断语句读写了哪些变量
+var identifierRegex = regexp.MustCompile(`[\p{L}_][\p{L}\p{N}_]*`)
+
+// flowRun 一次工作流执行的运行时状态
+//
+// 语句状态、变量、日志和状态回调都在 mu 的保护下修改，
+// 步骤仅在调用业务回调（OnFunctionCall）和重试等待期间释放 mu，
+// 因此异步步骤的业务回调可以并发执行，而 OnWorkFlowUpdate 总是串行触发。
+type flowRun struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	slots   chan struct{}         // 异步步骤并发槽位，容量为 max_parallel
+	pending map[string]*asyncTask // 变量名 -> 尚未完成的产生该变量的异步步骤
+	tasks   []*asyncTask          // 已启动的全部异步步骤
+	err     error                 // 第一个失败的异步步骤的错误
+
+	resuming bool // 从存储恢复执行，已完成的语句不再执行
+
+	// 执行goroutine在持有锁时调用状态回调、观察者和 Store 期间不修改工作流，
+	// 此时 Get 直接复制工作流，不等待锁，回调中调用 Get 也不会死锁
+	readers   sync.RWMutex
+	callbacks int
+}
+
+// asyncTask 一个异步执行的步骤
+type asyncTask struct {
+	stmt *SimpleStatement
+	done bool
+	err  error
+}
+
+func newFlowRun(maxParallel int) *flowRun {
+	run := &flowRun{
+		slots:   make(chan struct{}, maxParallel),
+		pending: make(map[string]*asyncTask),
+	}
+	run.cond = sync.NewCond(&run.mu)
+	return run
+}
+
+// flowRunFromContext 获取当前执行的运行时状态，直接调用语句执行方法时返回nil
+func flowRunFromContext(ctx context.Context) *flowRun {
+	run, _ := ctx.Value(flowRunKey{}).(*flowRun)
+	return run
+}
+
+// unlocked 在释放锁的情况下执行fn，用于业务回调和等待等耗时操作
+func (r *flowRun) unlocked(fn func()) {
+	if r == nil {
+		fn()
+		return
+	}
+	r.mu.Unlock()
+	defer r.mu.Lock()
+	fn()
+}
+
+// callback 执行持有锁时调用的回调，回调结束后等待正在复制工作流的 Get 返回
+func (r *flowRun) callback(fn func()) {
+	if r == nil {
+		fn()
+		return
+	}
+	r.readers.Lock()
+	r.callbacks++
+	r.readers.Unlock()
+	defer func() {
+		r.readers.Lock()
+		r.callbacks--
+		r.readers.Unlock()
+	}()
+	fn()
+}
+
+// snapshot 复制正在执行的工作流，不需要持有锁
+func (r *flowRun) snapshot(workflow *SimpleParseResult) *SimpleParseResult {
+	r.readers.RLock()
+	if r.callbacks > 0 {
+		defer r.readers.RUnlock()
+		return workflow.Snapshot()
+	}
+	r.readers.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return workflow.Snapshot()
+}
+
+// skip 恢复执行时已完成或跳过的语句不再执行
+func (r *flowRun) skip(stmt *SimpleStatement) bool {
+	return r != nil && r.resuming && (stmt.Status == StatusCompleted || stmt.Status == StatusSkipped)
+}
+
+// wait 等待产生指定变量的异步步骤完成，需持有锁；返回已失败的异步步骤的错误
+func (r *flowRun) wait(names []string) error {
+	if r == nil {
+		return nil
+	}
+	for _, name := range names {
+		if task, exists := r.pending[name]; exists {
+			for !task.done {
+				r.cond.Wait()
+			}
+		}
+	}
+	return r.err
+}
+
+// waitAll 等待所有异步步骤完成，需持有锁
+func (r *flowRun) waitAll() error {
+	if r == nil {
+		return nil
+	}
+	for _, task := range r.tasks {
+		for !task.done {
+			r.cond.Wait()
+		}
+	}
+	return r.err
+}
+
+// finish 标记异步步骤完成并唤醒等待者，需持有锁
+func (r *flowRun) finish(task *asyncTask, err error) {
+	task.done = true
+	task.err = err
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("异步步骤 %s 执行失败(第%d行): %w", task.stmt.Function, task.stmt.LineNumber, err)
+	}
+	for name, pending := range r.pending {
+		if pending == task {
+			delete(r.pending, name)
+		}
+	}
+	r.cond.Broadcast()
+}
+
+// isAsyncCall 是否为需要异步执行的步骤调用
+func isAsyncCall(ctx context.Context, stmt *SimpleStatement) bool {
+	return stmt.Type == "function-call" && stmt.IsAsync() && flowRunFromContext(ctx) != nil
+}
+
+// waitDependencies 等待语句（含嵌套语句）读写的变量所依赖的异步步骤完成
+func (e *Executor) waitDependencies(ctx context.Context, stmt *SimpleStatement) error {
+	return flowRunFromContext(ctx).wait(statementNames(stmt))
+}
+
+// startAsync 在新的goroutine中执行异步步骤，返回后主流程继续执行后续语句
+//
+// 异步步骤在它读写的变量所依赖的其他异步步骤完成后才开始，
+// 并受 max_parallel 限制；后续读取其返回变量的语句会等待它完成。
+func (e *Executor) startAsync(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) {
+	run := flowRunFromContext(ctx)
+
+	// 1. 记录依赖，依赖必须在登记本步骤的返回变量之前收集
+	names := statementNames(stmt)
+	var deps []*asyncTask
+	for _, name := range names {
+		if task, exists := run.pending[name]; exists {
+			deps = append(deps, task)
+		}
+	}
+
+	// 2. 参数在启动时求值，循环中的异步步骤使用本次迭代的循环变量；
+	// 依赖其他异步步骤返回值的参数要等依赖完成后才能求值，其余参数的值先取出
+	step, inputErr := stepDefinition(stmt, workflow)
+	var realInput, bound map[string]interface{}
+	if inputErr == nil {
+		if len(deps) == 0 {
+			realInput, inputErr = stepInput(stmt, step, workflow, nil)
+		} else {
+			bound = make(map[string]interface{})
+			for _, arg := range stmt.Args {
+				if _, waiting := run.pending[arg.Value]; waiting {
+					continue
+				}
+				if variable, exists := workflow.GetVariable(arg.Value); exists {
+					bound[arg.Value] = variable.Value
+				}
+			}
+		}
+	}
+
+	task := &asyncTask{stmt: stmt}
+	for _, name := range asyncWrites(stmt) {
+		run.pending[name] = task
+	}
+	run.tasks = append(run.tasks, task)
+
+	go func() {
+		run.mu.Lock()
+		defer run.mu.Unlock()
+
+		// 3. 等待依赖的异步步骤
+		for _, dep := range deps {
+			for !dep.done {
+				run.cond.Wait()
+			}
+		}
+		if run.err != nil || ctx.Err() != nil {
+			markSkipped([]*SimpleStatement{stmt})
+			run.finish(task, nil)
+			return
+		}
+
+		// 4. 获取并发槽位
+		acquired := false
+		run.unlocked(func() {
+			select {
+			case run.slots <- struct{}{}:
+				acquired = true
+			case <-ctx.Done():
+			}
+		})
+		if !acquired {
+			run.finish(task, interruptedError(ctx, stmt, "步骤执行"))
+			return
+		}
+		defer func() { <-run.slots }()
+
+		// 5. 执行步骤
+		if inputErr == nil && realInput == nil {
+			realInput, inputErr = stepInput(stmt, step, workflow, bound)
+		}
+		stmt.StartExecution()
+		if err := e.updateWorkflow(ctx, workflow); err != nil {
+			stmt.EndExecution()
+			stmt.Status = StatusFailed
+			run.finish(task, err)
+			return
+		}
+		e.emitStatement(ctx, workflow, EventStatementStarted, stmt, nil)
+		err := inputErr
+		if err == nil {
+			err = e.callFunction(ctx, stmt, step, realInput, workflow)
+		}
+		e.emitStatement(ctx, workflow, EventStatementFinished, stmt, err)
+		stmt.EndExecution()
+		if err != nil && stmt.Status == StatusRunning {
+			stmt.Status = StatusFailed
+			_ = e.updateWorkflow(ctx, workflow)
+		}
+		run.finish(task, err)
+	}()
+}
+
+// statementNames 返回语句及其嵌套语句、分支中出现的标识符，作为读写变量的保守估计
+func statementNames(stmt *SimpleStatement) []string {
+	seen := make(map[string]bool)
+	var names []string
+	var walk func(s *SimpleStatement)
+	walk = func(s *SimpleStatement) {
+		for _, text := range []string{s.Content, s.Condition} {
+			for _, name := range identifierRegex.FindAllString(text, -1) {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		for _, child := range s.Children {
+			walk(child)
+		}
+		for _, branch := range s.Branches {
+			walk(branch)
+		}
+	}
+	walk(stmt)
+	return names
+}
+
+// asyncWrites 返回异步步骤写入的变量，err 重命名为 stepXErr 后别名 err 也视为写入
+func asyncWrites(stmt *SimpleStatement) []string {
+	var names []string
+	for _, ret := range stmt.Returns {
+		if ret.Value == "" || ret.Value == "_" {
+			continue
+		}
+		names = append(names, ret.Value)
+		if ret.Value == stmt.Function+"Err" {
+			names = append(names, "err")
+		}
+	}
+	return names
+}
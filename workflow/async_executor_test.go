@@ -0,0 +1,217 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const asyncTestCode = `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = beiluo.test1.hr.query_department(username: string "用户名") -> (department: string "部门", err: error "是否失败");
+step2 = beiluo.test1.hr.query_level(username: string "用户名") -> (level: string "职级", err: error "是否失败");
+step3 = beiluo.test1.hr.create_profile(department: string "部门", level: string "职级") -> (profileId: string "档案编号", err: error "是否失败");
+
+func main() {
+    部门, step1Err := step1(input["用户名"]){async: true}
+    职级, step2Err := step2(input["用户名"]){async: true}
+    档案编号, step3Err := step3(部门, 职级)
+    sys.Printf("档案编号: %s", 档案编号)
+}`
+
+// TestExecutor_AsyncParallel 测试异步步骤并发执行，读取其返回变量的语句等待其完成
+func TestExecutor_AsyncParallel(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(asyncTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	// step1 和 step2 互相等待对方开始，只有并发执行时才能都完成
+	var started sync.WaitGroup
+	started.Add(2)
+	bothStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(bothStarted)
+	}()
+
+	var step3Input map[string]interface{}
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		switch step.Name {
+		case "step1", "step2":
+			if !in.Options.Async {
+				t.Errorf("%s 的执行选项应为异步", step.Name)
+			}
+			started.Done()
+			select {
+			case <-bothStarted:
+			case <-time.After(2 * time.Second):
+				return nil, fmt.Errorf("%s 未与其他异步步骤并发执行", step.Name)
+			}
+			if step.Name == "step1" {
+				return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"department": "研发", "err": nil}}, nil
+			}
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"level": "P6", "err": nil}}, nil
+		default:
+			step3Input = in.RealInput
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"profileId": "P001", "err": nil}}, nil
+		}
+	}
+	executor.OnWorkFlowUpdate = func(ctx context.Context, current *SimpleParseResult) error {
+		// 状态回调串行触发，读取语句状态不会与异步步骤竞争
+		for _, stmt := range current.MainFunc.Statements {
+			_ = stmt.Status
+		}
+		return nil
+	}
+
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	if step3Input["department"] != "研发" || step3Input["level"] != "P6" {
+		t.Errorf("step3 未读取到异步步骤的返回值: %v", step3Input)
+	}
+	for i, stmt := range result.MainFunc.Statements {
+		if stmt.Status != StatusCompleted {
+			t.Errorf("语句 %d 状态不正确: %s", i, stmt.Status)
+		}
+	}
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "档案编号: P001" {
+		t.Errorf("日志不正确: %+v", result.GlobalLogs)
+	}
+}
+
+// TestExecutor_AsyncMaxParallel 测试 max_parallel 限制同时执行的异步步骤数量
+func TestExecutor_AsyncMaxParallel(t *testing.T) {
+	code := `//max_parallel: 2
+
+step1 = beiluo.test1.notify.send(to: string "接收人") -> (err: error "是否失败");
+
+func main() {
+    step1("张三"){async: true}
+    step1("李四"){async: true}
+    step1("王五"){async: true}
+    step1("赵六"){async: true}
+    sys.Println("已提交")
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if result.GetMaxParallel() != 2 {
+		t.Fatalf("最大并发数不正确: %d", result.GetMaxParallel())
+	}
+
+	var running, maxRunning, calls int32
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		atomic.AddInt32(&calls, 1)
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{}}, nil
+	}
+
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("调用次数不正确: %d", calls)
+	}
+	if maxRunning > 2 {
+		t.Errorf("同时执行的异步步骤超过限制: %d", maxRunning)
+	}
+	for i, stmt := range result.MainFunc.Statements {
+		if stmt.Status != StatusCompleted {
+			t.Errorf("语句 %d 状态不正确: %s", i, stmt.Status)
+		}
+	}
+}
+
+// TestExecutor_AsyncFailure 测试异步步骤失败时终止工作流，依赖它的语句不执行
+func TestExecutor_AsyncFailure(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(asyncTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	// step1 在 step2 开始后才失败，已开始的异步步骤正常执行完
+	step2Started := make(chan struct{})
+	step3Called := false
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		switch step.Name {
+		case "step1":
+			<-step2Started
+			return nil, fmt.Errorf("部门服务不可用")
+		case "step2":
+			close(step2Started)
+			time.Sleep(10 * time.Millisecond)
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"level": "P6", "err": nil}}, nil
+		default:
+			step3Called = true
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"profileId": "P001", "err": nil}}, nil
+		}
+	}
+
+	err := executor.Start(context.Background(), result)
+	if err == nil || !strings.Contains(err.Error(), "部门服务不可用") {
+		t.Fatalf("期望异步步骤的错误, 实际 %v", err)
+	}
+	if step3Called {
+		t.Error("依赖失败异步步骤的语句不应执行")
+	}
+	if status := result.MainFunc.Statements[0].Status; status != StatusFailed {
+		t.Errorf("异步步骤状态不正确: %s", status)
+	}
+	if status := result.MainFunc.Statements[1].Status; status != StatusCompleted {
+		t.Errorf("其他异步步骤应正常完成, 实际 %s", status)
+	}
+	if status := result.MainFunc.Statements[2].Status; status != StatusPending {
+		t.Errorf("step3 不应开始执行, 实际 %s", status)
+	}
+}
+
+// TestExecutor_AsyncInLoop 测试循环中的异步步骤使用启动时的循环变量，而不是执行时已更新或已删除的值
+func TestExecutor_AsyncInLoop(t *testing.T) {
+	code := `step1 = beiluo.test1.crm.list_candidates(position: string "职位") -> (candidates: []string "候选人列表", err: error "是否失败");
+step2 = beiluo.test1.crm.notify(to: string "候选人") -> (err: error "是否失败");
+
+func main() {
+    候选人列表, step1Err := step1("工程师")
+    for _, 候选人 := range 候选人列表 {
+        step2(候选人){async: true}
+    }
+}`
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"candidates": []string{"张三", "李四", "王五"}}})
+	h.On("step2", MockResult{Delay: 10 * time.Millisecond})
+	if _, err := h.Run(context.Background(), code); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	var notified []string
+	for _, call := range h.Calls()[1:] {
+		notified = append(notified, call.Input["to"].(string))
+	}
+	sort.Strings(notified)
+	if strings.Join(notified, ",") != "张三,李四,王五" {
+		t.Errorf("每次迭代应使用当次的循环变量: %v", notified)
+	}
+}
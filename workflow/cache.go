@@ -0,0 +1,222 @@
+package workflow
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StepCache 步骤结果缓存
+//
+// 步骤定义或调用设置了 cache 元数据时，执行器按函数名和实际输入参数查找缓存的结果，
+// 命中时不调用 OnFunctionCall，未命中时把成功的结果保存到缓存中。
+type StepCache interface {
+	// Get 返回未过期的结果，不存在或已过期时返回 nil
+	Get(ctx context.Context, key string) (*ExecutorOut, error)
+	// Set 保存结果，ttl 后过期
+	Set(ctx context.Context, key string, out *ExecutorOut, ttl time.Duration) error
+}
+
+// cacheEntry 缓存的步骤结果
+type cacheEntry struct {
+	Output    *ExecutorOut `json:"output"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// copyOutput 复制步骤结果，缓存与执行器互不影响
+func copyOutput(out *ExecutorOut) *ExecutorOut {
+	copied := *out
+	copied.WantOutput = copyMetadata(out.WantOutput)
+	return &copied
+}
+
+// MemoryCache 进程内的步骤结果缓存，NewExecutor 默认使用
+//
+// 超过 MaxEntries 时删除最久未使用的结果，过期的结果在读取时或每分钟一次的 Set 中删除。
+type MemoryCache struct {
+	MaxEntries int // 最多保存的结果数，0 表示10000
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // 值为 *memoryCacheItem
+	lru     *list.List               // 最近使用的在前
+	swept   time.Time                // 上一次清理过期结果的时间
+}
+
+// memoryCacheItem MemoryCache 中的一个结果
+type memoryCacheItem struct {
+	key   string
+	entry cacheEntry
+}
+
+// memoryCacheSweepInterval 清理过期结果的间隔
+const memoryCacheSweepInterval = time.Minute
+
+// NewMemoryCache 创建进程内缓存
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Get 返回未过期的结果，过期的结果被删除
+func (c *MemoryCache) Get(ctx context.Context, key string) (*ExecutorOut, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, exists := c.entries[key]
+	if !exists {
+		return nil, nil
+	}
+	item := elem.Value.(*memoryCacheItem)
+	if time.Now().After(item.entry.ExpiresAt) {
+		c.remove(elem)
+		return nil, nil
+	}
+	c.lru.MoveToFront(elem)
+	return copyOutput(item.entry.Output), nil
+}
+
+// Set 保存结果，超过 MaxEntries 时删除最久未使用的结果
+func (c *MemoryCache) Set(ctx context.Context, key string, out *ExecutorOut, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.swept) >= memoryCacheSweepInterval {
+		c.swept = now
+		for elem := c.lru.Front(); elem != nil; {
+			next := elem.Next()
+			if now.After(elem.Value.(*memoryCacheItem).entry.ExpiresAt) {
+				c.remove(elem)
+			}
+			elem = next
+		}
+	}
+
+	entry := cacheEntry{Output: copyOutput(out), ExpiresAt: now.Add(ttl)}
+	if elem, exists := c.entries[key]; exists {
+		elem.Value.(*memoryCacheItem).entry = entry
+		c.lru.MoveToFront(elem)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+
+	maxEntries := c.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	for c.lru.Len() > maxEntries {
+		c.remove(c.lru.Back())
+	}
+	return nil
+}
+
+// remove 删除一个结果，调用方持有锁
+func (c *MemoryCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*memoryCacheItem).key)
+}
+
+// FileCache 基于文件的步骤结果缓存，每个结果保存为 <dir>/<key>.json，进程重启后仍可使用
+//
+// 结果经过JSON序列化，整数返回值读取后为 float64，执行器会按步骤定义的返回值类型还原。
+type FileCache struct {
+	dir string
+}
+
+// NewFileCache 创建文件缓存，目录不存在时自动创建
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建步骤缓存目录失败: %w", err)
+	}
+	return &FileCache{dir: dir}, nil
+}
+
+// Get 返回未过期的结果，过期的缓存文件被删除
+func (c *FileCache) Get(ctx context.Context, key string) (*ExecutorOut, error) {
+	path := filepath.Join(c.dir, key+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取步骤缓存失败: %w", err)
+	}
+	entry := cacheEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("步骤缓存反序列化失败: %w", err)
+	}
+	if entry.Output == nil || time.Now().After(entry.ExpiresAt) {
+		os.Remove(path)
+		return nil, nil
+	}
+	return entry.Output, nil
+}
+
+// Set 先写临时文件再重命名，并发读取时不会读到写了一半的文件
+func (c *FileCache) Set(ctx context.Context, key string, out *ExecutorOut, ttl time.Duration) error {
+	data, err := json.Marshal(cacheEntry{Output: out, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return fmt.Errorf("步骤缓存序列化失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("保存步骤缓存失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存步骤缓存失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存步骤缓存失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, key+".json")); err != nil {
+		return fmt.Errorf("保存步骤缓存失败: %w", err)
+	}
+	return nil
+}
+
+// cacheTTL 返回 cache 元数据设置的缓存时间，支持毫秒数或 "10m"、"24h" 格式，
+// 后面的元数据覆盖前面的（语句元数据覆盖步骤定义元数据），未设置或为 false 时返回0
+func cacheTTL(metadata ...map[string]interface{}) time.Duration {
+	var ttl time.Duration
+	for _, meta := range metadata {
+		if value, exists := meta["cache"]; exists {
+			ttl, _ = metadataDuration(value)
+		}
+	}
+	return ttl
+}
+
+// stepCacheKey 按函数名、用例ID和实际输入参数计算缓存键
+func stepCacheKey(step *SimpleStep, realInput map[string]interface{}) (string, error) {
+	data, err := json.Marshal(struct {
+		Function string                 `json:"function"`
+		CaseID   string                 `json:"case_id"`
+		Input    map[string]interface{} `json:"input"`
+	}{step.Function, step.CaseID, realInput})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// stepCache 返回步骤调用的缓存键和缓存时间，不需要缓存时返回空：未设置 cache 元数据、
+// 执行器没有缓存、子工作流步骤（每次执行都是新的子流程）或输入参数无法序列化
+func (e *Executor) stepCache(stmt *SimpleStatement, step *SimpleStep, realInput map[string]interface{}) (string, time.Duration) {
+	ttl := cacheTTL(step.Metadata, stmt.Metadata)
+	if ttl <= 0 || e.Cache == nil || step.SubWorkflow != nil {
+		return "", 0
+	}
+	key, err := stepCacheKey(step, realInput)
+	if err != nil {
+		return "", 0
+	}
+	return key, ttl
+}
@@ -0,0 +1,134 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const cacheTestCode = `var input = map[string]interface{}{
+    "版本": "v1.2.0",
+}
+
+step1 = beiluo.test1.devops.build(version: string "版本") -> (buildId: int "构建编号", err: error "是否失败") {cache: "1h"};
+step2 = beiluo.test1.devops.deploy(buildId: int "构建编号") -> (err: error "是否失败");
+
+func main() {
+    构建编号, step1Err := step1(input["版本"])
+    step2(构建编号)
+}`
+
+// cacheTestRun 执行工作流，返回执行结果
+func cacheTestRun(t *testing.T, h *Harness, code string) *SimpleParseResult {
+	t.Helper()
+	result, err := h.Run(context.Background(), code)
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	return result
+}
+
+// TestExecutor_StepCache 测试相同函数和输入参数的步骤使用缓存的结果
+func TestExecutor_StepCache(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"buildId": 1001}}, MockResult{Output: map[string]interface{}{"buildId": 1002}})
+	h.On("step2", MockResult{})
+
+	cacheTestRun(t, h, cacheTestCode)
+	// 修复后续步骤后重新执行，构建步骤不再调用
+	result := cacheTestRun(t, h, cacheTestCode)
+	h.AssertPath(t, "step1", "step2", "step2")
+	h.AssertVariable(t, "构建编号", 1001)
+	if stmt := result.MainFunc.Statements[0]; !stmt.Cached || stmt.Status != StatusCompleted {
+		t.Errorf("语句应标记为使用缓存: %v %s", stmt.Cached, stmt.Status)
+	}
+	if result.MainFunc.Statements[1].Cached {
+		t.Error("未设置 cache 的步骤不应使用缓存")
+	}
+	logs := result.Steps[0].Logs
+	if len(logs) == 0 || logs[len(logs)-1].Message != "completed (cached)" {
+		t.Errorf("应记录使用缓存的日志: %+v", logs)
+	}
+
+	// 输入参数不同时不使用缓存，语句元数据可以关闭缓存
+	cacheTestRun(t, h, strings.Replace(cacheTestCode, "v1.2.0", "v1.3.0", 1))
+	cacheTestRun(t, h, strings.Replace(cacheTestCode, `step1(input["版本"])`, `step1(input["版本"]){cache: 0}`, 1))
+	h.AssertPath(t, "step1", "step2", "step2", "step1", "step2", "step1", "step2")
+
+	// 不使用缓存
+	h = NewHarness()
+	h.Executor.Cache = nil
+	h.On("step1", MockResult{Output: map[string]interface{}{"buildId": 1001}})
+	h.On("step2", MockResult{})
+	cacheTestRun(t, h, cacheTestCode)
+	cacheTestRun(t, h, cacheTestCode)
+	h.AssertPath(t, "step1", "step2", "step1", "step2")
+}
+
+// TestMemoryCache_Eviction 测试超过 MaxEntries 时删除最久未使用的结果，定期清理过期的结果
+func TestMemoryCache_Eviction(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache()
+	cache.MaxEntries = 2
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Set(ctx, key, &ExecutorOut{Success: true, Error: key}, time.Hour); err != nil {
+			t.Fatalf("保存缓存失败: %v", err)
+		}
+	}
+	// 读取 a 后 b 成为最久未使用的
+	if out, _ := cache.Get(ctx, "a"); out == nil || out.Error != "a" {
+		t.Fatalf("应命中缓存: %+v", out)
+	}
+	cache.Set(ctx, "c", &ExecutorOut{Success: true}, time.Hour)
+	if out, _ := cache.Get(ctx, "b"); out != nil {
+		t.Errorf("最久未使用的结果应被删除: %+v", out)
+	}
+	if out, _ := cache.Get(ctx, "a"); out == nil {
+		t.Error("最近使用的结果不应被删除")
+	}
+
+	// 过期后未再读取的结果在清理时删除
+	cache.MaxEntries = 10
+	cache.Set(ctx, "expired", &ExecutorOut{Success: true}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	cache.swept = time.Now().Add(-memoryCacheSweepInterval)
+	cache.Set(ctx, "d", &ExecutorOut{Success: true}, time.Hour)
+	if _, exists := cache.entries["expired"]; exists || len(cache.entries) != 3 {
+		t.Errorf("过期的结果应被清理: %d", len(cache.entries))
+	}
+}
+
+// TestFileCache 测试文件缓存在执行器之间共享，结果按返回值类型还原，过期后重新执行
+func TestFileCache(t *testing.T) {
+	cache, err := NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建文件缓存失败: %v", err)
+	}
+
+	first := NewHarness()
+	first.Executor.Cache = cache
+	first.On("step1", MockResult{Output: map[string]interface{}{"buildId": 1001}})
+	first.On("step2", MockResult{})
+	cacheTestRun(t, first, cacheTestCode)
+
+	// 模拟进程重启
+	second := NewHarness()
+	second.Executor.Cache = cache
+	second.On("step2", MockResult{})
+	cacheTestRun(t, second, cacheTestCode)
+	second.AssertPath(t, "step2")
+	second.AssertVariable(t, "构建编号", 1001)
+	if calls := second.Calls(); calls[0].Input["buildId"] != 1001 {
+		t.Errorf("缓存的结果应按类型传给后续步骤: %#v", calls[0].Input)
+	}
+
+	ctx := context.Background()
+	if err := cache.Set(ctx, "expired", &ExecutorOut{Success: true}, time.Millisecond); err != nil {
+		t.Fatalf("保存缓存失败: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if out, err := cache.Get(ctx, "expired"); err != nil || out != nil {
+		t.Errorf("过期的缓存不应返回: %+v %v", out, err)
+	}
+}
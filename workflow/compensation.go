@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 执行失败后补偿已完成步骤的工作流状态
+const (
+	StatusCompensating       StatementStatus = "compensating"        // 正在执行补偿
+	StatusCompensated        StatementStatus = "compensated"         // 执行失败，补偿全部成功
+	StatusCompensationFailed StatementStatus = "compensation_failed" // 执行失败，部分补偿失败
+)
+
+// Compensation 一个已完成步骤的补偿记录
+//
+// 步骤定义的元数据设置了 compensate 时，步骤每次执行成功都会记录一条补偿，
+// 工作流最终失败（或超时）时按完成顺序的倒序执行补偿步骤。
+type Compensation struct {
+	Step       string                 `json:"step"`        // 被补偿的步骤，如 step3
+	Compensate string                 `json:"compensate"`  // 补偿步骤，如 step8
+	LineNumber int                    `json:"line_number"` // 被补偿的调用所在行
+	Input      map[string]interface{} `json:"input"`       // 补偿步骤的输入参数
+	Status     StatementStatus        `json:"status"`      // 执行状态：pending、completed、failed
+	Error      string                 `json:"error"`       // 补偿失败的原因
+	Logs       []*StepLog             `json:"logs"`        // 补偿执行日志
+	StartTime  *time.Time             `json:"start_time"`  // 开始执行时间
+	EndTime    *time.Time             `json:"end_time"`    // 结束执行时间
+	Duration   time.Duration          `json:"duration"`    // 执行耗时
+}
+
+// GetCompensate 获取步骤定义中 {compensate: stepX} 指定的补偿步骤
+func (s *SimpleStep) GetCompensate() string {
+	if s.Metadata == nil {
+		return ""
+	}
+	name, _ := s.Metadata["compensate"].(string)
+	return name
+}
+
+// recordCompensation 步骤执行成功后记录补偿，需持有流程的执行锁
+//
+// 补偿步骤的参数按参数名（其次按参数说明）从被补偿步骤的返回值和输入参数中取值，返回值优先。
+func (e *Executor) recordCompensation(workflow *SimpleParseResult, stmt *SimpleStatement, step *SimpleStep, input, output map[string]interface{}) {
+	name := step.GetCompensate()
+	if name == "" {
+		return
+	}
+	compensation := &Compensation{
+		Step:       step.Name,
+		Compensate: name,
+		LineNumber: stmt.LineNumber,
+		Input:      make(map[string]interface{}),
+		Status:     StatusPending,
+		Logs:       make([]*StepLog, 0),
+	}
+
+	values := make(map[string]interface{})
+	for _, params := range []struct {
+		defs   []ParameterInfo
+		values map[string]interface{}
+	}{{step.InputParams, input}, {step.OutputParams, output}} {
+		for _, param := range params.defs {
+			if value, exists := params.values[param.Name]; exists {
+				values[param.Name] = value
+				if param.Desc != "" {
+					values[param.Desc] = value
+				}
+			}
+		}
+	}
+	if compensate := findStep(workflow, name); compensate != nil {
+		for _, param := range compensate.InputParams {
+			if value, exists := values[param.Name]; exists {
+				compensation.Input[param.Name] = value
+			} else if value, exists := values[param.Desc]; exists {
+				compensation.Input[param.Name] = value
+			}
+		}
+	}
+	workflow.Compensations = append(workflow.Compensations, compensation)
+}
+
+// findStep 按名称查找步骤定义
+func findStep(workflow *SimpleParseResult, name string) *SimpleStep {
+	for _, step := range workflow.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	return nil
+}
+
+// compensate 工作流失败后按倒序执行补偿，返回 compensated 或 compensation_failed
+//
+// 补偿不受工作流取消和整体超时的影响，单个补偿失败后继续执行其余补偿。
+// 恢复执行时已完成的补偿不再执行。
+func (e *Executor) compensate(ctx context.Context, workflow *SimpleParseResult) StatementStatus {
+	ctx = context.WithoutCancel(ctx)
+	workflow.Status = StatusCompensating
+	status := StatusCompensated
+	for i := len(workflow.Compensations) - 1; i >= 0; i-- {
+		compensation := workflow.Compensations[i]
+		if compensation.Status == StatusCompleted {
+			continue
+		}
+
+		now := time.Now()
+		compensation.StartTime = &now
+		compensation.Status = StatusRunning
+		_ = e.updateWorkflow(ctx, workflow)
+
+		err := e.runCompensation(ctx, workflow, compensation)
+		end := time.Now()
+		compensation.EndTime = &end
+		compensation.Duration = end.Sub(now)
+		if err != nil {
+			compensation.Status = StatusFailed
+			compensation.Error = err.Error()
+			status = StatusCompensationFailed
+		} else {
+			compensation.Status = StatusCompleted
+		}
+		_ = e.updateWorkflow(ctx, workflow)
+	}
+	return status
+}
+
+// runCompensation 执行一个补偿步骤，按补偿步骤定义的元数据超时和重试
+func (e *Executor) runCompensation(ctx context.Context, workflow *SimpleParseResult, compensation *Compensation) error {
+	step := findStep(workflow, compensation.Compensate)
+	if step == nil {
+		return fmt.Errorf("未找到补偿步骤定义: %s", compensation.Compensate)
+	}
+
+	policy := NewRetryPolicy(step.Metadata)
+	var timeout *time.Duration
+	if value, exists := step.Metadata["timeout"]; exists {
+		if d, ok := metadataDuration(value); ok {
+			timeout = &d
+		}
+	}
+	stmt := &SimpleStatement{Type: "function-call", Function: step.Name, LineNumber: compensation.LineNumber}
+
+	var lastErr error
+	for attempt := 0; attempt <= policy.MaxRetries; attempt++ {
+		call := e.OnFunctionCall
+		if step.SubWorkflow != nil {
+			childID := generateFlowID()
+			workflow.ChildFlowIDs = append(workflow.ChildFlowIDs, childID)
+			call = e.subWorkflowCall(workflow.FlowID, childID)
+		}
+		in := &ExecutorIn{
+			StepName:   step.Name,
+			StepDesc:   step.Desc,
+			RealInput:  compensation.Input,
+			WantParams: step.OutputParams,
+			Options:    &ExecutorOptions{Timeout: timeout, RetryCount: policy.MaxRetries},
+		}
+
+		var out *ExecutorOut
+		var err error
+		flowRunFromContext(ctx).unlocked(func() {
+			out, err = e.callStep(ctx, call, stmt, *step, in, timeout, attempt)
+		})
+		if err == nil && out != nil && out.Success {
+			compensation.Logs = append(compensation.Logs, &StepLog{
+				Timestamp: time.Now(),
+				Level:     "info",
+				Message:   fmt.Sprintf("补偿 %s 第%d行完成", compensation.Step, compensation.LineNumber),
+				Source:    step.Name + ".Compensate",
+				Attempt:   attempt + 1,
+			})
+			return nil
+		}
+		if err == nil {
+			message, code := "", ""
+			if out != nil {
+				message, code = out.Error, out.ErrorCode
+			}
+			err = &stepError{code: code, message: message}
+		}
+		lastErr = err
+		compensation.Logs = append(compensation.Logs, &StepLog{
+			Timestamp: time.Now(),
+			Level:     "error",
+			Message:   fmt.Sprintf("第%d次补偿失败: %s", attempt+1, failureMessage(err)),
+			Source:    step.Name + ".Compensate",
+			Attempt:   attempt + 1,
+			Error:     err.Error(),
+		})
+		if attempt == policy.MaxRetries || !policy.ShouldRetry(err) {
+			break
+		}
+		var sleepErr error
+		flowRunFromContext(ctx).unlocked(func() {
+			sleepErr = sleepContext(ctx, policy.Delay(attempt+1))
+		})
+		if sleepErr != nil {
+			return sleepErr
+		}
+	}
+	return lastErr
+}
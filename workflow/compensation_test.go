@@ -0,0 +1,149 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const compensationTestCode = `var input = map[string]interface{}{
+    "姓名": "张三",
+}
+
+step1 = beiluo.test1.hr.create_account(name: string "姓名") -> (accountId: string "账号", err: error "是否失败") {compensate: step8};
+step2 = beiluo.test1.hr.book_interview(name: string "姓名") -> (interviewId: string "面试编号", err: error "是否失败") {compensate: step9};
+step3 = beiluo.test1.hr.send_offer(name: string "姓名") -> (err: error "是否失败");
+step8 = beiluo.test1.hr.delete_account(accountId: string "账号") -> (err: error "是否失败");
+step9 = beiluo.test1.hr.cancel_interview(interviewId: string "面试编号", name: string "姓名") -> (err: error "是否失败") {retry: 1, retry_initial: 1};
+
+func main() {
+    账号, step1Err := step1(input["姓名"])
+    面试编号, step2Err := step2(input["姓名"])
+    step3Err := step3(input["姓名"])
+    sys.Println("不会执行")
+}`
+
+// compensationTestExecutor 返回记录调用顺序的执行器，failing 中的步骤执行失败
+func compensationTestExecutor(failing ...string) (*Executor, func() []string) {
+	var mu sync.Mutex
+	var calls []string
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		mu.Lock()
+		calls = append(calls, step.Name)
+		mu.Unlock()
+		// 补偿回调与步骤回调一样可以获取流程ID
+		if FlowIDFromContext(ctx) == "" {
+			return &ExecutorOut{Success: false, Error: step.Name + " 缺少流程ID"}, nil
+		}
+		for _, name := range failing {
+			if name == step.Name {
+				return &ExecutorOut{Success: false, Error: step.Name + " 失败"}, nil
+			}
+		}
+		switch step.Name {
+		case "step1":
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"accountId": "A001", "err": nil}}, nil
+		case "step2":
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"interviewId": "I001", "err": nil}}, nil
+		case "step9":
+			if in.RealInput["interviewId"] != "I001" || in.RealInput["name"] != "张三" {
+				return &ExecutorOut{Success: false, Error: "补偿参数不正确"}, nil
+			}
+		case "step8":
+			if in.RealInput["accountId"] != "A001" {
+				return &ExecutorOut{Success: false, Error: "补偿参数不正确"}, nil
+			}
+		}
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"err": nil}}, nil
+	}
+	return executor, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+// TestExecutor_Compensation 测试失败后倒序补偿已完成的步骤
+func TestExecutor_Compensation(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(compensationTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	executor, calls := compensationTestExecutor("step3")
+	var exitStatus StatementStatus
+	executor.OnWorkFlowExit = func(ctx context.Context, current *SimpleParseResult) error {
+		exitStatus = current.Status
+		return nil
+	}
+
+	err := executor.Start(context.Background(), result)
+	if err == nil || !strings.Contains(err.Error(), "step3 失败") {
+		t.Fatalf("工作流应失败: %v", err)
+	}
+	if got := strings.Join(calls(), ","); got != "step1,step2,step3,step9,step8" {
+		t.Errorf("调用顺序不正确: %s", got)
+	}
+	if result.Status != StatusCompensated || exitStatus != StatusCompensated {
+		t.Errorf("工作流状态不正确: %s, 退出回调: %s", result.Status, exitStatus)
+	}
+	if len(result.Compensations) != 2 {
+		t.Fatalf("补偿记录数量不正确: %d", len(result.Compensations))
+	}
+	for _, compensation := range result.Compensations {
+		if compensation.Status != StatusCompleted || len(compensation.Logs) != 1 || compensation.EndTime == nil {
+			t.Errorf("补偿记录不正确: %+v", compensation)
+		}
+	}
+	if status := result.MainFunc.Statements[2].Status; status != StatusFailed {
+		t.Errorf("失败步骤状态不正确: %s", status)
+	}
+}
+
+// TestExecutor_CompensationFailed 测试补偿失败后继续执行其余补偿
+func TestExecutor_CompensationFailed(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(compensationTestCode)
+	executor, calls := compensationTestExecutor("step3", "step9")
+
+	if err := executor.Start(context.Background(), result); err == nil {
+		t.Fatal("工作流应失败")
+	}
+	// step9 重试一次
+	if got := strings.Join(calls(), ","); got != "step1,step2,step3,step9,step9,step8" {
+		t.Errorf("调用顺序不正确: %s", got)
+	}
+	if result.Status != StatusCompensationFailed {
+		t.Errorf("工作流状态不正确: %s", result.Status)
+	}
+	failed, completed := result.Compensations[1], result.Compensations[0]
+	if failed.Status != StatusFailed || !strings.Contains(failed.Error, "step9 失败") || len(failed.Logs) != 2 {
+		t.Errorf("失败的补偿记录不正确: %+v", failed)
+	}
+	if completed.Status != StatusCompleted {
+		t.Errorf("补偿记录不正确: %+v", completed)
+	}
+
+	// 成功的工作流不执行补偿
+	result = NewSimpleParser().ParseWorkflow(compensationTestCode)
+	executor, calls = compensationTestExecutor()
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if got := strings.Join(calls(), ","); got != "step1,step2,step3" || result.Status != StatusCompleted {
+		t.Errorf("成功的工作流不应补偿: %s %s", got, result.Status)
+	}
+}
+
+// TestValidate_Compensate 测试补偿步骤未定义
+func TestValidate_Compensate(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(strings.Replace(compensationTestCode, "compensate: step8", "compensate: step7", 1))
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	diagnostics := Validate(result)
+	if len(diagnostics) == 0 || diagnostics[0].Line != 5 || !strings.Contains(diagnostics[0].Message, "step1 的补偿步骤未定义: step7") {
+		t.Errorf("诊断不正确: %v", diagnostics)
+	}
+}
@@ -0,0 +1,278 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const concurrencyTestCode = `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = beiluo.test1.hr.query_level(username: string "用户名") -> (level: string "职级", err: error "是否失败");
+step2 = beiluo.test1.hr.list_departments(username: string "用户名") -> (departments: []string "部门列表", err: error "是否失败");
+step3 = beiluo.test1.hr.notify(department: string "部门") -> (msgId: string "消息编号", err: error "是否失败");
+
+func main() {
+    职级, step1Err := step1(input["用户名"]){async: true}
+    部门列表, step2Err := step2(input["用户名"])
+    for i, 部门 := range 部门列表 {
+        消息编号, step3Err := step3(部门)
+    }
+    sys.Printf("职级: %s", 职级)
+}`
+
+func concurrencyTestOutput(step SimpleStep) *ExecutorOut {
+	switch step.Name {
+	case "step1":
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"level": "P6", "err": nil}}
+	case "step2":
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"departments": []string{"研发", "测试", "运维"}, "err": nil}}
+	default:
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"msgId": "M001", "err": nil}}
+	}
+}
+
+// TestExecutor_ConcurrentFlows 测试同一个执行器并发执行、停止、查询多个流程
+func TestExecutor_ConcurrentFlows(t *testing.T) {
+	const flows = 50
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return concurrencyTestOutput(step), nil
+	}
+
+	// 回调保存快照，在其他goroutine中读取
+	snapshots := make(chan *SimpleParseResult, 1024)
+	executor.OnWorkFlowUpdate = func(ctx context.Context, current *SimpleParseResult) error {
+		select {
+		case snapshots <- current:
+		default:
+		}
+		return nil
+	}
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for snapshot := range snapshots {
+			_, _ = encodeWorkflow(snapshot)
+		}
+	}()
+
+	// 执行期间不停地查询流程状态
+	stopPolling := make(chan struct{})
+	var pollers sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		pollers.Add(1)
+		go func() {
+			defer pollers.Done()
+			for {
+				select {
+				case <-stopPolling:
+					return
+				case <-time.After(time.Millisecond):
+				}
+				for _, flowID := range executor.Running() {
+					if workflow, err := executor.Get(flowID); err == nil {
+						for _, stmt := range workflow.MainFunc.Statements {
+							_ = stmt.Status
+						}
+						_, _ = workflow.GetVariable("职级")
+					}
+				}
+			}
+		}()
+	}
+
+	results := make([]*SimpleParseResult, flows)
+	errs := make([]error, flows)
+	var wg sync.WaitGroup
+	for i := 0; i < flows; i++ {
+		result := NewSimpleParser().ParseWorkflow(concurrencyTestCode)
+		if !result.Success {
+			t.Fatalf("解析失败: %s", result.Error)
+		}
+		result.FlowID = fmt.Sprintf("flow_%d", i)
+		results[i] = result
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = executor.Start(context.Background(), results[i])
+		}(i)
+	}
+
+	// 停止一部分流程，流程可能已经结束
+	for i := 0; i < flows; i += 5 {
+		_ = executor.Stop(context.Background(), fmt.Sprintf("flow_%d", i))
+	}
+
+	wg.Wait()
+	close(stopPolling)
+	pollers.Wait()
+	close(snapshots)
+	<-readerDone
+
+	if running := executor.Running(); len(running) != 0 {
+		t.Errorf("所有流程应已结束: %v", running)
+	}
+	for i, err := range errs {
+		workflow := results[i]
+		// 已结束的流程从执行器中删除，未设置 Store 时无法再获取
+		if _, getErr := executor.Get(workflow.FlowID); getErr == nil {
+			t.Errorf("流程 %d 结束后应从执行器中删除", i)
+		}
+		if err != nil {
+			if i%5 != 0 || !strings.Contains(err.Error(), "被取消") {
+				t.Errorf("流程 %d 执行失败: %v", i, err)
+			}
+			continue
+		}
+		if workflow.Status != StatusCompleted {
+			t.Errorf("流程 %d 状态不正确: %s", i, workflow.Status)
+		}
+		if len(workflow.GlobalLogs) != 1 || workflow.GlobalLogs[0].Message != "职级: P6" {
+			t.Errorf("流程 %d 日志不正确: %+v", i, workflow.GlobalLogs)
+		}
+	}
+}
+
+// TestExecutor_DuplicateFlow 测试同一流程ID不能同时运行，结束后可以再次启动
+func TestExecutor_DuplicateFlow(t *testing.T) {
+	parse := func() *SimpleParseResult {
+		result := NewSimpleParser().ParseWorkflow(concurrencyTestCode)
+		if !result.Success {
+			t.Fatalf("解析失败: %s", result.Error)
+		}
+		result.FlowID = "flow_dup"
+		return result
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		once.Do(func() { close(started) })
+		<-release
+		return concurrencyTestOutput(step), nil
+	}
+
+	first := make(chan error, 1)
+	go func() { first <- executor.Start(context.Background(), parse()) }()
+	<-started
+
+	if err := executor.Start(context.Background(), parse()); err == nil {
+		t.Error("同一流程ID运行中不应再次启动")
+	}
+	workflow, err := executor.Get("flow_dup")
+	if err != nil || workflow.Status != StatusRunning {
+		t.Errorf("运行中的流程状态不正确: %v, %v", workflow, err)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if err := executor.Stop(context.Background(), "flow_dup"); err == nil {
+		t.Error("已结束的流程不应能停止")
+	}
+	if err := executor.Start(context.Background(), parse()); err != nil {
+		t.Errorf("流程结束后应能再次启动: %v", err)
+	}
+}
+
+// TestExecutor_DuplicateStartPaused 测试再次启动正在运行（暂停中）的同一个工作流对象时不修改它的状态
+func TestExecutor_DuplicateStartPaused(t *testing.T) {
+	ctx := context.Background()
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return concurrencyTestOutput(step), nil
+	}
+	paused := make(chan Event, 1)
+	var once sync.Once
+	executor.Subscribe(func(ctx context.Context, event Event) {
+		switch event.Type {
+		case EventStatementStarted:
+			once.Do(func() { _ = executor.Pause(ctx, event.FlowID) })
+		case EventFlowPaused:
+			paused <- event
+		}
+	})
+
+	running := NewSimpleParser().ParseWorkflow(concurrencyTestCode)
+	if !running.Success {
+		t.Fatalf("解析失败: %s", running.Error)
+	}
+	done := make(chan error, 1)
+	go func() { done <- executor.Start(ctx, running) }()
+	<-paused
+
+	if err := executor.Start(ctx, running); err == nil {
+		t.Error("同一工作流运行中不应再次启动")
+	}
+	if current, err := executor.Get(running.FlowID); err != nil || current.Status != StatusPaused || current.Paused == nil {
+		t.Errorf("启动失败不应修改正在运行的流程: %v, %v", current, err)
+	}
+	if err := executor.Resume(ctx, running.FlowID); err != nil {
+		t.Fatalf("继续执行失败: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+}
+
+// TestExecutor_GetInCallback 测试在状态回调和观察者中获取流程快照，回调期间 Get 不等待执行锁
+func TestExecutor_GetInCallback(t *testing.T) {
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return concurrencyTestOutput(step), nil
+	}
+	var updates, finished int
+	executor.OnWorkFlowUpdate = func(ctx context.Context, current *SimpleParseResult) error {
+		workflow, err := executor.Get(FlowIDFromContext(ctx))
+		if err != nil || workflow.Status != current.Status {
+			t.Errorf("状态回调中获取快照不正确: %v, %v", workflow, err)
+		}
+		updates++
+		return nil
+	}
+	executor.Subscribe(func(ctx context.Context, event Event) {
+		if event.Type != EventStatementFinished {
+			return
+		}
+		// 其他goroutine在回调期间获取快照
+		got := make(chan error, 1)
+		go func() {
+			workflow, err := executor.Get(event.FlowID)
+			if err == nil && workflow.FlowID != event.FlowID {
+				err = fmt.Errorf("流程ID不正确: %s", workflow.FlowID)
+			}
+			got <- err
+		}()
+		if err := <-got; err != nil {
+			t.Errorf("观察者中获取快照失败: %v", err)
+		}
+		finished++
+	})
+
+	result := NewSimpleParser().ParseWorkflow(concurrencyTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if updates == 0 || finished == 0 {
+		t.Errorf("回调未触发: updates=%d finished=%d", updates, finished)
+	}
+}
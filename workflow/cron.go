@@ -0,0 +1,190 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 触发计划，返回 t 之后的下一次触发时间，不会再触发时返回零值
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// everySchedule 固定间隔的触发计划
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule cron 表达式的触发计划，每个字段为允许取值的位集合
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// 日和星期都不是 * 时满足其一即可，与 crontab 一致
+	domStar, dowStar bool
+}
+
+// cron 表达式的字段：名称、取值范围和别名
+var cronFields = []struct {
+	name     string
+	min, max int
+	names    []string
+}{
+	{name: "分", min: 0, max: 59},
+	{name: "时", min: 0, max: 23},
+	{name: "日", min: 1, max: 31},
+	{name: "月", min: 1, max: 12, names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "周", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// cron 表达式的预定义写法
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron 解析 cron 表达式，按触发时间所在的时区计算
+//
+// 支持5个字段 "分 时 日 月 周"，每个字段可以是 *、数字、范围 1-5、步长 */15 或 1-30/5、逗号分隔的列表，
+// 月和周可以使用英文缩写（jan、mon），周日为0或7；
+// 也支持 @yearly、@monthly、@weekly、@daily、@hourly 和固定间隔 @every 10m。
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if every, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("cron 表达式 %q 的间隔无效", expr)
+		}
+		return everySchedule(d), nil
+	}
+	if descriptor, exists := cronDescriptors[strings.ToLower(expr)]; exists {
+		expr = descriptor
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron 表达式 %q 应为5个字段（分 时 日 月 周）", expr)
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		bits, err := parseCronField(part, i)
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 的%s字段 %q 无效: %v", expr, cronFields[i].name, part, err)
+		}
+		values[i] = bits
+	}
+	s := &cronSchedule{minute: values[0], hour: values[1], dom: values[2], month: values[3], dow: values[4]}
+	// 周日可以写为0或7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = parts[2] == "*" || parts[2] == "?"
+	s.dowStar = parts[4] == "*" || parts[4] == "?"
+	return s, nil
+}
+
+// parseCronField 解析一个字段，返回允许取值的位集合
+func parseCronField(field string, index int) (uint64, error) {
+	spec := cronFields[index]
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长 %q 无效", stepPart)
+			}
+			step = n
+		}
+
+		from, to := spec.min, spec.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			lo, hi, _ := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = cronValue(lo, index); err != nil {
+				return 0, err
+			}
+			if to, err = cronValue(hi, index); err != nil {
+				return 0, err
+			}
+			if from > to {
+				return 0, fmt.Errorf("范围 %q 的起始值大于结束值", rangePart)
+			}
+		default:
+			value, err := cronValue(rangePart, index)
+			if err != nil {
+				return 0, err
+			}
+			from = value
+			if !hasStep {
+				to = value
+			}
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue 解析字段中的数字或英文缩写，检查取值范围
+func cronValue(s string, index int) (int, error) {
+	spec := cronFields[index]
+	for i, name := range spec.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q 不是数字", s)
+	}
+	if value < spec.min || value > spec.max {
+		return 0, fmt.Errorf("%d 超出范围 %d-%d", value, spec.min, spec.max)
+	}
+	return value, nil
+}
+
+// Next 返回 t 之后第一个满足表达式的整分钟，5年内没有满足的时间（如2月30日）时返回零值
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches 日和星期是否满足：其中一个为 * 时只看另一个，都不是 * 时满足其一即可
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domStar || s.dowStar:
+		return dom && dow
+	default:
+		return dom || dow
+	}
+}
@@ -0,0 +1,251 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StatusPaused 工作流已暂停，等待 Resume 或 Step
+const StatusPaused StatementStatus = "paused"
+
+// PauseReason 流程暂停的原因
+type PauseReason string
+
+const (
+	PauseRequested  PauseReason = "pause"      // 调用了 Pause
+	PauseBreakpoint PauseReason = "breakpoint" // 调试模式下语句设置了 debug: true 元数据
+	PauseStep       PauseReason = "step"       // 调用 Step 执行了一条语句
+	PauseFailed     PauseReason = "failed"     // 调试中的步骤执行失败，可以 Retry 重新执行
+)
+
+// PauseInfo 流程暂停的位置，暂停在语句执行之前（失败暂停时在语句执行之后）
+type PauseInfo struct {
+	Reason  PauseReason `json:"reason"`          // 暂停原因
+	Line    int         `json:"line"`            // 语句行号
+	Content string      `json:"content"`         // 语句内容的第一行
+	Error   string      `json:"error,omitempty"` // 失败暂停时步骤的失败原因
+	Time    time.Time   `json:"time"`            // 暂停时间
+}
+
+// debugAction 暂停中的流程收到的命令
+type debugAction int
+
+const (
+	debugContinue debugAction = iota // 继续执行，只在断点处再次暂停
+	debugStep                        // 执行下一条语句后暂停
+	debugRetry                       // 重新执行失败的步骤
+	debugSet                         // 修改变量，流程保持暂停
+)
+
+type debugCommand struct {
+	action debugAction
+	name   string
+	value  interface{}
+	done   chan error // 命令处理完成后回复，可以为nil
+}
+
+// debugState 流程的暂停和单步执行状态
+type debugState struct {
+	pauseRequested bool              // Pause 已调用，下一条语句前暂停
+	stepping       bool              // Step 执行中，下一条语句前暂停
+	paused         *PauseInfo        // 正在暂停时的位置
+	commands       chan debugCommand // 正在暂停时接收命令，否则为nil
+}
+
+// Pause 暂停流程：当前语句执行完后，在下一条语句执行前暂停，暂停期间可以修改变量，之后通过 Resume 或 Step 继续
+//
+// 暂停时工作流状态为 paused，Get 返回的快照中 Paused 为暂停的位置，Variables 为当前的变量。
+// 正在执行的异步步骤不会被暂停。
+func (e *Executor) Pause(ctx context.Context, flowID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entry, exists := e.flows[flowID]
+	if !exists {
+		return fmt.Errorf("流程 %s 未在运行中", flowID)
+	}
+	if entry.debug.paused == nil {
+		entry.debug.pauseRequested = true
+	}
+	return nil
+}
+
+// Step 暂停中的流程执行下一条语句后再次暂停；for、if 等语句执行到其中的第一条语句时暂停
+func (e *Executor) Step(ctx context.Context, flowID string) error {
+	_, err := e.command(ctx, flowID, debugCommand{action: debugStep})
+	return err
+}
+
+// Retry 重新执行因失败而暂停（PauseFailed）的步骤，可以先通过 SetVariable 修改步骤的参数
+func (e *Executor) Retry(ctx context.Context, flowID string) error {
+	_, err := e.command(ctx, flowID, debugCommand{action: debugRetry})
+	return err
+}
+
+// SetVariable 修改暂停中的流程的变量，继续执行后生效
+func (e *Executor) SetVariable(ctx context.Context, flowID, name string, value interface{}) error {
+	_, err := e.command(ctx, flowID, debugCommand{action: debugSet, name: name, value: value, done: make(chan error, 1)})
+	return err
+}
+
+// command 向正在运行的流程发送调试命令，流程不在运行中时 running 为false；
+// 命令带有 done 时等待流程处理完成
+func (e *Executor) command(ctx context.Context, flowID string, cmd debugCommand) (running bool, err error) {
+	e.mu.Lock()
+	entry, exists := e.flows[flowID]
+	if !exists {
+		e.mu.Unlock()
+		return false, fmt.Errorf("流程 %s 未在运行中", flowID)
+	}
+	state := &entry.debug
+	if state.paused == nil {
+		defer e.mu.Unlock()
+		// 尚未暂停时 Resume 取消暂停请求
+		if cmd.action == debugContinue && (state.pauseRequested || state.stepping) {
+			state.pauseRequested, state.stepping = false, false
+			return true, nil
+		}
+		return true, fmt.Errorf("流程 %s 未暂停", flowID)
+	}
+	if cmd.action == debugRetry && state.paused.Reason != PauseFailed {
+		e.mu.Unlock()
+		return true, fmt.Errorf("流程 %s 没有失败的步骤可以重试", flowID)
+	}
+	select {
+	case state.commands <- cmd:
+	default:
+		e.mu.Unlock()
+		return true, fmt.Errorf("流程 %s 的上一个命令尚未处理", flowID)
+	}
+	e.mu.Unlock()
+
+	if cmd.done == nil {
+		return true, nil
+	}
+	select {
+	case err := <-cmd.done:
+		return true, err
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
+
+// checkpoint 在语句执行前检查暂停请求、单步执行和断点，需要时暂停直到收到继续执行的命令
+func (e *Executor) checkpoint(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
+	e.mu.Lock()
+	var reason PauseReason
+	if entry, exists := e.flows[workflow.FlowID]; exists {
+		switch {
+		case entry.debug.pauseRequested:
+			reason = PauseRequested
+		case entry.debug.stepping:
+			reason = PauseStep
+		case e.Debug && stmt.IsDebug():
+			reason = PauseBreakpoint
+		}
+	}
+	e.mu.Unlock()
+	if reason == "" {
+		return nil
+	}
+	_, err := e.pause(ctx, stmt, workflow, &PauseInfo{Reason: reason})
+	return err
+}
+
+// retryFailed 调试中（单步执行或断点语句）的步骤失败时暂停，返回是否需要重新执行该步骤；
+// 暂停期间流程被取消时返回取消的错误
+func (e *Executor) retryFailed(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult, stepErr error) (bool, error) {
+	if stmt.Type != "function-call" || isControlFlow(stepErr) || ctx.Err() != nil {
+		return false, nil
+	}
+	e.mu.RLock()
+	entry, exists := e.flows[workflow.FlowID]
+	debugging := exists && (entry.debug.stepping || e.Debug && stmt.IsDebug())
+	e.mu.RUnlock()
+	if !debugging {
+		return false, nil
+	}
+
+	action, err := e.pause(ctx, stmt, workflow, &PauseInfo{Reason: PauseFailed, Error: failureMessage(stepErr)})
+	if err != nil || action != debugRetry {
+		return false, err
+	}
+	stmt.StartExecution()
+	return true, nil
+}
+
+// pause 暂停流程直到收到继续执行、单步执行或重试的命令，暂停期间释放运行时锁并处理修改变量的命令
+func (e *Executor) pause(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult, info *PauseInfo) (debugAction, error) {
+	info.Line = stmt.LineNumber
+	info.Content = firstLine(stmt.Content)
+	info.Time = time.Now()
+	commands := make(chan debugCommand, 1)
+
+	e.mu.Lock()
+	entry, exists := e.flows[workflow.FlowID]
+	if !exists {
+		e.mu.Unlock()
+		return debugContinue, nil
+	}
+	entry.debug.pauseRequested, entry.debug.stepping = false, false
+	entry.debug.paused = info
+	entry.debug.commands = commands
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		entry.debug.paused = nil
+		entry.debug.commands = nil
+		e.mu.Unlock()
+		// 流程取消后尚未处理的命令返回错误
+		select {
+		case cmd := <-commands:
+			if cmd.done != nil {
+				cmd.done <- fmt.Errorf("流程 %s 已结束暂停", workflow.FlowID)
+			}
+		default:
+		}
+	}()
+
+	status := workflow.Status
+	workflow.Status = StatusPaused
+	workflow.Paused = info
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return debugContinue, err
+	}
+	e.emit(ctx, workflow, Event{Type: EventFlowPaused, Line: info.Line, StatementType: stmt.Type, Content: info.Content, Error: info.Error})
+
+	for {
+		var cmd debugCommand
+		received := false
+		flowRunFromContext(ctx).unlocked(func() {
+			select {
+			case cmd = <-commands:
+				received = true
+			case <-ctx.Done():
+			}
+		})
+		if !received {
+			workflow.Status = status
+			workflow.Paused = nil
+			err := interruptedError(ctx, stmt, "暂停")
+			stmt.EndExecution()
+			_ = e.updateWorkflow(ctx, workflow)
+			return debugContinue, err
+		}
+
+		if cmd.action == debugSet {
+			info := VariableInfo{Name: cmd.name, Type: typeName(cmd.value), Value: cmd.value, Source: "debug", LineNum: stmt.LineNumber}
+			e.setVariable(ctx, workflow, info)
+			cmd.done <- e.updateWorkflow(ctx, workflow)
+			continue
+		}
+
+		e.mu.Lock()
+		entry.debug.stepping = cmd.action == debugStep
+		e.mu.Unlock()
+		workflow.Status = status
+		workflow.Paused = nil
+		e.emit(ctx, workflow, Event{Type: EventFlowResumed, Line: info.Line, StatementType: stmt.Type, Content: info.Content})
+		return cmd.action, e.updateWorkflow(ctx, workflow)
+	}
+}
@@ -0,0 +1,175 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// debugTestRun 在后台执行工作流，返回暂停事件和执行结果
+func debugTestRun(h *Harness, code string) (<-chan Event, <-chan error) {
+	paused := make(chan Event, 10)
+	h.Executor.Debug = true
+	h.Executor.Subscribe(func(ctx context.Context, event Event) {
+		if event.Type == EventFlowPaused {
+			paused <- event
+		}
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.Run(context.Background(), code)
+		done <- err
+	}()
+	return paused, done
+}
+
+// TestExecutor_PauseResume 测试暂停后修改变量并继续执行
+func TestExecutor_PauseResume(t *testing.T) {
+	ctx := context.Background()
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"department": "研发"}})
+	h.On("step2", MockResult{Output: map[string]interface{}{"profileId": "P001"}})
+	// step1 开始执行时请求暂停，step1 执行完后在 step2 之前暂停
+	h.Executor.Subscribe(func(ctx context.Context, event Event) {
+		if event.Type == EventStatementStarted && event.Line == 9 {
+			if err := h.Executor.Pause(ctx, event.FlowID); err != nil {
+				t.Errorf("暂停失败: %v", err)
+			}
+		}
+	})
+	paused, done := debugTestRun(h, storeTestCode)
+
+	event := <-paused
+	if event.Line != 10 {
+		t.Errorf("暂停位置不正确: %d", event.Line)
+	}
+	snapshot, err := h.Executor.Get(event.FlowID)
+	if err != nil {
+		t.Fatalf("获取快照失败: %v", err)
+	}
+	if snapshot.Status != StatusPaused || snapshot.Paused == nil || snapshot.Paused.Reason != PauseRequested || snapshot.Paused.Line != 10 {
+		t.Errorf("暂停状态不正确: %s %+v", snapshot.Status, snapshot.Paused)
+	}
+	if variable, _ := snapshot.GetVariable("部门"); variable.Value != "研发" {
+		t.Errorf("暂停时的变量不正确: %v", variable.Value)
+	}
+	if err := h.Executor.Retry(ctx, event.FlowID); err == nil {
+		t.Error("没有失败的步骤时重试应失败")
+	}
+
+	if err := h.Executor.SetVariable(ctx, event.FlowID, "部门", "财务"); err != nil {
+		t.Fatalf("修改变量失败: %v", err)
+	}
+	if err := h.Executor.Resume(ctx, event.FlowID); err != nil {
+		t.Fatalf("继续执行失败: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	calls := h.Calls()
+	if len(calls) != 2 || calls[1].Input["department"] != "财务" {
+		t.Errorf("修改的变量未传给后续步骤: %+v", calls)
+	}
+	result := h.lastResult(t)
+	if result.Status != StatusCompleted || result.Paused != nil {
+		t.Errorf("流程状态不正确: %s %+v", result.Status, result.Paused)
+	}
+	if err := h.Executor.Pause(ctx, result.FlowID); err == nil {
+		t.Error("流程结束后暂停应失败")
+	}
+}
+
+// TestExecutor_BreakpointStep 测试 debug 断点和单步执行
+func TestExecutor_BreakpointStep(t *testing.T) {
+	ctx := context.Background()
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"department": "研发"}})
+	h.On("step2", MockResult{Output: map[string]interface{}{"profileId": "P001"}})
+	code := strings.Replace(storeTestCode, `step2(部门)`, `step2(部门){debug: true}`, 1)
+	paused, done := debugTestRun(h, code)
+
+	event := <-paused
+	if event.Line != 10 || len(h.Calls()) != 1 {
+		t.Fatalf("应在 step2 之前暂停: %d %v", event.Line, h.Path())
+	}
+	if snapshot, _ := h.Executor.Get(event.FlowID); snapshot.Paused.Reason != PauseBreakpoint {
+		t.Errorf("暂停原因不正确: %s", snapshot.Paused.Reason)
+	}
+	if err := h.Executor.Step(ctx, event.FlowID); err != nil {
+		t.Fatalf("单步执行失败: %v", err)
+	}
+
+	event = <-paused
+	if event.Line != 11 || len(h.Calls()) != 2 {
+		t.Fatalf("应在 step2 之后暂停: %d %v", event.Line, h.Path())
+	}
+	if snapshot, _ := h.Executor.Get(event.FlowID); snapshot.Paused.Reason != PauseStep || snapshot.MainFunc.Statements[1].Status != StatusCompleted {
+		t.Errorf("单步执行状态不正确: %+v %s", snapshot.Paused, snapshot.MainFunc.Statements[1].Status)
+	}
+	if err := h.Executor.Resume(ctx, event.FlowID); err != nil {
+		t.Fatalf("继续执行失败: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if len(paused) != 0 {
+		t.Errorf("继续执行后不应再暂停: %+v", <-paused)
+	}
+}
+
+// TestExecutor_DebugRetry 测试调试中的步骤失败后暂停，修改参数后重新执行
+func TestExecutor_DebugRetry(t *testing.T) {
+	ctx := context.Background()
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"department": "研发"}})
+	h.On("step2", MockResult{Error: "部门不存在"}, MockResult{Output: map[string]interface{}{"profileId": "P001"}})
+	code := strings.Replace(storeTestCode, `step2(部门)`, `step2(部门){debug: true}`, 1)
+	paused, done := debugTestRun(h, code)
+
+	event := <-paused
+	if err := h.Executor.Resume(ctx, event.FlowID); err != nil {
+		t.Fatalf("继续执行失败: %v", err)
+	}
+	event = <-paused
+	if event.Line != 10 || event.Error != "部门不存在" {
+		t.Fatalf("step2 失败后应暂停: %+v", event)
+	}
+	if snapshot, _ := h.Executor.Get(event.FlowID); snapshot.Paused.Reason != PauseFailed {
+		t.Errorf("暂停原因不正确: %s", snapshot.Paused.Reason)
+	}
+	if err := h.Executor.SetVariable(ctx, event.FlowID, "部门", "财务"); err != nil {
+		t.Fatalf("修改变量失败: %v", err)
+	}
+	if err := h.Executor.Retry(ctx, event.FlowID); err != nil {
+		t.Fatalf("重试失败: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	h.AssertPath(t, "step1", "step2", "step2")
+	if calls := h.Calls(); calls[2].Input["department"] != "财务" {
+		t.Errorf("重试时应使用修改后的参数: %v", calls[2].Input)
+	}
+	h.AssertStatus(t, 10, StatusCompleted)
+	h.AssertVariable(t, "档案编号", "P001")
+}
+
+// TestExecutor_StopPaused 测试停止暂停中的流程
+func TestExecutor_StopPaused(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"department": "研发"}})
+	code := strings.Replace(storeTestCode, `step2(部门)`, `step2(部门){debug: true}`, 1)
+	paused, done := debugTestRun(h, code)
+
+	event := <-paused
+	if err := h.Executor.Stop(context.Background(), event.FlowID); err != nil {
+		t.Fatalf("停止失败: %v", err)
+	}
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "被取消") {
+		t.Fatalf("应返回取消错误: %v", err)
+	}
+	result := h.lastResult(t)
+	if result.Status != "cancelled" || result.MainFunc.Statements[1].Status != "cancelled" {
+		t.Errorf("状态不正确: %s %s", result.Status, result.MainFunc.Statements[1].Status)
+	}
+}
@@ -0,0 +1,339 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DiagramOptions 流程图的渲染选项
+type DiagramOptions struct {
+	Status   bool // 按语句的执行状态着色，并在节点中显示状态
+	Duration bool // 在节点中显示执行耗时
+}
+
+// 流程图节点形状
+const (
+	shapeTerminal  = "terminal"  // 开始、结束
+	shapeStep      = "step"      // 步骤调用
+	shapeDecision  = "decision"  // if 条件
+	shapeLoop      = "loop"      // for 循环
+	shapeWait      = "wait"      // 等待信号
+	shapeStatement = "statement" // 打印、变量赋值等其他语句
+)
+
+// 节点状态对应的颜色：填充色、边框色
+var diagramColors = map[StatementStatus][2]string{
+	StatusRunning:   {"#cce5ff", "#004085"},
+	StatusCompleted: {"#d4edda", "#28a745"},
+	StatusFailed:    {"#f8d7da", "#dc3545"},
+	StatusSkipped:   {"#f8f9fa", "#adb5bd"},
+	StatusTimeout:   {"#ffe5b4", "#fd7e14"},
+	StatusWaiting:   {"#fff3cd", "#ffc107"},
+	"cancelled":     {"#e2e3e5", "#6c757d"},
+}
+
+type diagramNode struct {
+	id     string
+	shape  string
+	label  string
+	status StatementStatus
+}
+
+type diagramEdge struct {
+	from  string
+	to    string
+	label string
+	data  bool // 数据依赖：变量从产生它的语句流向使用它的步骤参数
+}
+
+// diagramExit 语句的出口，连接到下一个语句时带上边的标签（如 if 的 是/否）
+type diagramExit struct {
+	id    string
+	label string
+}
+
+// diagramLoop 正在构建的 for 循环，break 的出口在循环结束后连接到下一个语句
+type diagramLoop struct {
+	id     string
+	breaks []diagramExit
+}
+
+// diagram 工作流的流程图，ToMermaid 和 ToDOT 共用同一个图结构
+type diagram struct {
+	opts      DiagramOptions
+	nodes     []*diagramNode
+	edges     []*diagramEdge
+	loops     []*diagramLoop
+	producers map[string]string // 变量名 -> 最近一次赋值的语句节点
+}
+
+// ToMermaid 把主函数渲染为 Mermaid 流程图（flowchart TD）
+//
+// 实线为执行顺序，虚线为数据依赖（变量从哪个语句流向哪个步骤的参数）。
+// 执行过的工作流可以设置 opts 按状态着色并显示耗时，用于展示当前的执行进度。
+func (r *SimpleParseResult) ToMermaid(opts DiagramOptions) string {
+	g := buildDiagram(r, opts)
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for _, node := range g.nodes {
+		label := mermaidEscape(g.label(node))
+		switch node.shape {
+		case shapeTerminal:
+			fmt.Fprintf(&b, "    %s([\"%s\"])\n", node.id, label)
+		case shapeStep:
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", node.id, label)
+		case shapeDecision:
+			fmt.Fprintf(&b, "    %s{\"%s\"}\n", node.id, label)
+		case shapeLoop:
+			fmt.Fprintf(&b, "    %s{{\"%s\"}}\n", node.id, label)
+		case shapeWait:
+			fmt.Fprintf(&b, "    %s[/\"%s\"/]\n", node.id, label)
+		default:
+			fmt.Fprintf(&b, "    %s(\"%s\")\n", node.id, label)
+		}
+	}
+	for _, edge := range g.edges {
+		arrow := "-->"
+		if edge.data {
+			arrow = "-.->"
+		}
+		if edge.label != "" {
+			fmt.Fprintf(&b, "    %s %s|\"%s\"| %s\n", edge.from, arrow, mermaidEscape(edge.label), edge.to)
+		} else {
+			fmt.Fprintf(&b, "    %s %s %s\n", edge.from, arrow, edge.to)
+		}
+	}
+
+	if opts.Status {
+		for _, status := range g.statuses() {
+			color := diagramColors[status]
+			fmt.Fprintf(&b, "    classDef %s fill:%s,stroke:%s\n", status, color[0], color[1])
+		}
+		for _, node := range g.nodes {
+			if _, exists := diagramColors[node.status]; exists {
+				fmt.Fprintf(&b, "    class %s %s\n", node.id, node.status)
+			}
+		}
+	}
+	return b.String()
+}
+
+// ToDOT 把主函数渲染为 Graphviz DOT 格式的流程图，内容与 ToMermaid 相同
+func (r *SimpleParseResult) ToDOT(opts DiagramOptions) string {
+	g := buildDiagram(r, opts)
+	var b strings.Builder
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("    node [fontname=\"sans-serif\"];\n")
+	for _, node := range g.nodes {
+		attrs := []string{fmt.Sprintf("label=\"%s\"", dotEscape(g.label(node)))}
+		switch node.shape {
+		case shapeTerminal:
+			attrs = append(attrs, "shape=oval")
+		case shapeStep:
+			attrs = append(attrs, "shape=box")
+		case shapeDecision:
+			attrs = append(attrs, "shape=diamond")
+		case shapeLoop:
+			attrs = append(attrs, "shape=hexagon")
+		case shapeWait:
+			attrs = append(attrs, "shape=parallelogram")
+		default:
+			attrs = append(attrs, "shape=box", "style=rounded")
+		}
+		if color, exists := diagramColors[node.status]; exists && opts.Status {
+			if node.shape == shapeStatement {
+				attrs[len(attrs)-1] = "style=\"rounded,filled\""
+			} else {
+				attrs = append(attrs, "style=filled")
+			}
+			attrs = append(attrs, fmt.Sprintf("fillcolor=\"%s\"", color[0]), fmt.Sprintf("color=\"%s\"", color[1]))
+		}
+		fmt.Fprintf(&b, "    %s [%s];\n", node.id, strings.Join(attrs, ", "))
+	}
+	for _, edge := range g.edges {
+		var attrs []string
+		if edge.label != "" {
+			attrs = append(attrs, fmt.Sprintf("label=\"%s\"", dotEscape(edge.label)))
+		}
+		if edge.data {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, "    %s -> %s [%s];\n", edge.from, edge.to, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&b, "    %s -> %s;\n", edge.from, edge.to)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// buildDiagram 从主函数的语句构建流程图
+func buildDiagram(r *SimpleParseResult, opts DiagramOptions) *diagram {
+	g := &diagram{opts: opts, producers: make(map[string]string)}
+	// end 是 Mermaid 的关键字，不能作为节点ID
+	g.nodes = append(g.nodes, &diagramNode{id: "begin", shape: shapeTerminal, label: "开始"})
+	exits := []diagramExit{{id: "begin"}}
+	if r.MainFunc != nil {
+		exits = g.sequence(r.MainFunc.Statements, exits)
+	}
+	g.nodes = append(g.nodes, &diagramNode{id: "finish", shape: shapeTerminal, label: "结束", status: r.Status})
+	g.connect(exits, "finish")
+	return g
+}
+
+// sequence 依次连接语句，prev 为前一个语句的出口，返回最后一个语句的出口
+func (g *diagram) sequence(statements []*SimpleStatement, prev []diagramExit) []diagramExit {
+	for _, stmt := range statements {
+		prev = g.statement(stmt, prev)
+	}
+	return prev
+}
+
+// statement 添加语句的节点和边，返回语句的出口；return、break、continue 没有出口
+func (g *diagram) statement(stmt *SimpleStatement, prev []diagramExit) []diagramExit {
+	switch stmt.Type {
+	case "if":
+		id := g.add(stmt, shapeDecision, stmt.Condition)
+		g.connect(prev, id)
+		exits := g.sequence(stmt.Children, []diagramExit{{id: id, label: "是"}})
+		otherwise := []diagramExit{{id: id, label: "否"}}
+		for _, branch := range stmt.Branches {
+			if branch.Condition == "" {
+				exits = append(exits, g.sequence(branch.Children, otherwise)...)
+				otherwise = nil
+				break
+			}
+			branchID := g.add(branch, shapeDecision, branch.Condition)
+			g.connect(otherwise, branchID)
+			exits = append(exits, g.sequence(branch.Children, []diagramExit{{id: branchID, label: "是"}})...)
+			otherwise = []diagramExit{{id: branchID, label: "否"}}
+		}
+		return append(exits, otherwise...)
+	case "for":
+		id := g.add(stmt, shapeLoop, strings.TrimSpace(strings.TrimSuffix(firstLine(stmt.Content), "{")))
+		g.connect(prev, id)
+		loop := &diagramLoop{id: id}
+		g.loops = append(g.loops, loop)
+		// 执行过的循环显示最后一次迭代的状态
+		body := stmt.Children
+		if stmt.Loop != nil && len(stmt.Loop.Iterations) > 0 {
+			body = stmt.Loop.Iterations[len(stmt.Loop.Iterations)-1].Statements
+		}
+		g.connect(g.sequence(body, []diagramExit{{id: id, label: "循环"}}), id)
+		g.loops = g.loops[:len(g.loops)-1]
+		return append([]diagramExit{{id: id, label: "结束"}}, loop.breaks...)
+	case "return":
+		g.connect(prev, "finish")
+		return nil
+	case "break", "continue":
+		if len(g.loops) == 0 {
+			return prev
+		}
+		loop := g.loops[len(g.loops)-1]
+		if stmt.Type == "break" {
+			loop.breaks = append(loop.breaks, prev...)
+		} else {
+			g.connect(prev, loop.id)
+		}
+		return nil
+	case "function-call":
+		id := g.add(stmt, shapeStep, firstLine(stmt.Content))
+		g.connect(prev, id)
+		for _, arg := range stmt.Args {
+			if !arg.IsVariable || arg.IsInput {
+				continue
+			}
+			if from, exists := g.producers[arg.Value]; exists {
+				g.edges = append(g.edges, &diagramEdge{from: from, to: id, label: arg.Value, data: true})
+			}
+		}
+		for _, ret := range stmt.Returns {
+			if ret.Value != "" && ret.Value != "_" {
+				g.producers[ret.Value] = id
+			}
+		}
+		return []diagramExit{{id: id}}
+	case "wait":
+		id := g.add(stmt, shapeWait, firstLine(stmt.Content))
+		g.connect(prev, id)
+		for _, name := range stmt.Wait.Vars {
+			g.producers[name] = id
+		}
+		return []diagramExit{{id: id}}
+	default:
+		id := g.add(stmt, shapeStatement, firstLine(stmt.Content))
+		g.connect(prev, id)
+		if stmt.Type == "var" {
+			if name, _, ok := strings.Cut(stmt.Content, ":="); ok {
+				g.producers[strings.TrimSpace(name)] = id
+			}
+		}
+		return []diagramExit{{id: id}}
+	}
+}
+
+// add 添加语句对应的节点，返回节点ID
+func (g *diagram) add(stmt *SimpleStatement, shape, label string) string {
+	node := &diagramNode{
+		id:     fmt.Sprintf("s%d", len(g.nodes)),
+		shape:  shape,
+		label:  label,
+		status: stmt.Status,
+	}
+	if g.opts.Duration && stmt.Duration > 0 {
+		// 不足1毫秒的耗时保留到微秒
+		precision := time.Millisecond
+		if stmt.Duration < time.Millisecond {
+			precision = time.Microsecond
+		}
+		node.label += "\n" + stmt.Duration.Round(precision).String()
+	}
+	g.nodes = append(g.nodes, node)
+	return node.id
+}
+
+// connect 把前一个语句的出口连接到节点 to
+func (g *diagram) connect(prev []diagramExit, to string) {
+	for _, exit := range prev {
+		g.edges = append(g.edges, &diagramEdge{from: exit.id, to: to, label: exit.label})
+	}
+}
+
+// label 节点显示的文字，按状态着色时附带非 pending 的状态
+func (g *diagram) label(node *diagramNode) string {
+	if g.opts.Status && node.status != "" && node.status != StatusPending {
+		return node.label + "\n" + string(node.status)
+	}
+	return node.label
+}
+
+// statuses 图中出现的需要着色的状态，按节点顺序去重
+func (g *diagram) statuses() []StatementStatus {
+	var statuses []StatementStatus
+	seen := make(map[StatementStatus]bool)
+	for _, node := range g.nodes {
+		if _, exists := diagramColors[node.status]; exists && !seen[node.status] {
+			seen[node.status] = true
+			statuses = append(statuses, node.status)
+		}
+	}
+	return statuses
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(line)
+}
+
+// mermaidEscape 转义 Mermaid 标签中的引号和尖括号，换行转为 <br/>
+func mermaidEscape(s string) string {
+	s = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "|", "#124;").Replace(s)
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
+
+// dotEscape 转义 DOT 字符串中的反斜杠、引号和换行
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,79 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestToMermaid 测试执行顺序、if 分支和数据依赖的渲染
+func TestToMermaid(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(waitTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	mermaid := result.ToMermaid(DiagramOptions{})
+	for _, want := range []string{
+		"flowchart TD\n",
+		`    s1["请假单号, step1Err := step1(input[#quot;姓名#quot;])"]`,
+		`    s2{"step1Err != nil"}`,
+		`    s3[/"审批意见 := wait(#quot;manager_approval#quot;)"/]`,
+		"    s2 -->|\"是\"| finish\n",
+		"    s2 -->|\"否\"| s3\n",
+		"    s1 -.->|\"请假单号\"| s4\n",
+		"    s3 -.->|\"审批意见\"| s4\n",
+		"    s6 --> finish\n",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid 缺少 %q:\n%s", want, mermaid)
+		}
+	}
+	if strings.Contains(mermaid, "classDef") {
+		t.Errorf("未设置 Status 时不应着色:\n%s", mermaid)
+	}
+}
+
+// TestToDOT_Status 测试执行后按状态着色并显示耗时
+func TestToDOT_Status(t *testing.T) {
+	code := strings.Replace(storeTestCode, `档案编号, step2Err := step2(部门)`, `for i := range 2 {
+        if i == 1 {
+            break
+        }
+        档案编号, step2Err := step2(部门)
+    }`, 1)
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"department": "研发", "profileId": "P001", "err": nil}}, nil
+	}
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	result.MainFunc.Statements[0].Duration = 1234567 * time.Nanosecond
+	dot := result.ToDOT(DiagramOptions{Status: true, Duration: true})
+	for _, want := range []string{
+		"digraph workflow {\n",
+		`    s1 [label="部门, step1Err := step1(input[\"用户名\"]){retry: 2, retry_on: [timeout, \"E500\"]}\n1ms\ncompleted", shape=box, style=filled, fillcolor="#d4edda", color="#28a745"];`,
+		`    s2 [label="for i := range 2\n`,
+		// 最后一次迭代在 break 处结束，循环体中的步骤被跳过
+		`    s4 [label="档案编号, step2Err := step2(部门)\nskipped", shape=box, style=filled, fillcolor="#f8f9fa"`,
+		"    s2 -> s3 [label=\"循环\"];\n",
+		// break 跳出循环连接到循环后的语句
+		"    s3 -> s5 [label=\"是\"];\n",
+		"    s4 -> s2;\n",
+		"    s1 -> s4 [label=\"部门\", style=dashed];\n",
+		"    finish [label=\"结束\\ncompleted\", shape=oval",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT 缺少 %q:\n%s", want, dot)
+		}
+	}
+	if !strings.Contains(result.ToMermaid(DiagramOptions{Status: true}), "    class s1 completed\n") {
+		t.Errorf("Mermaid 应按状态着色:\n%s", result.ToMermaid(DiagramOptions{Status: true}))
+	}
+}
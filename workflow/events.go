@@ -0,0 +1,135 @@
+package workflow
+
+import (
+	"context"
+	"time"
+)
+
+// EventType 执行事件类型
+type EventType string
+
+const (
+	EventFlowStarted       EventType = "flow_started"        // 流程开始或恢复执行
+	EventStatementStarted  EventType = "statement_started"   // 语句开始执行
+	EventStepAttemptFailed EventType = "step_attempt_failed" // 步骤的一次尝试失败或超时，Retry 表示是否还会重试
+	EventVariableSet       EventType = "variable_set"        // 步骤返回值、var、range、wait 给变量赋值
+	EventStatementFinished EventType = "statement_finished"  // 语句执行结束，Status 为结束时的状态
+	EventFlowFinished      EventType = "flow_finished"       // 流程执行结束，Status 为流程的最终状态
+	EventFlowPaused        EventType = "flow_paused"         // 流程暂停，Line 为暂停的语句，失败暂停时 Error 为失败原因
+	EventFlowResumed       EventType = "flow_resumed"        // 暂停的流程继续执行
+)
+
+// Event 工作流执行过程中的事件，字段按事件类型填写
+type Event struct {
+	Seq    uint64    `json:"seq"`     // 流程内递增的序号，从1开始，Resume 后从保存时的序号继续
+	Type   EventType `json:"type"`    // 事件类型
+	FlowID string    `json:"flow_id"` // 流程ID，子工作流的事件为子流程ID
+	Time   time.Time `json:"time"`    // 事件发生时间
+
+	// 语句事件和步骤尝试失败事件
+	Line          int    `json:"line,omitempty"`           // 语句行号
+	StatementType string `json:"statement_type,omitempty"` // 语句类型，如 function-call、if、for
+	Content       string `json:"content,omitempty"`        // 语句内容的第一行
+	Step          string `json:"step,omitempty"`           // 调用的步骤名，如 step1
+
+	// 结束事件和步骤尝试失败事件
+	Status    StatementStatus `json:"status,omitempty"`     // 语句或流程结束时的状态，尝试失败时为 failed 或 timeout
+	Duration  time.Duration   `json:"duration,omitempty"`   // 语句或流程的执行耗时
+	Error     string          `json:"error,omitempty"`      // 失败原因
+	ErrorCode string          `json:"error_code,omitempty"` // 业务错误码
+	Attempt   int             `json:"attempt,omitempty"`    // 失败的是第几次尝试，从1开始
+	Retry     bool            `json:"retry,omitempty"`      // 失败后是否还会重试
+
+	// 变量赋值事件
+	Variable *VariableInfo `json:"variable,omitempty"`
+}
+
+// Observer 执行事件的观察者
+//
+// 同一流程的事件按序号依次同步通知，观察者返回前流程不会继续执行，耗时的处理（如写数据库）应转发到其他goroutine。
+type Observer func(ctx context.Context, event Event)
+
+// observerEntry 已订阅的观察者
+type observerEntry struct {
+	id       int
+	observer Observer
+}
+
+// Subscribe 订阅所有流程的执行事件，返回取消订阅的函数
+//
+// 可以订阅多个观察者，按订阅顺序通知，用于审计日志、进度展示和监控指标等，不需要比较 OnWorkFlowUpdate 的快照。
+func (e *Executor) Subscribe(observer Observer) (unsubscribe func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nextObserver++
+	id := e.nextObserver
+	e.observers = append(e.observers, observerEntry{id: id, observer: observer})
+
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for i, entry := range e.observers {
+			if entry.id == id {
+				e.observers = append(e.observers[:i:i], e.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// emit 为事件分配序号并通知所有观察者，需持有流程的执行锁或在流程的执行goroutine中调用
+func (e *Executor) emit(ctx context.Context, workflow *SimpleParseResult, event Event) {
+	workflow.EventSeq++
+	e.mu.RLock()
+	observers := e.observers
+	e.mu.RUnlock()
+	if len(observers) == 0 {
+		return
+	}
+
+	event.Seq = workflow.EventSeq
+	event.FlowID = workflow.FlowID
+	event.Time = time.Now()
+	flowRunFromContext(ctx).callback(func() {
+		for _, entry := range observers {
+			entry.observer(ctx, event)
+		}
+	})
+}
+
+// emitStatement 发布语句开始或结束事件，err 为语句的执行结果
+func (e *Executor) emitStatement(ctx context.Context, workflow *SimpleParseResult, eventType EventType, stmt *SimpleStatement, err error) {
+	event := Event{
+		Type:          eventType,
+		Line:          stmt.LineNumber,
+		StatementType: stmt.Type,
+		Content:       firstLine(stmt.Content),
+	}
+	if stmt.Type == "function-call" {
+		event.Step = stmt.Function
+	}
+	if eventType == EventStatementFinished {
+		// 失败的语句可能在返回后才由上层标记为 failed，这里按返回的错误得到结束时的状态
+		event.Status = stmt.Status
+		switch {
+		case isControlFlow(err):
+			event.Status = StatusCompleted
+		case err != nil:
+			if stmt.Status == StatusRunning {
+				event.Status = StatusFailed
+			}
+			event.Error = failureMessage(err)
+			event.ErrorCode = errorCode(err)
+		}
+		if stmt.StartTime != nil {
+			event.Duration = time.Since(*stmt.StartTime)
+		}
+	}
+	e.emit(ctx, workflow, event)
+}
+
+// setVariable 给变量赋值并发布变量赋值事件
+func (e *Executor) setVariable(ctx context.Context, workflow *SimpleParseResult, info VariableInfo) {
+	workflow.SetVariable(info.Name, info)
+	e.emit(ctx, workflow, Event{Type: EventVariableSet, Line: info.LineNum, Variable: &info})
+}
@@ -0,0 +1,97 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestExecutor_Subscribe 测试执行事件的类型、顺序和序号，以及多个观察者和取消订阅
+func TestExecutor_Subscribe(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{ErrorCode: "E500", Error: "服务繁忙"}, MockResult{Output: map[string]interface{}{"department": "研发"}})
+	h.On("step2", MockResult{Output: map[string]interface{}{"profileId": "P001"}})
+
+	var mu sync.Mutex
+	var events []Event
+	var counts int
+	unsubscribe := h.Executor.Subscribe(func(ctx context.Context, event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	h.Executor.Subscribe(func(ctx context.Context, event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts++
+	})
+
+	code := strings.Replace(storeTestCode, `retry: 2,`, `retry: 2, retry_initial: 1,`, 1)
+	result, err := h.Run(context.Background(), code)
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	var got []string
+	for i, event := range events {
+		if event.Seq != uint64(i+1) || event.FlowID != result.FlowID {
+			t.Errorf("第%d个事件的序号或流程ID不正确: %+v", i+1, event)
+		}
+		desc := fmt.Sprintf("%s:%d", event.Type, event.Line)
+		switch event.Type {
+		case EventStepAttemptFailed:
+			desc += fmt.Sprintf(":%s:%d:%s:%v", event.Step, event.Attempt, event.ErrorCode, event.Retry)
+		case EventVariableSet:
+			desc += fmt.Sprintf(":%s=%v", event.Variable.Name, event.Variable.Value)
+		case EventStatementFinished, EventFlowFinished:
+			desc += ":" + string(event.Status)
+		}
+		got = append(got, desc)
+	}
+	want := []string{
+		"flow_started:0",
+		"statement_started:9",
+		"step_attempt_failed:9:step1:1:E500:true",
+		"variable_set:9:部门=研发",
+		"variable_set:9:step1Err=<nil>",
+		"statement_finished:9:completed",
+		"statement_started:10",
+		"variable_set:10:档案编号=P001",
+		"variable_set:10:step2Err=<nil>",
+		"statement_finished:10:completed",
+		"statement_started:11",
+		"statement_finished:11:completed",
+		"flow_finished:0:completed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("事件不正确:\n%s\n期望:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if counts != len(events) || result.EventSeq != uint64(len(events)) {
+		t.Errorf("观察者收到的事件数不一致: %d %d %d", counts, len(events), result.EventSeq)
+	}
+
+	// 取消订阅后不再收到事件，失败的步骤和流程带有错误信息
+	unsubscribe()
+	h.On("step2", MockResult{Error: "档案已存在"})
+	var finished []Event
+	h.Executor.Subscribe(func(ctx context.Context, event Event) {
+		if event.Type == EventStatementFinished || event.Type == EventFlowFinished {
+			finished = append(finished, event)
+		}
+	})
+	total := len(events)
+	if _, err := h.Run(context.Background(), storeTestCode); err == nil {
+		t.Fatal("step2 失败时执行应失败")
+	}
+	if len(events) != total {
+		t.Errorf("取消订阅后仍收到事件: %d", len(events)-total)
+	}
+	last := finished[len(finished)-1]
+	if len(finished) != 3 || finished[1].Status != StatusFailed || finished[1].Error != "档案已存在" ||
+		last.Type != EventFlowFinished || last.Status != StatusFailed || last.Error != "档案已存在" {
+		t.Errorf("失败事件不正确: %+v", finished)
+	}
+}
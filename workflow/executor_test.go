@@ -3,6 +3,7 @@ package workflow
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -445,8 +446,13 @@ func main() {
 		t.Fatalf("解析失败: %s", result.Error)
 	}
 
-	// 创建执行器
+	// 创建执行器，已结束的流程通过 Store 获取
 	executor := NewExecutor()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	executor.Store = store
 
 	// 设置回调函数
 	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
@@ -467,7 +473,7 @@ func main() {
 
 	// 执行工作流
 	ctx := context.Background()
-	err := executor.Start(ctx, result)
+	err = executor.Start(ctx, result)
 	if err != nil {
 		t.Fatalf("执行失败: %v", err)
 	}
@@ -494,3 +500,100 @@ func main() {
 		t.Errorf("变量数量不匹配: 期望 %d, 实际 %d", len(result.Variables), len(retrievedResult.Variables))
 	}
 }
+
+// TestExecutor_ElseIfBranches 测试只执行一个分支，其余分支标记为跳过
+func TestExecutor_ElseIfBranches(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "级别": 2,
+}
+
+step1 = code.service.basic(level: int "级别") -> (result: string "结果", err: error "是否失败");
+step2 = code.service.advanced(level: int "级别") -> (result: string "结果", err: error "是否失败");
+step3 = code.service.expert(level: int "级别") -> (result: string "结果", err: error "是否失败");
+
+func main() {
+    if input["级别"] == 1 {
+        基础结果, step1Err := step1(input["级别"])
+    } else if input["级别"] == 2 {
+        高级结果, step2Err := step2(input["级别"])
+    } else {
+        专家结果, step3Err := step3(input["级别"])
+    }
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	var called []string
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		called = append(called, step.Name)
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"result": "ok", "err": nil}}, nil
+	}
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	if len(called) != 1 || called[0] != "step2" {
+		t.Fatalf("执行的步骤不正确: %v", called)
+	}
+
+	ifStmt := result.MainFunc.Statements[0]
+	if ifStmt.Status != StatusCompleted {
+		t.Errorf("if语句状态不正确: %s", ifStmt.Status)
+	}
+	if ifStmt.Children[0].Status != StatusSkipped {
+		t.Errorf("if分支语句应被跳过, 实际 %s", ifStmt.Children[0].Status)
+	}
+	if ifStmt.Branches[0].Status != StatusCompleted || ifStmt.Branches[0].Children[0].Status != StatusCompleted {
+		t.Errorf("else if分支应执行完成, 实际 %s/%s", ifStmt.Branches[0].Status, ifStmt.Branches[0].Children[0].Status)
+	}
+	if ifStmt.Branches[1].Status != StatusSkipped || ifStmt.Branches[1].Children[0].Status != StatusSkipped {
+		t.Errorf("else分支应被跳过, 实际 %s/%s", ifStmt.Branches[1].Status, ifStmt.Branches[1].Children[0].Status)
+	}
+}
+
+// TestExecutor_RegisterType 测试注册语句处理器：实现内置引擎跳过的 assign 语句
+func TestExecutor_RegisterType(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+func main() {
+    状态 := "待审批"
+    状态 = "已审批"
+    sys.Printf("状态: %s", 状态)
+    状态 = "未知"
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	executor := NewExecutor()
+	executor.RegisterType("assign", func(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
+		name, value, _ := strings.Cut(stmt.Content, "=")
+		name, value = strings.TrimSpace(name), strings.Trim(strings.TrimSpace(value), `"`)
+		if value == "未知" {
+			return fmt.Errorf("第%d行: 未知的状态", stmt.LineNumber)
+		}
+		variable, _ := workflow.GetVariable(name)
+		variable.Value = value
+		workflow.SetVariable(name, variable)
+		return nil
+	})
+
+	err := executor.Start(context.Background(), result)
+	if err == nil || !strings.Contains(err.Error(), "未知的状态") {
+		t.Fatalf("处理器返回的错误应使工作流失败: %v", err)
+	}
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "状态: 已审批" {
+		t.Errorf("日志不正确: %+v", result.GlobalLogs)
+	}
+	statements := result.MainFunc.Statements
+	if statements[1].Status != StatusCompleted || statements[3].Status != StatusFailed {
+		t.Errorf("语句状态不正确: %s %s", statements[1].Status, statements[3].Status)
+	}
+}
@@ -0,0 +1,634 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// 表达式错误，携带语句行号和表达式内的列号
+type ExprError struct {
+	Line   int    `json:"line"`   // 语句所在行号
+	Column int    `json:"column"` // 表达式内的列号（从1开始，0表示未知）
+	Expr   string `json:"expr"`   // 原始表达式
+	Msg    string `json:"msg"`    // 错误描述
+}
+
+func (e *ExprError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("第%d行表达式 `%s` 第%d列: %s", e.Line, e.Expr, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("第%d行表达式 `%s`: %s", e.Line, e.Expr, e.Msg)
+}
+
+// 表达式变量环境
+type exprEnv interface {
+	lookup(name string) (interface{}, bool)
+}
+
+// 基于工作流解析结果的变量环境，input 指向输入变量，其他标识符从 Variables 中查找
+type workflowEnv struct {
+	workflow *SimpleParseResult
+}
+
+func (w workflowEnv) lookup(name string) (interface{}, bool) {
+	if name == "input" {
+		return w.workflow.InputVars, true
+	}
+	info, exists := w.workflow.GetVariable(name)
+	if !exists {
+		return nil, false
+	}
+	// err 等被重命名的变量记录的是别名，需要取真实变量的值
+	if info.Name != "" && info.Name != name {
+		if target, ok := w.workflow.GetVariable(info.Name); ok {
+			return target.Value, true
+		}
+	}
+	return info.Value, true
+}
+
+// EvaluateExpression 在工作流当前变量上求值表达式
+func EvaluateExpression(expr string, line int, workflow *SimpleParseResult) (interface{}, error) {
+	return evalExpression(expr, line, workflowEnv{workflow: workflow})
+}
+
+// EvaluateCondition 求值条件表达式，结果必须为bool
+func EvaluateCondition(condition string, line int, workflow *SimpleParseResult) (bool, error) {
+	return evalCondition(condition, line, workflowEnv{workflow: workflow})
+}
+
+func evalCondition(condition string, line int, env exprEnv) (bool, error) {
+	value, err := evalExpression(condition, line, env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, &ExprError{Line: line, Expr: condition, Msg: fmt.Sprintf("条件结果必须为bool，实际为 %s", typeName(value))}
+	}
+	return b, nil
+}
+
+func evalExpression(expr string, line int, env exprEnv) (interface{}, error) {
+	node, err := parseExpression(expr, line)
+	if err != nil {
+		return nil, err
+	}
+	ev := &exprEvaluator{src: expr, line: line, env: env}
+	return ev.eval(node)
+}
+
+// parseExpression 使用Go语法解析表达式
+func parseExpression(expr string, line int) (ast.Expr, error) {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		msg := err.Error()
+		column := 0
+		var list scanner.ErrorList
+		if errors.As(err, &list) && len(list) > 0 {
+			msg = list[0].Msg
+			column = columnOf(expr, list[0].Pos.Offset)
+		}
+		return nil, &ExprError{Line: line, Column: column, Expr: expr, Msg: "语法错误: " + msg}
+	}
+	return node, nil
+}
+
+// parseCallExpr 将语句内容解析为函数调用表达式
+func parseCallExpr(content string) (*ast.CallExpr, bool) {
+	node, err := parser.ParseExpr(content)
+	if err != nil {
+		return nil, false
+	}
+	call, ok := node.(*ast.CallExpr)
+	return call, ok
+}
+
+// 表达式求值器
+type exprEvaluator struct {
+	src  string
+	line int
+	env  exprEnv
+}
+
+func (ev *exprEvaluator) errorf(node ast.Node, format string, args ...interface{}) error {
+	column := 0
+	if node != nil && node.Pos().IsValid() {
+		column = columnOf(ev.src, int(node.Pos())-1)
+	}
+	return &ExprError{Line: ev.line, Column: column, Expr: ev.src, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (ev *exprEvaluator) eval(node ast.Expr) (interface{}, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return ev.eval(n.X)
+	case *ast.BasicLit:
+		return ev.evalLiteral(n)
+	case *ast.Ident:
+		return ev.evalIdent(n)
+	case *ast.UnaryExpr:
+		return ev.evalUnary(n)
+	case *ast.BinaryExpr:
+		return ev.evalBinary(n)
+	case *ast.IndexExpr:
+		return ev.evalIndex(n)
+	case *ast.SelectorExpr:
+		return ev.evalSelector(n)
+	case *ast.CallExpr:
+		return ev.evalCall(n)
+	case *ast.TypeAssertExpr:
+		return ev.evalTypeAssert(n)
+	default:
+		return nil, ev.errorf(node, "不支持的表达式: %s", types.ExprString(node))
+	}
+}
+
+func (ev *exprEvaluator) evalLiteral(n *ast.BasicLit) (interface{}, error) {
+	switch n.Kind {
+	case token.INT:
+		i, err := strconv.ParseInt(n.Value, 0, 64)
+		if err != nil {
+			return nil, ev.errorf(n, "无效的整数: %s", n.Value)
+		}
+		return int(i), nil
+	case token.FLOAT:
+		f, err := strconv.ParseFloat(n.Value, 64)
+		if err != nil {
+			return nil, ev.errorf(n, "无效的浮点数: %s", n.Value)
+		}
+		return f, nil
+	case token.STRING, token.CHAR:
+		s, err := strconv.Unquote(n.Value)
+		if err != nil {
+			return nil, ev.errorf(n, "无效的字符串: %s", n.Value)
+		}
+		return s, nil
+	default:
+		return nil, ev.errorf(n, "不支持的字面量: %s", n.Value)
+	}
+}
+
+func (ev *exprEvaluator) evalIdent(n *ast.Ident) (interface{}, error) {
+	switch n.Name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nil":
+		return nil, nil
+	}
+	value, exists := ev.env.lookup(n.Name)
+	if !exists {
+		return nil, ev.errorf(n, "未定义的变量: %s", n.Name)
+	}
+	return value, nil
+}
+
+func (ev *exprEvaluator) evalUnary(n *ast.UnaryExpr) (interface{}, error) {
+	x, err := ev.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Op {
+	case token.NOT:
+		b, ok := x.(bool)
+		if !ok {
+			return nil, ev.errorf(n, "运算符 ! 需要bool，实际为 %s", typeName(x))
+		}
+		return !b, nil
+	case token.SUB, token.ADD:
+		num, ok := toNumber(x)
+		if !ok {
+			return nil, ev.errorf(n, "运算符 %s 需要数字，实际为 %s", n.Op, typeName(x))
+		}
+		if n.Op == token.ADD {
+			return num.value(), nil
+		}
+		if num.isInt {
+			return int(-num.i), nil
+		}
+		return -num.f, nil
+	default:
+		return nil, ev.errorf(n, "不支持的运算符: %s", n.Op)
+	}
+}
+
+func (ev *exprEvaluator) evalBinary(n *ast.BinaryExpr) (interface{}, error) {
+	// 逻辑运算短路求值
+	if n.Op == token.LAND || n.Op == token.LOR {
+		left, err := ev.evalBool(n.X, n.Op)
+		if err != nil {
+			return nil, err
+		}
+		if (n.Op == token.LAND && !left) || (n.Op == token.LOR && left) {
+			return left, nil
+		}
+		return ev.evalBool(n.Y, n.Op)
+	}
+
+	x, err := ev.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := ev.eval(n.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case token.EQL, token.NEQ:
+		eq, err := ev.equal(n, x, y)
+		if err != nil {
+			return nil, err
+		}
+		if n.Op == token.NEQ {
+			return !eq, nil
+		}
+		return eq, nil
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return ev.compare(n, x, y)
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+		return ev.arithmetic(n, x, y)
+	default:
+		return nil, ev.errorf(n, "不支持的运算符: %s", n.Op)
+	}
+}
+
+func (ev *exprEvaluator) evalBool(node ast.Expr, op token.Token) (bool, error) {
+	value, err := ev.eval(node)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, ev.errorf(node, "运算符 %s 需要bool，实际为 %s", op, typeName(value))
+	}
+	return b, nil
+}
+
+func (ev *exprEvaluator) equal(n *ast.BinaryExpr, x, y interface{}) (bool, error) {
+	if isNil(x) || isNil(y) {
+		return isNil(x) && isNil(y), nil
+	}
+	if nx, ok := toNumber(x); ok {
+		ny, ok := toNumber(y)
+		if !ok {
+			return false, ev.errorf(n, "类型不匹配: %s %s %s", typeName(x), n.Op, typeName(y))
+		}
+		return nx.cmp(ny) == 0, nil
+	}
+	switch xv := x.(type) {
+	case string:
+		yv, ok := y.(string)
+		if !ok {
+			return false, ev.errorf(n, "类型不匹配: %s %s %s", typeName(x), n.Op, typeName(y))
+		}
+		return xv == yv, nil
+	case bool:
+		yv, ok := y.(bool)
+		if !ok {
+			return false, ev.errorf(n, "类型不匹配: %s %s %s", typeName(x), n.Op, typeName(y))
+		}
+		return xv == yv, nil
+	}
+	if reflect.TypeOf(x) != reflect.TypeOf(y) {
+		return false, ev.errorf(n, "类型不匹配: %s %s %s", typeName(x), n.Op, typeName(y))
+	}
+	return reflect.DeepEqual(x, y), nil
+}
+
+func (ev *exprEvaluator) compare(n *ast.BinaryExpr, x, y interface{}) (interface{}, error) {
+	var c int
+	nx, okx := toNumber(x)
+	ny, oky := toNumber(y)
+	sx, sokx := x.(string)
+	sy, soky := y.(string)
+	switch {
+	case okx && oky:
+		c = nx.cmp(ny)
+	case sokx && soky:
+		switch {
+		case sx < sy:
+			c = -1
+		case sx > sy:
+			c = 1
+		}
+	default:
+		return nil, ev.errorf(n, "类型不匹配: %s %s %s", typeName(x), n.Op, typeName(y))
+	}
+	switch n.Op {
+	case token.LSS:
+		return c < 0, nil
+	case token.LEQ:
+		return c <= 0, nil
+	case token.GTR:
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func (ev *exprEvaluator) arithmetic(n *ast.BinaryExpr, x, y interface{}) (interface{}, error) {
+	if n.Op == token.ADD {
+		if sx, ok := x.(string); ok {
+			sy, ok := y.(string)
+			if !ok {
+				return nil, ev.errorf(n, "类型不匹配: %s + %s", typeName(x), typeName(y))
+			}
+			return sx + sy, nil
+		}
+	}
+	nx, okx := toNumber(x)
+	ny, oky := toNumber(y)
+	if !okx || !oky {
+		return nil, ev.errorf(n, "类型不匹配: %s %s %s", typeName(x), n.Op, typeName(y))
+	}
+	if nx.isInt && ny.isInt {
+		switch n.Op {
+		case token.ADD:
+			return int(nx.i + ny.i), nil
+		case token.SUB:
+			return int(nx.i - ny.i), nil
+		case token.MUL:
+			return int(nx.i * ny.i), nil
+		case token.QUO, token.REM:
+			if ny.i == 0 {
+				return nil, ev.errorf(n, "除数为0")
+			}
+			if n.Op == token.QUO {
+				return int(nx.i / ny.i), nil
+			}
+			return int(nx.i % ny.i), nil
+		}
+	}
+	switch n.Op {
+	case token.ADD:
+		return nx.f + ny.f, nil
+	case token.SUB:
+		return nx.f - ny.f, nil
+	case token.MUL:
+		return nx.f * ny.f, nil
+	case token.QUO:
+		if ny.f == 0 {
+			return nil, ev.errorf(n, "除数为0")
+		}
+		return nx.f / ny.f, nil
+	default:
+		return nil, ev.errorf(n, "运算符 %% 需要整数，实际为 %s 和 %s", typeName(x), typeName(y))
+	}
+}
+
+func (ev *exprEvaluator) evalIndex(n *ast.IndexExpr) (interface{}, error) {
+	x, err := ev.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	index, err := ev.eval(n.Index)
+	if err != nil {
+		return nil, err
+	}
+	if isNil(x) {
+		return nil, ev.errorf(n, "无法对nil进行索引: %s", types.ExprString(n.X))
+	}
+
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.Map:
+		key := reflect.ValueOf(index)
+		if !key.IsValid() || !key.Type().AssignableTo(rv.Type().Key()) {
+			if key.IsValid() && key.Type().ConvertibleTo(rv.Type().Key()) && key.Kind() == rv.Type().Key().Kind() {
+				key = key.Convert(rv.Type().Key())
+			} else {
+				return nil, ev.errorf(n.Index, "map键类型不匹配: 需要 %s，实际为 %s", rv.Type().Key(), typeName(index))
+			}
+		}
+		value := rv.MapIndex(key)
+		if !value.IsValid() {
+			return nil, nil
+		}
+		return value.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		num, ok := toNumber(index)
+		if !ok || !num.isInt {
+			return nil, ev.errorf(n.Index, "下标必须为整数，实际为 %s", typeName(index))
+		}
+		if num.i < 0 || int(num.i) >= rv.Len() {
+			return nil, ev.errorf(n.Index, "下标越界: %d (长度 %d)", num.i, rv.Len())
+		}
+		return rv.Index(int(num.i)).Interface(), nil
+	default:
+		return nil, ev.errorf(n, "类型 %s 不支持索引", typeName(x))
+	}
+}
+
+func (ev *exprEvaluator) evalSelector(n *ast.SelectorExpr) (interface{}, error) {
+	x, err := ev.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	if isNil(x) {
+		return nil, ev.errorf(n, "无法访问nil的字段: %s", types.ExprString(n))
+	}
+	rv := reflect.Indirect(reflect.ValueOf(x))
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, ev.errorf(n, "类型 %s 不支持字段访问", typeName(x))
+		}
+		value := rv.MapIndex(reflect.ValueOf(n.Sel.Name).Convert(rv.Type().Key()))
+		if !value.IsValid() {
+			return nil, nil
+		}
+		return value.Interface(), nil
+	case reflect.Struct:
+		field := rv.FieldByName(n.Sel.Name)
+		if !field.IsValid() || !field.CanInterface() {
+			return nil, ev.errorf(n.Sel, "类型 %s 没有字段 %s", typeName(x), n.Sel.Name)
+		}
+		return field.Interface(), nil
+	default:
+		return nil, ev.errorf(n, "类型 %s 不支持字段访问", typeName(x))
+	}
+}
+
+func (ev *exprEvaluator) evalCall(n *ast.CallExpr) (interface{}, error) {
+	fn, ok := n.Fun.(*ast.Ident)
+	if !ok || fn.Name != "len" {
+		return nil, ev.errorf(n, "不支持的函数调用: %s", types.ExprString(n.Fun))
+	}
+	if len(n.Args) != 1 {
+		return nil, ev.errorf(n, "len 需要1个参数，实际 %d 个", len(n.Args))
+	}
+	x, err := ev.eval(n.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	if isNil(x) {
+		return 0, nil
+	}
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Array:
+		return rv.Len(), nil
+	default:
+		return nil, ev.errorf(n.Args[0], "len 不支持类型 %s", typeName(x))
+	}
+}
+
+func (ev *exprEvaluator) evalTypeAssert(n *ast.TypeAssertExpr) (interface{}, error) {
+	x, err := ev.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	if n.Type == nil {
+		return nil, ev.errorf(n, "不支持 .(type)")
+	}
+	want := types.ExprString(n.Type)
+	if value, ok := assertType(x, want); ok {
+		return value, nil
+	}
+	return nil, ev.errorf(n, "类型断言失败: %s 不是 %s", typeName(x), want)
+}
+
+// assertType 按DSL的宽松规则做类型断言，JSON解码得到的整数值float64可断言为int
+func assertType(x interface{}, want string) (interface{}, bool) {
+	switch want {
+	case "interface{}", "any":
+		return x, true
+	case "string":
+		s, ok := x.(string)
+		return s, ok
+	case "bool":
+		b, ok := x.(bool)
+		return b, ok
+	case "int", "int64", "int32":
+		num, ok := toNumber(x)
+		if !ok || (!num.isInt && num.f != math.Trunc(num.f)) {
+			return nil, false
+		}
+		i := num.i
+		if !num.isInt {
+			i = int64(num.f)
+		}
+		if want == "int64" {
+			return i, true
+		}
+		if want == "int32" {
+			return int32(i), true
+		}
+		return int(i), true
+	case "float64", "float32":
+		num, ok := toNumber(x)
+		if !ok {
+			return nil, false
+		}
+		return num.f, true
+	case "error":
+		e, ok := x.(error)
+		return e, ok
+	}
+	if x == nil {
+		return nil, false
+	}
+	return x, typeName(x) == want
+}
+
+// 数值，整数保留精确值
+type exprNumber struct {
+	i     int64
+	f     float64
+	isInt bool
+}
+
+func (n exprNumber) value() interface{} {
+	if n.isInt {
+		return int(n.i)
+	}
+	return n.f
+}
+
+func (n exprNumber) cmp(o exprNumber) int {
+	if n.isInt && o.isInt {
+		switch {
+		case n.i < o.i:
+			return -1
+		case n.i > o.i:
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case n.f < o.f:
+		return -1
+	case n.f > o.f:
+		return 1
+	}
+	return 0
+}
+
+func toNumber(x interface{}) (exprNumber, bool) {
+	switch v := x.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return exprNumber{i: i, f: float64(i), isInt: true}, true
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return exprNumber{}, false
+		}
+		return exprNumber{f: f}, true
+	case bool, string, nil:
+		return exprNumber{}, false
+	}
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return exprNumber{i: rv.Int(), f: float64(rv.Int()), isInt: true}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return exprNumber{i: int64(rv.Uint()), f: float64(rv.Uint()), isInt: true}, true
+	case reflect.Float32, reflect.Float64:
+		return exprNumber{f: rv.Float()}, true
+	}
+	return exprNumber{}, false
+}
+
+func isNil(x interface{}) bool {
+	if x == nil {
+		return true
+	}
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func typeName(x interface{}) string {
+	if x == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%T", x)
+}
+
+// columnOf 将字节偏移转换为字符列号
+func columnOf(src string, offset int) int {
+	if offset < 0 {
+		return 0
+	}
+	if offset > len(src) {
+		offset = len(src)
+	}
+	return utf8.RuneCountInString(src[:offset]) + 1
+}
@@ -0,0 +1,183 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newExpressionTestWorkflow() *SimpleParseResult {
+	return &SimpleParseResult{
+		InputVars: map[string]interface{}{
+			"部门":  "研发",
+			"年龄":  30,
+			"分数":  float64(88),
+			"候选人": []interface{}{"张三", "李四"},
+		},
+		Variables: map[string]VariableInfo{
+			"工号":       {Name: "工号", Type: "string", Value: "EMP001"},
+			"数量":       {Name: "数量", Type: "int", Value: 3},
+			"通过":       {Name: "通过", Type: "bool", Value: true},
+			"step1Err": {Name: "step1Err", Type: "error", Value: nil},
+			"step2Err": {Name: "step2Err", Type: "error", Value: errors.New("失败")},
+			"err":      {Name: "step2Err", Type: "error"},
+			"详情":       {Name: "详情", Type: "map[string]interface{}", Value: map[string]interface{}{"级别": 2}},
+		},
+	}
+}
+
+// TestEvaluateCondition 测试条件表达式求值
+func TestEvaluateCondition(t *testing.T) {
+	workflow := newExpressionTestWorkflow()
+
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`step1Err != nil`, false},
+		{`step1Err == nil`, true},
+		{`step2Err != nil`, true},
+		{`err != nil`, true},
+		{`通过 == true`, true},
+		{`!通过`, false},
+		{`数量 > 2 && 数量 <= 3`, true},
+		{`数量 * 2 == 6`, true},
+		{`数量 > 5 || 工号 == "EMP001"`, true},
+		{`(数量 > 5 || 工号 != "EMP001") && 通过`, false},
+		{`input["部门"] == "研发"`, true},
+		{`input["年龄"] >= 18`, true},
+		{`input["分数"] > 87.5`, true},
+		{`input["年龄"].(int) == 30`, true},
+		{`input["不存在"] == nil`, true},
+		{`len(input["候选人"]) == 2`, true},
+		{`input["候选人"][1] == "李四"`, true},
+		{`详情["级别"] == 2`, true},
+		{`详情.级别 == 2`, true},
+		{`len(工号) > 0`, true},
+		{`"a" < "b"`, true},
+		// 短路求值，右侧不会访问未定义变量
+		{`false && 未定义 == 1`, false},
+	}
+
+	for _, c := range cases {
+		got, err := EvaluateCondition(c.expr, 10, workflow)
+		if err != nil {
+			t.Errorf("%s: 求值失败: %v", c.expr, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: 期望 %v, 实际 %v", c.expr, c.want, got)
+		}
+	}
+}
+
+// TestEvaluateCondition_Errors 测试未定义变量和类型不匹配时返回带行号的错误
+func TestEvaluateCondition_Errors(t *testing.T) {
+	workflow := newExpressionTestWorkflow()
+
+	cases := []struct {
+		expr    string
+		wantMsg string
+	}{
+		{`未定义 != nil`, "未定义的变量: 未定义"},
+		{`工号 > 1`, "类型不匹配"},
+		{`数量 == "3"`, "类型不匹配"},
+		{`数量 && 通过`, "需要bool"},
+		{`数量`, "条件结果必须为bool"},
+		{`input["部门"].(int) == 1`, "类型断言失败"},
+		{`input["候选人"][5] == nil`, "下标越界"},
+		{`数量 / 0 == 1`, "除数为0"},
+		{`foo(1)`, "不支持的函数调用"},
+		{`数量 >`, "语法错误"},
+	}
+
+	for _, c := range cases {
+		_, err := EvaluateCondition(c.expr, 42, workflow)
+		if err == nil {
+			t.Errorf("%s: 期望返回错误", c.expr)
+			continue
+		}
+		var exprErr *ExprError
+		if !errors.As(err, &exprErr) {
+			t.Errorf("%s: 期望 *ExprError, 实际 %T", c.expr, err)
+			continue
+		}
+		if exprErr.Line != 42 {
+			t.Errorf("%s: 行号不正确: 期望 42, 实际 %d", c.expr, exprErr.Line)
+		}
+		if !strings.Contains(exprErr.Msg, c.wantMsg) {
+			t.Errorf("%s: 错误信息 %q 不包含 %q", c.expr, exprErr.Msg, c.wantMsg)
+		}
+	}
+}
+
+// TestExecutor_ConditionError 测试if条件求值失败时工作流终止并指向语句行号
+func TestExecutor_ConditionError(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = beiluo.test1.devops.devops_script_create(username: string "用户名") -> (workId: string "工号", err: error "是否失败");
+
+func main() {
+    工号, step1Err := step1(input["用户名"])
+    if 未知变量 != nil {
+        return
+    }
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"workId": "EMP001", "err": nil}}, nil
+	}
+
+	err := executor.Start(context.Background(), result)
+	var exprErr *ExprError
+	if !errors.As(err, &exprErr) {
+		t.Fatalf("期望 *ExprError, 实际 %v", err)
+	}
+	if exprErr.Line != 9 {
+		t.Errorf("行号不正确: 期望 9, 实际 %d", exprErr.Line)
+	}
+	if result.MainFunc.Statements[1].Status != StatusFailed {
+		t.Errorf("if语句状态不正确: 期望 failed, 实际 %s", result.MainFunc.Statements[1].Status)
+	}
+}
+
+// TestExecutor_PrintStatement 测试打印语句记录到步骤日志
+func TestExecutor_PrintStatement(t *testing.T) {
+	code := `step1 = beiluo.test1.devops.devops_script_create(username: string "用户名") -> (workId: string "工号", err: error "是否失败");
+
+func main() {
+    sys.Println("开始")
+    工号, step1Err := step1("张三")
+    step1.Printf("工号: %s", 工号)
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"workId": "EMP001", "err": nil}}, nil
+	}
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "开始" {
+		t.Errorf("全局日志不正确: %+v", result.GlobalLogs)
+	}
+	logs := result.Steps[0].Logs
+	if len(logs) != 1 || logs[0].Message != "工号: EMP001" || logs[0].Source != "step1.Printf" {
+		t.Errorf("步骤日志不正确: %+v", logs)
+	}
+}
@@ -0,0 +1,430 @@
+package workflow
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format 格式化工作流代码，输出规范的写法
+//
+// 输入参数为 var input = map[string]interface{}{...}，每个参数一行；步骤声明每个一行，
+// 形如 step1 = 函数名(参数: 类型 "描述") -> (返回值: 类型 "描述") {元数据};，
+// 多行的步骤声明合并为一行；main 函数按 gofmt 的规则缩进（4个空格），步骤调用的元数据写为 {retry: 3, timeout: 5000}。
+// 注释（包括 //desc:）、中文标识符、空行分组和声明顺序保持不变，package/import 被去掉。
+//
+// 代码有解析错误时返回错误；格式化结果保证与原代码解析出相同的工作流，否则返回错误而不是改变语义的结果。
+func Format(code string) (string, error) {
+	p := NewSimpleParser()
+	original := p.ParseWorkflow(code)
+	if !original.Success {
+		return "", fmt.Errorf("解析失败: %s", original.Error)
+	}
+
+	d := newDSLParser(p, strings.TrimSpace(code), &SimpleParseResult{InputVars: map[string]interface{}{}, Variables: map[string]VariableInfo{}})
+	d.tokenize()
+	d.parseTopLevel()
+	d.stripMetadata()
+	f := &formatter{d: d}
+	formatted, err := f.format()
+	if err != nil {
+		return "", err
+	}
+
+	if result := p.ParseWorkflow(formatted); !result.Success || !sameWorkflow(original, result) {
+		return "", fmt.Errorf("格式化后的工作流与原代码不一致，请检查代码写法")
+	}
+	return formatted, nil
+}
+
+// formatter 按顶层项依次输出规范的代码
+type formatter struct {
+	d       *dslParser
+	out     []string // 输出的行
+	grouped bool     // 是否在 var 分组中
+}
+
+// 格式化输出的缩进
+const formatIndent = "    "
+
+func (f *formatter) format() (string, error) {
+	if err := f.items(f.d.topLevel, ""); err != nil {
+		return "", err
+	}
+	return strings.Join(f.out, "\n") + "\n", nil
+}
+
+// items 输出一组顶层项，保留原来的空行分组，不同类型的声明之间加空行
+func (f *formatter) items(items []topLevelItem, indent string) error {
+	for i, item := range items {
+		if i > 0 {
+			prev := items[i-1]
+			// 与上一项在同一行的注释写在行尾
+			if item.kind == topLevelComment && f.line(item.from) == f.endLine(prev) {
+				f.out[len(f.out)-1] += " " + f.tokenSource(item.from)
+				continue
+			}
+			if f.line(item.from) > f.endLine(prev)+1 || f.section(items, i-1) != f.section(items, i) {
+				f.out = append(f.out, "")
+			}
+		}
+
+		var lines []string
+		switch item.kind {
+		case topLevelComment:
+			lines = []string{f.tokenSource(item.from)}
+		case topLevelDecl:
+			lines = f.declaration(item)
+		case topLevelGroup:
+			lines = []string{"var ("}
+			inner := &formatter{d: f.d, grouped: true}
+			if err := inner.items(item.items, formatIndent); err != nil {
+				return err
+			}
+			lines = append(append(lines, inner.out...), ")")
+		case topLevelMain:
+			main, err := f.main(item)
+			if err != nil {
+				return err
+			}
+			lines = main
+		}
+		for _, line := range lines {
+			if line != "" {
+				line = indent + line
+			}
+			f.out = append(f.out, line)
+		}
+	}
+	return nil
+}
+
+// section 顶层项所属的分组：注释属于其后的第一个声明
+func (f *formatter) section(items []topLevelItem, i int) string {
+	for ; i < len(items); i++ {
+		item := items[i]
+		switch {
+		case item.kind == topLevelDecl && f.isInput(item):
+			return "input"
+		case item.kind != topLevelComment:
+			return item.kind
+		}
+	}
+	return topLevelComment
+}
+
+func (f *formatter) line(i int) int {
+	line, _ := f.d.position(f.d.toks[i].off)
+	return line
+}
+
+// endLine 顶层项最后一个词法单元结束的行号
+func (f *formatter) endLine(item topLevelItem) int {
+	t := f.d.toks[item.to-1]
+	line, _ := f.d.position(max(t.end-1, t.off))
+	return line
+}
+
+func (f *formatter) tokenSource(i int) string {
+	t := f.d.toks[i]
+	return strings.TrimRight(f.d.code[t.off:t.end], " \t\r")
+}
+
+func (f *formatter) isInput(item topLevelItem) bool {
+	return item.to-item.from >= 2 && f.d.toks[item.from].lit == "input" && f.d.toks[item.from+1].tok == token.ASSIGN
+}
+
+// declaration 输出 input 或步骤声明，声明中间的注释移到声明之前，声明之后的注释写在行尾
+func (f *formatter) declaration(item topLevelItem) []string {
+	last := item.to - 1
+	for last > item.from && f.d.toks[last].tok == token.COMMENT {
+		last--
+	}
+	var trailing string
+	for i := last + 1; i < item.to; i++ {
+		trailing += " " + f.tokenSource(i)
+	}
+	if f.isInput(item) {
+		lines := f.input(item)
+		lines[len(lines)-1] += trailing
+		return lines
+	}
+
+	var comments []string
+	for i := item.from; i < last; i++ {
+		if f.d.toks[i].tok == token.COMMENT {
+			comments = append(comments, f.tokenSource(i))
+		}
+	}
+	source := f.d.code[f.d.toks[item.from].off:f.d.toks[last].end]
+	return append(comments, canonicalTokens(source)+";"+trailing)
+}
+
+// input 输出 var input = map[string]interface{}{...}，var 分组中省略 var，每个参数一行，参数的值保持原样
+func (f *formatter) input(item topLevelItem) []string {
+	open := -1
+	for j := item.from + 2; j < item.to; j++ {
+		if f.d.toks[j].tok == token.LBRACE && f.d.toks[j-1].tok != token.INTERFACE {
+			open = j
+			break
+		}
+	}
+	closing := f.d.matching(open)
+	content := f.d.code[f.d.toks[open].end:f.d.toks[closing].off]
+	lines := []string{"input = map[string]interface{}{"}
+	if !f.grouped {
+		lines[0] = "var " + lines[0]
+	}
+
+	src := "package workflow\nvar _ = map[string]interface{}{" + content + "}"
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	var lit *ast.CompositeLit
+	if err == nil {
+		lit, _ = file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.CompositeLit)
+	}
+	if lit == nil {
+		// 不是Go语法时按行解析，只调整缩进
+		for _, line := range strings.Split(content, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "//") && strings.Contains(line, ":") && !strings.HasSuffix(line, ",") {
+				line += ","
+			}
+			lines = append(lines, formatIndent+line)
+		}
+		return append(lines, "}")
+	}
+
+	text := func(from, to token.Pos) string { return src[fset.Position(from).Offset:fset.Position(to).Offset] }
+	comments := file.Comments
+	prevLine := fset.Position(lit.Lbrace).Line
+	// flush 输出 before 之前的注释，与上一个参数在同一行的注释写在行尾
+	flush := func(before token.Pos) {
+		for len(comments) > 0 && comments[0].Pos() < before {
+			for _, comment := range comments[0].List {
+				if fset.Position(comment.Pos()).Line == prevLine && len(lines) > 1 {
+					lines[len(lines)-1] += " " + comment.Text
+				} else {
+					lines = append(lines, formatIndent+comment.Text)
+				}
+			}
+			comments = comments[1:]
+		}
+	}
+	for _, elt := range lit.Elts {
+		flush(elt.Pos())
+		prevLine = fset.Position(elt.End()).Line
+		line := formatIndent + text(elt.Pos(), elt.End())
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			line = formatIndent + text(kv.Key.Pos(), kv.Key.End()) + ": " + text(kv.Value.Pos(), kv.Value.End())
+		}
+		lines = append(lines, line+",")
+	}
+	flush(lit.Rbrace)
+	return append(lines, "}")
+}
+
+// 步骤调用的元数据在 go/printer 格式化期间替换为方法调用 ._wm0()，格式化后再换回
+var metadataPlaceholderRegex = regexp.MustCompile(`\._wm(\d+)_*\(\)`)
+
+// main 用 go/printer 格式化 main 函数
+//
+// 元数据先替换为与其等宽的占位方法调用，使格式化时行尾注释的对齐不受影响。
+func (f *formatter) main(item topLevelItem) ([]string, error) {
+	start, end := f.d.toks[item.from].off, f.d.toks[item.to-1].end
+	var src strings.Builder
+	var metadata []string
+	pos := start
+	for i := item.from; i < item.to; i++ {
+		t := f.d.toks[i]
+		block, exists := f.d.metadata[t.off]
+		if t.tok != token.RPAREN || !exists {
+			continue
+		}
+		text := canonicalTokens(block.text)
+		placeholder := "._wm" + strconv.Itoa(len(metadata))
+		padding := utf8.RuneCountInString(text) - utf8.RuneCountInString(placeholder) - 2
+		src.WriteString(f.d.code[pos:t.end])
+		src.WriteString(placeholder + strings.Repeat("_", max(0, padding)) + "()")
+		metadata = append(metadata, text)
+		pos = block.end
+	}
+	src.WriteString(f.d.code[pos:end])
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", goSourcePrefix+src.String(), parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("格式化 main 函数失败: %v", err)
+	}
+	var buf bytes.Buffer
+	config := printer.Config{Mode: printer.UseSpaces, Tabwidth: len(formatIndent)}
+	if err := config.Fprint(&buf, fset, file); err != nil {
+		return nil, fmt.Errorf("格式化 main 函数失败: %v", err)
+	}
+	// 去掉补在前面的包声明
+	formatted := strings.TrimSpace(strings.TrimPrefix(buf.String(), strings.TrimSpace(goSourcePrefix)))
+	formatted = metadataPlaceholderRegex.ReplaceAllStringFunc(formatted, func(s string) string {
+		index, _ := strconv.Atoi(metadataPlaceholderRegex.FindStringSubmatch(s)[1])
+		return metadata[index]
+	})
+	return strings.Split(formatted, "\n"), nil
+}
+
+// formatToken 规范化输出中的一个词法单元
+type formatToken struct {
+	tok  token.Token
+	text string
+}
+
+// isWord 标识符、关键字和字面量，相邻时需要空格分隔
+func (t formatToken) isWord() bool {
+	return t.tok == token.IDENT || t.tok.IsLiteral() || t.tok.IsKeyword()
+}
+
+// canonicalTokens 按词法单元重新排版一段代码：逗号和冒号后加空格，= 和 -> 两边加空格，
+// 括号内侧不加空格，步骤声明的 ) 与元数据 { 之间加空格，注释、换行和右括号前多余的逗号被去掉
+func canonicalTokens(src string) string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, 0)
+
+	var toks []formatToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		text := tok.String()
+		if lit != "" {
+			text = lit
+		}
+		// 步骤声明的 -> 被分为 - 和 >
+		if tok == token.GTR && len(toks) > 0 && toks[len(toks)-1].tok == token.SUB && file.Offset(pos) > 0 && src[file.Offset(pos)-1] == '-' {
+			toks[len(toks)-1] = formatToken{tok: token.ARROW, text: "->"}
+			continue
+		}
+		toks = append(toks, formatToken{tok: tok, text: text})
+	}
+
+	var b strings.Builder
+	for i, t := range toks {
+		// 右括号前多余的逗号
+		if t.tok == token.COMMA && i+1 < len(toks) && (toks[i+1].tok == token.RPAREN || toks[i+1].tok == token.RBRACK || toks[i+1].tok == token.RBRACE) {
+			continue
+		}
+		if i > 0 && needSpace(toks[i-1], t) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+// needSpace 两个相邻的词法单元之间是否需要空格
+func needSpace(prev, cur formatToken) bool {
+	switch {
+	case cur.tok == token.RPAREN || cur.tok == token.RBRACK || cur.tok == token.RBRACE,
+		cur.tok == token.COMMA || cur.tok == token.COLON || cur.tok == token.SEMICOLON:
+		return false
+	case prev.tok == token.LPAREN || prev.tok == token.LBRACK || prev.tok == token.LBRACE:
+		return false
+	case prev.tok == token.COMMA || prev.tok == token.COLON:
+		return true
+	case prev.tok == token.ASSIGN || cur.tok == token.ASSIGN || prev.tok == token.ARROW || cur.tok == token.ARROW:
+		return true
+	case cur.tok == token.LBRACE:
+		return prev.tok == token.RPAREN
+	case cur.isWord():
+		return prev.isWord() || prev.tok == token.RPAREN || prev.tok == token.RBRACE
+	}
+	return false
+}
+
+// sameWorkflow 两次解析的工作流是否相同，忽略行号、流程ID和代码中的空白
+func sameWorkflow(a, b *SimpleParseResult) bool {
+	return reflect.DeepEqual(workflowShape(a), workflowShape(b))
+}
+
+// workflowShape 返回工作流中与执行相关的部分，代码文本按词法单元规范化
+func workflowShape(result *SimpleParseResult) map[string]interface{} {
+	steps := make([]map[string]interface{}, len(result.Steps))
+	for i, step := range result.Steps {
+		steps[i] = map[string]interface{}{
+			"name":     step.Name,
+			"function": step.Function,
+			"inputs":   paramsShape(step.InputParams),
+			"outputs":  paramsShape(step.OutputParams),
+			"static":   step.IsStatic,
+			"case":     step.CaseID,
+			"desc":     step.Desc,
+			"metadata": step.Metadata,
+		}
+	}
+	return map[string]interface{}{
+		"input":        result.InputVars,
+		"steps":        steps,
+		"main":         statementsShape(result.MainFunc.Statements),
+		"max_parallel": result.MaxParallel,
+		"timeout":      result.Timeout,
+	}
+}
+
+func paramsShape(params []ParameterInfo) []ParameterInfo {
+	shape := make([]ParameterInfo, len(params))
+	for i, param := range params {
+		shape[i] = ParameterInfo{Name: param.Name, Type: canonicalTokens(param.Type), Desc: param.Desc}
+	}
+	return shape
+}
+
+func argumentsShape(args []*ArgumentInfo) []ArgumentInfo {
+	shape := make([]ArgumentInfo, len(args))
+	for i, arg := range args {
+		shape[i] = *arg
+		shape[i].Value = canonicalTokens(arg.Value)
+		shape[i].LineNum = 0
+	}
+	return shape
+}
+
+func statementsShape(statements []*SimpleStatement) []map[string]interface{} {
+	shape := make([]map[string]interface{}, len(statements))
+	for i, stmt := range statements {
+		s := map[string]interface{}{
+			"type":      stmt.Type,
+			"content":   canonicalTokens(stmt.Content),
+			"condition": canonicalTokens(stmt.Condition),
+			"function":  stmt.Function,
+			"args":      argumentsShape(stmt.Args),
+			"returns":   argumentsShape(stmt.Returns),
+			"metadata":  stmt.Metadata,
+			"desc":      stmt.Desc,
+			"children":  statementsShape(stmt.Children),
+			"branches":  statementsShape(stmt.Branches),
+		}
+		if stmt.Loop != nil {
+			s["loop"] = []string{stmt.Loop.Kind, stmt.Loop.KeyVar, stmt.Loop.ValueVar, canonicalTokens(stmt.Loop.RangeExpr)}
+		}
+		if stmt.Wait != nil {
+			s["wait"] = *stmt.Wait
+		}
+		shape[i] = s
+	}
+	return shape
+}
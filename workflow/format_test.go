@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFormat_Canonical 测试不规范的写法格式化为规范写法
+func TestFormat_Canonical(t *testing.T) {
+	code := `package main
+
+var input = map[string]interface{}{ "姓名":"张三",
+  // 年龄
+  "年龄":  18 }
+step1 = beiluo.test1.crm.create( name:string "姓名",
+    age:int "年龄", )->( id:string "编号",err:error "是否失败" )
+step2=beiluo.test1.crm.notify(id: string "编号")->(err: error "是否失败")  {retry:3};   // 通知
+
+func main() {
+//desc: 创建用户
+编号,err:=step1(input["姓名"],input["年龄"]){retry:2,timeout:1000}
+    if err!=nil {
+  step1.Printf("创建失败: %v",err)
+        return
+    }
+	step2(编号) {timeout: 500}
+}`
+	want := `var input = map[string]interface{}{
+    "姓名": "张三",
+    // 年龄
+    "年龄": 18,
+}
+
+step1 = beiluo.test1.crm.create(name: string "姓名", age: int "年龄") -> (id: string "编号", err: error "是否失败");
+step2 = beiluo.test1.crm.notify(id: string "编号") -> (err: error "是否失败") {retry: 3}; // 通知
+
+func main() {
+    //desc: 创建用户
+    编号, err := step1(input["姓名"], input["年龄"]){retry: 2, timeout: 1000}
+    if err != nil {
+        step1.Printf("创建失败: %v", err)
+        return
+    }
+    step2(编号){timeout: 500}
+}
+`
+	got, err := Format(code)
+	if err != nil {
+		t.Fatalf("格式化失败: %v", err)
+	}
+	if got != want {
+		t.Errorf("格式化结果不正确:\n%s\n期望:\n%s", got, want)
+	}
+}
+
+// TestFormat_Equivalent 测试格式化结果与原代码解析出相同的工作流，并且再次格式化不变
+func TestFormat_Equivalent(t *testing.T) {
+	codes := map[string]string{
+		"store":        storeTestCode,
+		"wait":         waitTestCode,
+		"compensation": compensationTestCode,
+		"concurrency":  concurrencyTestCode,
+		"group": `var (
+    input = {"姓名": "张三"} // 输入
+    step1 = beiluo.test1.crm.create[用例001] -> (err: error "是否失败")
+)
+func main() {
+    for 次数 < 3 { step1() }
+}`,
+		"inline": `step1 = beiluo.test1.crm.create(name: string "姓名") -> (id: string "编号", err: error "是否失败")
+func main() {
+    部门 := "研发"
+    if 部门 == "研发" { 编号, e := step1(部门){retry: 1} }
+}`,
+	}
+	for name, code := range codes {
+		t.Run(name, func(t *testing.T) {
+			formatted, err := Format(code)
+			if err != nil {
+				t.Fatalf("格式化失败: %v", err)
+			}
+			if !sameWorkflow(NewSimpleParser().ParseWorkflow(code), NewSimpleParser().ParseWorkflow(formatted)) {
+				t.Errorf("格式化后的工作流不一致:\n%s", formatted)
+			}
+			again, err := Format(formatted)
+			if err != nil || again != formatted {
+				t.Errorf("再次格式化结果改变: %v\n%s\n%s", err, formatted, again)
+			}
+			if name == "inline" {
+				if call := NewSimpleParser().ParseWorkflow(code).MainFunc.Statements[1].Children[0]; call.Content != "编号, e := step1(部门){retry: 1}" {
+					t.Errorf("同一行的调用语句不应包含 if: %s", call.Content)
+				}
+			}
+			if name == "group" && !strings.Contains(formatted, "    input = map[string]interface{}{\n        \"姓名\": \"张三\",\n    } // 输入") {
+				t.Errorf("var 分组中的 input 不正确:\n%s", formatted)
+			}
+		})
+	}
+}
+
+// TestFormat_ParseError 测试代码有解析错误时返回错误
+func TestFormat_ParseError(t *testing.T) {
+	code := strings.Replace(storeTestCode, "func main() {", "func main() {\n    if {", 1)
+	if _, err := Format(code); err == nil || !strings.Contains(err.Error(), "解析失败") {
+		t.Errorf("应返回解析错误: %v", err)
+	}
+}
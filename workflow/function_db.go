@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 数据库函数执行器：每个函数对应一条SQL，按参数名绑定输入参数，查询结果的列按名称映射为输出参数
+//
+//	executor := &workflow.DatabaseFunctionExecutor{DB: db, Queries: map[string]string{
+//	    "beiluo.test1.hr.query_department": "SELECT department FROM users WHERE name = @name",
+//	    "beiluo.test1.hr.create_profile":   "INSERT INTO profiles (name, department) VALUES (@name, @department)",
+//	}}
+//
+// 步骤定义了 error 以外的返回值时执行查询，取第一行结果，没有结果时步骤失败，错误码为 NOT_FOUND；
+// 否则执行更新语句。
+type DatabaseFunctionExecutor struct {
+	DB      *gorm.DB          // 数据库连接
+	Queries map[string]string // 函数名 -> SQL，ExecuteStep 使用 @参数名 绑定参数，Execute 使用 ? 按位置绑定
+}
+
+// query 返回函数对应的SQL
+func (d *DatabaseFunctionExecutor) query(functionName string) (string, error) {
+	query, exists := d.Queries[functionName]
+	if !exists {
+		return "", fmt.Errorf("函数 %s 未配置SQL", functionName)
+	}
+	return query, nil
+}
+
+// ExecuteStep 按参数名执行SQL，实现 StepFunctionExecutor
+func (d *DatabaseFunctionExecutor) ExecuteStep(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+	query, err := d.query(step.Function)
+	if err != nil {
+		return nil, err
+	}
+	args := make(map[string]interface{}, len(step.InputParams))
+	for _, param := range step.InputParams {
+		args[param.Name] = in.RealInput[param.Name]
+	}
+
+	isQuery := false
+	for _, param := range step.OutputParams {
+		isQuery = isQuery || param.Type != "error"
+	}
+	if !isQuery {
+		if err := d.DB.WithContext(ctx).Exec(query, args).Error; err != nil {
+			return &ExecutorOut{Success: false, Error: err.Error()}, nil
+		}
+		return mapOutput(step, nil), nil
+	}
+
+	row, err := d.firstRow(ctx, query, args)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &ExecutorOut{Success: false, Error: fmt.Sprintf("函数 %s 查询无结果", step.Function), ErrorCode: "NOT_FOUND"}, nil
+	}
+	if err != nil {
+		return &ExecutorOut{Success: false, Error: err.Error()}, nil
+	}
+	values := make(map[string]interface{}, len(row.columns))
+	for i, column := range row.columns {
+		values[column] = row.values[i]
+	}
+	return mapOutput(step, values), nil
+}
+
+// Execute 按位置绑定参数执行查询，返回第一行各列的值
+func (d *DatabaseFunctionExecutor) Execute(functionName string, args []interface{}) ([]interface{}, error) {
+	query, err := d.query(functionName)
+	if err != nil {
+		return nil, err
+	}
+	row, err := d.firstRow(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range row.values {
+		if data, ok := value.([]byte); ok {
+			row.values[i] = string(data)
+		}
+	}
+	return row.values, nil
+}
+
+// dbRow 查询结果的一行
+type dbRow struct {
+	columns []string
+	values  []interface{}
+}
+
+// firstRow 执行查询并返回第一行，没有结果时返回 sql.ErrNoRows
+func (d *DatabaseFunctionExecutor) firstRow(ctx context.Context, query string, args ...interface{}) (*dbRow, error) {
+	rows, err := d.DB.WithContext(ctx).Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	row := &dbRow{columns: columns, values: make([]interface{}, len(columns))}
+	dest := make([]interface{}, len(columns))
+	for i := range dest {
+		dest[i] = &row.values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
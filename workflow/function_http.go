@@ -0,0 +1,139 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTP API 函数执行器：把步骤的输入参数按参数名组成JSON请求体，POST 到函数对应的地址，
+// 响应的JSON对象按参数名映射为输出参数
+//
+//	请求: POST {BaseURL}/beiluo.test1.hr.create_user  {"username": "张三"}
+//	响应: {"userId": "U001", "err": null}
+//
+// 响应状态码不是2xx时步骤失败，错误码为 HTTP_状态码（如 HTTP_503），可以在 retry_on 中指定；
+// error 类型的返回值不为空时步骤失败。步骤的 timeout 和 retry 元数据由执行器处理，
+// 单次请求的超时取 Timeout 和步骤剩余超时中较小的一个。
+type HTTPFunctionExecutor struct {
+	BaseURL   string            // 函数地址的前缀，请求地址为 BaseURL/函数名
+	Endpoints map[string]string // 函数名 -> 完整请求地址，设置后不使用 BaseURL
+	Headers   map[string]string // 每个请求附带的请求头，如鉴权信息
+	Timeout   time.Duration     // 单次请求超时，0 表示30秒
+}
+
+// endpoint 返回函数的请求地址
+func (h *HTTPFunctionExecutor) endpoint(functionName string) string {
+	if url, exists := h.Endpoints[functionName]; exists {
+		return url
+	}
+	return strings.TrimSuffix(h.BaseURL, "/") + "/" + functionName
+}
+
+// httpResponse 函数的HTTP响应
+type httpResponse struct {
+	code int
+	body string
+}
+
+// post 发送JSON请求，请求在 ctx 取消或超时后中断，单次请求的超时不超过 Timeout
+func (h *HTTPFunctionExecutor) post(ctx context.Context, functionName string, body interface{}) (*httpResponse, error) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化函数 %s 的请求失败: %w", functionName, err)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, h.endpoint(functionName), bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("调用函数 %s 失败: %w", functionName, err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for key, value := range h.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		var respBody []byte
+		if respBody, err = io.ReadAll(resp.Body); err == nil {
+			return &httpResponse{code: resp.StatusCode, body: string(respBody)}, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, fmt.Errorf("调用函数 %s 失败: %w", functionName, err)
+}
+
+// ExecuteStep 按参数名调用函数，实现 StepFunctionExecutor
+func (h *HTTPFunctionExecutor) ExecuteStep(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+	body := make(map[string]interface{}, len(step.InputParams))
+	for _, param := range step.InputParams {
+		body[param.Name] = in.RealInput[param.Name]
+	}
+	resp, err := h.post(ctx, step.Function, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.code < 200 || resp.code >= 300 {
+		return &ExecutorOut{
+			Success:   false,
+			Error:     fmt.Sprintf("HTTP %d: %s", resp.code, strings.TrimSpace(resp.body)),
+			ErrorCode: fmt.Sprintf("HTTP_%d", resp.code),
+		}, nil
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.body), &response); err != nil {
+		return &ExecutorOut{Success: false, Error: fmt.Sprintf("解析函数 %s 的响应失败: %v", step.Function, err)}, nil
+	}
+	return mapOutput(step, response), nil
+}
+
+// Execute 按位置调用函数：请求体为参数数组，响应为返回值数组
+func (h *HTTPFunctionExecutor) Execute(functionName string, args []interface{}) ([]interface{}, error) {
+	resp, err := h.post(context.Background(), functionName, args)
+	if err != nil {
+		return nil, err
+	}
+	if resp.code < 200 || resp.code >= 300 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.code, strings.TrimSpace(resp.body))
+	}
+	var results []interface{}
+	if err := json.Unmarshal([]byte(resp.body), &results); err != nil {
+		return nil, fmt.Errorf("解析函数 %s 的响应失败: %w", functionName, err)
+	}
+	return results, nil
+}
+
+// mapOutput 把按名称返回的结果映射为步骤的输出参数：
+// error 类型的返回值不为空时步骤失败，其他返回值缺失时步骤失败
+func mapOutput(step SimpleStep, values map[string]interface{}) *ExecutorOut {
+	output := make(map[string]interface{}, len(step.OutputParams))
+	for _, param := range step.OutputParams {
+		value, exists := values[param.Name]
+		if param.Type == "error" {
+			if value != nil && value != "" {
+				return &ExecutorOut{Success: false, Error: fmt.Sprint(value)}
+			}
+			output[param.Name] = nil
+			continue
+		}
+		if !exists {
+			return &ExecutorOut{Success: false, Error: fmt.Sprintf("函数 %s 没有返回 %s", step.Function, param.Name)}
+		}
+		output[param.Name] = convertOutput(value, param.Type)
+	}
+	return &ExecutorOut{Success: true, WantOutput: output}
+}
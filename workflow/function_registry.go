@@ -1,7 +1,10 @@
 package workflow
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"sync"
 )
@@ -11,6 +14,14 @@ type FunctionExecutor interface {
 	Execute(functionName string, args []interface{}) ([]interface{}, error)
 }
 
+// StepFunctionExecutor 按参数名执行步骤的函数执行器，如 HTTPFunctionExecutor、DatabaseFunctionExecutor
+//
+// FunctionRegistry.OnFunctionCall 优先调用 ExecuteStep，参数和返回值按名称对应，并且可以感知步骤超时。
+type StepFunctionExecutor interface {
+	FunctionExecutor
+	ExecuteStep(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error)
+}
+
 // 函数注册表
 type FunctionRegistry struct {
 	functions map[string]FunctionExecutor
@@ -39,6 +50,44 @@ func (r *FunctionRegistry) GetExecutor(functionName string) (FunctionExecutor, b
 	return executor, exists
 }
 
+// OnFunctionCall 把注册的函数执行器适配为 Executor 的步骤回调：executor.OnFunctionCall = registry.OnFunctionCall
+//
+// 按步骤函数名（如 beiluo.test1.hr.create_user）查找执行器，没有时按步骤名（如 step1）查找。
+// 执行器实现了 StepFunctionExecutor 时调用 ExecuteStep，否则参数按步骤定义的输入参数顺序传入，返回值按位置对应输出参数；执行器返回错误，
+// 或 error 类型的返回值不为nil时步骤执行失败。
+func (r *FunctionRegistry) OnFunctionCall(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+	executor, exists := r.GetExecutor(step.Function)
+	if !exists {
+		if executor, exists = r.GetExecutor(step.Name); !exists {
+			return nil, fmt.Errorf("函数 %s 未注册执行器", step.Function)
+		}
+	}
+	if stepExecutor, ok := executor.(StepFunctionExecutor); ok {
+		return stepExecutor.ExecuteStep(ctx, step, in)
+	}
+
+	args := make([]interface{}, len(step.InputParams))
+	for i, param := range step.InputParams {
+		args[i] = in.RealInput[param.Name]
+	}
+	results, err := executor.Execute(step.Function, args)
+	if err != nil {
+		return &ExecutorOut{Success: false, Error: err.Error()}, nil
+	}
+	if len(results) != len(step.OutputParams) {
+		return nil, fmt.Errorf("函数 %s 返回%d个值，步骤定义了%d个返回值", step.Function, len(results), len(step.OutputParams))
+	}
+
+	output := make(map[string]interface{}, len(results))
+	for i, param := range step.OutputParams {
+		if resultErr, ok := results[i].(error); ok && resultErr != nil && param.Type == "error" {
+			return &ExecutorOut{Success: false, Error: resultErr.Error()}, nil
+		}
+		output[param.Name] = results[i]
+	}
+	return &ExecutorOut{Success: true, WantOutput: output}, nil
+}
+
 // 默认函数执行器 - 用于模拟执行
 type DefaultFunctionExecutor struct{}
 
@@ -56,58 +105,6 @@ func (d *DefaultFunctionExecutor) Execute(functionName string, args []interface{
 	}
 }
 
-// HTTP API 函数执行器
-type HTTPFunctionExecutor struct {
-	BaseURL string
-	Client  interface{} // 可以是 http.Client 或其他HTTP客户端
-}
-
-func (h *HTTPFunctionExecutor) Execute(functionName string, args []interface{}) ([]interface{}, error) {
-	// 这里实现真实的HTTP API调用
-	// 例如：POST /api/functions/{functionName}
-	// 发送 args 作为请求体
-	// 解析响应并返回结果
-
-	fmt.Printf("🌐 [HTTP调用] %s - 参数: %v\n", functionName, args)
-
-	// 模拟HTTP调用
-	switch functionName {
-	case "step1":
-		return []interface{}{"真实工号结果", "真实用户名结果", nil}, nil
-	case "step2":
-		return []interface{}{"真实面试时间结果", "真实面试官名称结果", nil}, nil
-	case "step3":
-		return []interface{}{nil}, nil
-	default:
-		return []interface{}{"真实结果", nil}, nil
-	}
-}
-
-// 数据库函数执行器
-type DatabaseFunctionExecutor struct {
-	DB interface{} // 数据库连接
-}
-
-func (d *DatabaseFunctionExecutor) Execute(functionName string, args []interface{}) ([]interface{}, error) {
-	// 这里实现数据库查询和存储
-	// 例如：根据 functionName 查询数据库中的函数定义
-	// 执行SQL或调用存储过程
-
-	fmt.Printf("🗄️ [数据库调用] %s - 参数: %v\n", functionName, args)
-
-	// 模拟数据库调用
-	switch functionName {
-	case "step1":
-		return []interface{}{"数据库工号结果", "数据库用户名结果", nil}, nil
-	case "step2":
-		return []interface{}{"数据库面试时间结果", "数据库面试官名称结果", nil}, nil
-	case "step3":
-		return []interface{}{nil}, nil
-	default:
-		return []interface{}{"数据库结果", nil}, nil
-	}
-}
-
 // 反射函数执行器 - 直接调用Go函数
 type ReflectionFunctionExecutor struct {
 	Functions map[string]interface{}
@@ -122,6 +119,9 @@ func (r *ReflectionFunctionExecutor) Execute(functionName string, args []interfa
 	// 使用反射调用函数
 	fnValue := reflect.ValueOf(fn)
 	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s 不是函数", functionName)
+	}
 
 	// 检查函数签名
 	if fnType.NumIn() != len(args) {
@@ -131,16 +131,9 @@ func (r *ReflectionFunctionExecutor) Execute(functionName string, args []interfa
 	// 转换参数类型
 	callArgs := make([]reflect.Value, len(args))
 	for i, arg := range args {
-		expectedType := fnType.In(i)
-		argValue := reflect.ValueOf(arg)
-
-		// 简单的类型转换
-		if argValue.Type() != expectedType {
-			if argValue.CanConvert(expectedType) {
-				argValue = argValue.Convert(expectedType)
-			} else {
-				return nil, fmt.Errorf("参数 %d 类型不匹配，期望 %s，实际 %s", i, expectedType, argValue.Type())
-			}
+		argValue, err := convertArg(arg, fnType.In(i))
+		if err != nil {
+			return nil, fmt.Errorf("函数 %s 第%d个参数: %w", functionName, i+1, err)
 		}
 		callArgs[i] = argValue
 	}
@@ -156,3 +149,71 @@ func (r *ReflectionFunctionExecutor) Execute(functionName string, args []interfa
 
 	return returnValues, nil
 }
+
+// convertArg 把工作流中的参数值转换为函数参数类型：
+// nil 转为零值，数值在整数和浮点数之间转换（浮点数有小数部分时不能转为整数），
+// 其他无法直接转换的值（如JSON解析出的 map[string]interface{}）通过JSON转换为结构体、切片等类型
+func convertArg(arg interface{}, want reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(want), nil
+	}
+	argValue := reflect.ValueOf(arg)
+	if argValue.Type().AssignableTo(want) {
+		return argValue, nil
+	}
+
+	if number, ok := toNumber(arg); ok {
+		switch want.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if !number.isInt {
+				if number.f != math.Trunc(number.f) {
+					return reflect.Value{}, fmt.Errorf("%v 不是整数，不能转换为 %s", arg, want)
+				}
+				number.i = int64(number.f)
+			}
+			return reflect.ValueOf(number.i).Convert(want), nil
+		case reflect.Float32, reflect.Float64:
+			return reflect.ValueOf(number.f).Convert(want), nil
+		case reflect.String:
+			// 数值直接 Convert 为字符串会得到对应的字符，不做转换
+			return reflect.Value{}, fmt.Errorf("类型不匹配，期望 %s，实际 %s", want, argValue.Type())
+		}
+	}
+	if argValue.Type().ConvertibleTo(want) {
+		return argValue.Convert(want), nil
+	}
+
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("类型不匹配，期望 %s，实际 %s", want, argValue.Type())
+	}
+	converted := reflect.New(want)
+	if err := json.Unmarshal(data, converted.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("类型不匹配，期望 %s，实际 %s", want, argValue.Type())
+	}
+	return converted.Elem(), nil
+}
+
+// convertOutput 按步骤定义的参数类型转换外部返回的值：JSON数值转为 int 或 float64，数据库返回的 []byte 转为字符串
+func convertOutput(value interface{}, paramType string) interface{} {
+	if data, ok := value.([]byte); ok {
+		value = string(data)
+	}
+	number, ok := toNumber(value)
+	if !ok {
+		return value
+	}
+	switch normalizeType(paramType) {
+	case "int":
+		if number.isInt {
+			return int(number.i)
+		}
+		if number.f == math.Trunc(number.f) {
+			return int(number.f)
+		}
+	case "float":
+		return number.f
+	}
+	return number.value()
+}
@@ -0,0 +1,269 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// registryTestExecutor 用函数实现的执行器
+type registryTestExecutor func(args []interface{}) ([]interface{}, error)
+
+func (f registryTestExecutor) Execute(functionName string, args []interface{}) ([]interface{}, error) {
+	return f(args)
+}
+
+// TestFunctionRegistry_OnFunctionCall 测试函数注册表作为执行器的步骤回调
+func TestFunctionRegistry_OnFunctionCall(t *testing.T) {
+	registry := NewFunctionRegistry()
+	registry.Register("beiluo.test1.hr.query_department", registryTestExecutor(func(args []interface{}) ([]interface{}, error) {
+		return []interface{}{args[0].(string) + "-研发", nil}, nil
+	}))
+	registry.Register("step2", registryTestExecutor(func(args []interface{}) ([]interface{}, error) {
+		if args[0] != "张三-研发" {
+			return nil, errors.New("部门不正确")
+		}
+		return []interface{}{"P001", nil}, nil
+	}))
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = registry.OnFunctionCall
+	result := NewSimpleParser().ParseWorkflow(storeTestCode)
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if variable, _ := result.GetVariable("档案编号"); variable.Value != "P001" {
+		t.Errorf("返回值映射不正确: %v", variable.Value)
+	}
+
+	// error 类型的返回值不为nil时步骤失败
+	registry.Register("step2", registryTestExecutor(func(args []interface{}) ([]interface{}, error) {
+		return []interface{}{"", errors.New("档案已存在")}, nil
+	}))
+	result = NewSimpleParser().ParseWorkflow(storeTestCode)
+	if err := executor.Start(context.Background(), result); err == nil || !strings.Contains(err.Error(), "档案已存在") {
+		t.Errorf("应返回步骤失败: %v", err)
+	}
+
+	// 返回值个数与步骤定义不一致
+	registry.Register("step2", registryTestExecutor(func(args []interface{}) ([]interface{}, error) {
+		return []interface{}{"P001"}, nil
+	}))
+	result = NewSimpleParser().ParseWorkflow(storeTestCode)
+	if err := executor.Start(context.Background(), result); err == nil || !strings.Contains(err.Error(), "返回1个值，步骤定义了2个返回值") {
+		t.Errorf("应报告返回值个数不一致: %v", err)
+	}
+}
+
+// TestHTTPFunctionExecutor 测试HTTP执行器按参数名请求函数、映射响应，并按错误码重试
+func TestHTTPFunctionExecutor(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string][]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests[r.URL.Path] = append(requests[r.URL.Path], body)
+		count := len(requests[r.URL.Path])
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/fn/beiluo.test1.hr.query_department":
+			if count == 1 {
+				http.Error(w, "服务暂不可用", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(w, `{"department": "%s-研发", "err": null}`, body["username"])
+		case "/profile":
+			fmt.Fprint(w, `{"profileId": "P001"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	registry := NewFunctionRegistry()
+	httpExecutor := &HTTPFunctionExecutor{
+		BaseURL:   server.URL + "/fn/",
+		Endpoints: map[string]string{"beiluo.test1.hr.create_profile": server.URL + "/profile"},
+	}
+	registry.Register("beiluo.test1.hr.query_department", httpExecutor)
+	registry.Register("beiluo.test1.hr.create_profile", httpExecutor)
+	executor := NewExecutor()
+	executor.OnFunctionCall = registry.OnFunctionCall
+
+	code := strings.Replace(storeTestCode, `retry_on: [timeout, "E500"]`, `retry_on: ["HTTP_503"], retry_initial: 1`, 1)
+	result := NewSimpleParser().ParseWorkflow(code)
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if variable, _ := result.GetVariable("档案编号"); variable.Value != "P001" {
+		t.Errorf("响应映射不正确: %v", variable.Value)
+	}
+	if got := requests["/profile"]; len(got) != 1 || got[0]["department"] != "张三-研发" {
+		t.Errorf("请求参数不正确: %v", got)
+	}
+	if got := requests["/fn/beiluo.test1.hr.query_department"]; len(got) != 2 {
+		t.Errorf("HTTP 503 应重试一次: %v", got)
+	}
+
+	// 函数地址不存在
+	registry.Register("beiluo.test1.hr.create_profile", &HTTPFunctionExecutor{BaseURL: server.URL + "/fn"})
+	result = NewSimpleParser().ParseWorkflow(storeTestCode)
+	if err := executor.Start(context.Background(), result); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("应返回HTTP错误: %v", err)
+	}
+
+	// 请求超过 Timeout
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, `{"department": "研发"}`)
+	}))
+	defer slow.Close()
+	out, err := (&HTTPFunctionExecutor{BaseURL: slow.URL, Timeout: 20 * time.Millisecond}).ExecuteStep(context.Background(), *result.Steps[0], &ExecutorIn{})
+	if err == nil {
+		t.Errorf("请求应超时: %+v", out)
+	}
+
+	// 步骤超时或工作流取消时中断进行中的请求
+	aborted := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才能发现客户端断开连接
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer hanging.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := (&HTTPFunctionExecutor{BaseURL: hanging.URL, Timeout: 5 * time.Second}).ExecuteStep(ctx, *result.Steps[0], &ExecutorIn{}); !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+		t.Errorf("取消后应立即返回: %v %v", err, time.Since(start))
+	}
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Error("ctx 结束后请求应被中断")
+	}
+}
+
+// TestReflectionFunctionExecutor 测试反射执行器的参数类型转换
+func TestReflectionFunctionExecutor(t *testing.T) {
+	type profile struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	executor := &ReflectionFunctionExecutor{Functions: map[string]interface{}{
+		"create": func(p profile, level int64, score float32, tags []string) (string, error) {
+			return fmt.Sprintf("%s-%d-%d-%.1f-%v", p.Name, p.Age, level, score, tags), nil
+		},
+	}}
+
+	results, err := executor.Execute("create", []interface{}{
+		map[string]interface{}{"name": "张三", "age": float64(30)},
+		float64(3),
+		2,
+		[]interface{}{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("调用失败: %v", err)
+	}
+	if results[0] != "张三-30-3-2.0-[a b]" || results[1] != nil {
+		t.Errorf("返回值不正确: %v", results)
+	}
+
+	if results, err = executor.Execute("create", []interface{}{nil, nil, nil, nil}); err != nil || results[0] != "-0-0-0.0-[]" {
+		t.Errorf("nil 应转为零值: %v %v", results, err)
+	}
+	if _, err := executor.Execute("create", []interface{}{nil, 1.5, nil, nil}); err == nil || !strings.Contains(err.Error(), "第2个参数") {
+		t.Errorf("小数不能转为整数: %v", err)
+	}
+	if _, err := executor.Execute("create", []interface{}{nil, "三", nil, nil}); err == nil {
+		t.Error("字符串不能转为整数")
+	}
+}
+
+// TestDatabaseFunctionExecutor 测试数据库执行器按参数名执行SQL并映射查询结果
+func TestDatabaseFunctionExecutor(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE users (name TEXT, department TEXT)",
+		"CREATE TABLE profiles (id INTEGER PRIMARY KEY AUTOINCREMENT, department TEXT)",
+		"INSERT INTO users VALUES ('张三', '研发')",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("初始化数据库失败: %v", err)
+		}
+	}
+
+	registry := NewFunctionRegistry()
+	dbExecutor := &DatabaseFunctionExecutor{DB: db, Queries: map[string]string{
+		"beiluo.test1.hr.query_department": "SELECT department FROM users WHERE name = @username",
+		"beiluo.test1.hr.create_profile":   "SELECT 'P' || COUNT(*) AS profileId FROM users WHERE department = @department",
+	}}
+	registry.Register("beiluo.test1.hr.query_department", dbExecutor)
+	registry.Register("beiluo.test1.hr.create_profile", dbExecutor)
+	executor := NewExecutor()
+	executor.OnFunctionCall = registry.OnFunctionCall
+
+	result := NewSimpleParser().ParseWorkflow(storeTestCode)
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if variable, _ := result.GetVariable("档案编号"); variable.Value != "P1" {
+		t.Errorf("查询结果映射不正确: %v", variable.Value)
+	}
+
+	// 没有查询结果
+	result = NewSimpleParser().ParseWorkflow(strings.Replace(storeTestCode, `"张三"`, `"李四"`, 1))
+	if err := executor.Start(context.Background(), result); err == nil || !strings.Contains(err.Error(), "查询无结果") {
+		t.Errorf("应返回查询无结果: %v", err)
+	}
+
+	// 只返回 error 时执行更新语句
+	out, err := dbExecutor.ExecuteStep(context.Background(), SimpleStep{
+		Function:     "insert",
+		InputParams:  []ParameterInfo{{Name: "department", Type: "string"}},
+		OutputParams: []ParameterInfo{{Name: "err", Type: "error"}},
+	}, &ExecutorIn{RealInput: map[string]interface{}{"department": "研发"}})
+	if err == nil {
+		t.Errorf("未配置SQL的函数应返回错误: %+v", out)
+	}
+	dbExecutor.Queries["insert"] = "INSERT INTO profiles (department) VALUES (@department)"
+	out, err = dbExecutor.ExecuteStep(context.Background(), SimpleStep{
+		Function:     "insert",
+		InputParams:  []ParameterInfo{{Name: "department", Type: "string"}},
+		OutputParams: []ParameterInfo{{Name: "err", Type: "error"}},
+	}, &ExecutorIn{RealInput: map[string]interface{}{"department": "研发"}})
+	if err != nil || !out.Success {
+		t.Fatalf("执行更新语句失败: %+v %v", out, err)
+	}
+	results, err := dbExecutor.Execute("count", nil)
+	if err == nil {
+		t.Errorf("未配置SQL的函数应返回错误: %v", results)
+	}
+	dbExecutor.Queries["count"] = "SELECT COUNT(*), MAX(department) FROM profiles WHERE department = ?"
+	if results, err = dbExecutor.Execute("count", []interface{}{"研发"}); err != nil || results[0] != int64(1) || results[1] != "研发" {
+		t.Errorf("按位置查询结果不正确: %v %v", results, err)
+	}
+}
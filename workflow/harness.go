@@ -0,0 +1,298 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockResult 测试中步骤回调的模拟结果
+type MockResult struct {
+	Output    map[string]interface{} // 成功时的输出参数，未设置的 error 类型返回值默认为 nil
+	Error     string                 // 业务失败的错误信息，设置 Error 或 ErrorCode 时步骤执行失败
+	ErrorCode string                 // 业务错误码，用于测试 retry_on
+	Err       error                  // 回调返回的错误
+	Timeout   bool                   // 一直阻塞到步骤超时或工作流取消，用于测试 timeout 元数据
+	Delay     time.Duration          // 返回结果前等待的时间
+	Nil       bool                   // 回调返回 (nil, nil)，执行器按步骤失败处理，用于回放录制的空结果
+}
+
+// TestingT 断言方法需要的测试接口，*testing.T 和 *testing.B 都实现了该接口
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Harness 工作流测试工具：为步骤设置模拟结果，执行工作流后检查执行路径、变量和语句状态
+//
+//	h := workflow.NewHarness()
+//	h.On("step1", workflow.MockResult{Output: map[string]interface{}{"department": "研发"}})
+//	h.On("step2", workflow.MockResult{ErrorCode: "E500"}, workflow.MockResult{Output: ...}) // 第1次失败，第2次起成功
+//	result, err := h.Run(ctx, code)
+//	h.AssertPath(t, "step1", "step2", "step2")
+//	h.AssertVariable(t, "部门", "研发")
+type Harness struct {
+	// 执行工作流的执行器，OnFunctionCall 由 Harness 设置，其他回调、Store、Registry 可以按需设置
+	Executor *Executor
+
+	mu     sync.Mutex
+	mocks  map[string][]MockResult    // 步骤名 -> 按调用次数的模拟结果，超出时使用最后一个
+	replay map[string][]*RecordedCall // 步骤名 -> 录制的回调，按调用次数依次回放
+	counts map[string]int             // 步骤名 -> 已调用次数
+	calls  []*RecordedCall
+	result *SimpleParseResult
+}
+
+// NewHarness 创建测试工具
+func NewHarness() *Harness {
+	h := &Harness{
+		Executor: NewExecutor(),
+		mocks:    make(map[string][]MockResult),
+		replay:   make(map[string][]*RecordedCall),
+		counts:   make(map[string]int),
+	}
+	h.Executor.OnFunctionCall = h.call
+	return h
+}
+
+// On 设置步骤的模拟结果，第 n 次调用返回 results[n]，超出时返回最后一个
+func (h *Harness) On(step string, results ...MockResult) *Harness {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mocks[step] = results
+	return h
+}
+
+// Replay 按录制结果回放步骤回调：每个步骤按调用顺序返回录制的结果
+//
+// 回放时步骤的实际输入与录制时不一致，说明执行已偏离录制的流程，回调返回错误。
+func (h *Harness) Replay(recording *Recording) *Harness {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, call := range recording.Calls {
+		h.replay[call.Step] = append(h.replay[call.Step], call)
+	}
+	return h
+}
+
+// Run 解析并执行工作流，返回执行后的工作流和 Executor.Start 的错误
+func (h *Harness) Run(ctx context.Context, code string) (*SimpleParseResult, error) {
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		return result, fmt.Errorf("解析失败: %s", result.Error)
+	}
+	h.mu.Lock()
+	h.result = result
+	h.mu.Unlock()
+	return result, h.Executor.Start(ctx, result)
+}
+
+// call 返回步骤的模拟结果或回放结果，并记录调用
+func (h *Harness) call(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+	h.mu.Lock()
+	index := h.counts[step.Name]
+	h.counts[step.Name]++
+	recorded := &RecordedCall{Step: step.Name, Function: step.Function, Input: copyMetadata(in.RealInput)}
+	h.calls = append(h.calls, recorded)
+	mock, err := h.lookup(step, in, index)
+	h.mu.Unlock()
+	if err != nil {
+		recorded.Error = err.Error()
+		return nil, err
+	}
+
+	start := time.Now()
+	out, err := mock.result(ctx, step)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	recorded.Output = out
+	recorded.Duration = time.Since(start)
+	if err != nil {
+		recorded.Error = err.Error()
+		recorded.Timeout = mock.Timeout
+	} else if out == nil {
+		recorded.NilOutput = true
+	}
+	return out, err
+}
+
+// lookup 查找第 index 次调用的模拟结果，需持有 h.mu
+func (h *Harness) lookup(step SimpleStep, in *ExecutorIn, index int) (MockResult, error) {
+	if results := h.mocks[step.Name]; len(results) > 0 {
+		return results[min(index, len(results)-1)], nil
+	}
+
+	calls, exists := h.replay[step.Name]
+	if !exists {
+		return MockResult{}, fmt.Errorf("步骤 %s 未设置模拟结果", step.Name)
+	}
+	if index >= len(calls) {
+		return MockResult{}, fmt.Errorf("回放不一致: 步骤 %s 第%d次调用，录制时只调用了%d次", step.Name, index+1, len(calls))
+	}
+	call := calls[index]
+	if !sameJSON(in.RealInput, call.Input) {
+		return MockResult{}, fmt.Errorf("回放不一致: 步骤 %s 第%d次调用的输入为 %v，录制时为 %v", step.Name, index+1, in.RealInput, call.Input)
+	}
+
+	mock := MockResult{Timeout: call.Timeout}
+	switch {
+	case call.Timeout:
+	case call.Error != "":
+		mock.Err = errors.New(call.Error)
+	case call.NilOutput, call.Output == nil:
+		mock.Nil = true
+	case call.Output.Success:
+		mock.Output = call.Output.WantOutput
+	default:
+		mock.Error, mock.ErrorCode = call.Output.Error, call.Output.ErrorCode
+		if mock.Error == "" && mock.ErrorCode == "" {
+			mock.Error = "步骤执行失败"
+		}
+	}
+	return mock, nil
+}
+
+// result 按模拟结果返回回调的输出
+func (m MockResult) result(ctx context.Context, step SimpleStep) (*ExecutorOut, error) {
+	if m.Timeout {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if m.Delay > 0 {
+		if err := sleepContext(ctx, m.Delay); err != nil {
+			return nil, err
+		}
+	}
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	if m.Nil {
+		return nil, nil
+	}
+	if m.Error != "" || m.ErrorCode != "" {
+		return &ExecutorOut{Success: false, Error: m.Error, ErrorCode: m.ErrorCode}, nil
+	}
+
+	output := copyMetadata(m.Output)
+	if output == nil {
+		output = make(map[string]interface{})
+	}
+	for _, param := range step.OutputParams {
+		if _, exists := output[param.Name]; !exists && param.Type == "error" {
+			output[param.Name] = nil
+		}
+	}
+	return &ExecutorOut{Success: true, WantOutput: output}, nil
+}
+
+// sameJSON 比较两个值序列化为JSON后是否相同，用于比较回放时的输入与录制的输入
+func sameJSON(a, b interface{}) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(dataA) == string(dataB)
+}
+
+// Calls 返回所有步骤回调，按调用顺序排列
+func (h *Harness) Calls() []*RecordedCall {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*RecordedCall(nil), h.calls...)
+}
+
+// Path 返回按调用顺序排列的步骤名，重试的步骤出现多次
+func (h *Harness) Path() []string {
+	calls := h.Calls()
+	path := make([]string, len(calls))
+	for i, call := range calls {
+		path[i] = call.Step
+	}
+	return path
+}
+
+// Recording 返回最近一次执行的回调，可以保存为回放文件或测试的基准
+func (h *Harness) Recording() *Recording {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	recording := &Recording{Calls: append([]*RecordedCall(nil), h.calls...)}
+	if h.result != nil {
+		recording.FlowID = h.result.FlowID
+	}
+	return recording
+}
+
+// AssertPath 检查步骤的调用顺序
+func (h *Harness) AssertPath(t TestingT, steps ...string) {
+	t.Helper()
+	if path := h.Path(); !reflect.DeepEqual(path, steps) && !(len(path) == 0 && len(steps) == 0) {
+		t.Errorf("执行路径为 %s，期望 %s", strings.Join(path, " -> "), strings.Join(steps, " -> "))
+	}
+}
+
+// AssertVariable 检查最近一次执行后变量的值
+func (h *Harness) AssertVariable(t TestingT, name string, want interface{}) {
+	t.Helper()
+	result := h.lastResult(t)
+	if result == nil {
+		return
+	}
+	variable, exists := result.GetVariable(name)
+	if !exists {
+		t.Errorf("变量 %s 未赋值", name)
+		return
+	}
+	if !reflect.DeepEqual(variable.Value, want) {
+		t.Errorf("变量 %s 的值为 %v (%T)，期望 %v (%T)", name, variable.Value, variable.Value, want, want)
+	}
+}
+
+// AssertStatus 检查最近一次执行后第 line 行语句的状态，循环体中的语句检查最后一次迭代
+func (h *Harness) AssertStatus(t TestingT, line int, want StatementStatus) {
+	t.Helper()
+	result := h.lastResult(t)
+	if result == nil {
+		return
+	}
+	stmt := findStatement(result.MainFunc.Statements, line)
+	if stmt == nil {
+		t.Errorf("第%d行没有语句", line)
+		return
+	}
+	if stmt.Status != want {
+		t.Errorf("第%d行语句 %s 的状态为 %s，期望 %s", line, firstLine(stmt.Content), stmt.Status, want)
+	}
+}
+
+func (h *Harness) lastResult(t TestingT) *SimpleParseResult {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.result == nil || h.result.MainFunc == nil {
+		t.Errorf("工作流尚未执行")
+		return nil
+	}
+	return h.result
+}
+
+// findStatement 查找第 line 行的语句，执行过的循环在最后一次迭代中查找
+func findStatement(statements []*SimpleStatement, line int) *SimpleStatement {
+	for _, stmt := range statements {
+		if stmt.LineNumber == line && stmt.Type != "branch" {
+			return stmt
+		}
+		children := stmt.Children
+		if stmt.Loop != nil && len(stmt.Loop.Iterations) > 0 {
+			children = stmt.Loop.Iterations[len(stmt.Loop.Iterations)-1].Statements
+		}
+		for _, group := range [][]*SimpleStatement{children, stmt.Branches} {
+			if found := findStatement(group, line); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package workflow
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const harnessTestCode = `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = beiluo.test1.hr.query_department(username: string "用户名") -> (department: string "部门", err: error "是否失败");
+step2 = beiluo.test1.hr.create_profile(department: string "部门") -> (profileId: string "档案编号", err: error "是否失败");
+
+func main() {
+    部门, step1Err := step1(input["用户名"])
+    if 部门 == "研发" {
+        档案编号, step2Err := step2(部门){retry: 1, retry_initial: 1}
+        sys.Printf("档案编号: %s", 档案编号)
+    }
+    sys.Println("完成")
+}`
+
+// TestHarness 测试按调用次数设置模拟结果并检查执行路径、变量和状态
+func TestHarness(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"department": "研发"}})
+	h.On("step2", MockResult{ErrorCode: "E500", Error: "服务不可用"}, MockResult{Output: map[string]interface{}{"profileId": "P001"}})
+
+	if _, err := h.Run(context.Background(), harnessTestCode); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	h.AssertPath(t, "step1", "step2", "step2")
+	h.AssertVariable(t, "部门", "研发")
+	h.AssertVariable(t, "档案编号", "P001")
+	h.AssertVariable(t, "step2Err", nil)
+	h.AssertStatus(t, 11, StatusCompleted)
+	if calls := h.Calls(); calls[1].Input["department"] != "研发" || calls[1].Output.ErrorCode != "E500" {
+		t.Errorf("调用记录不正确: %+v", calls[1])
+	}
+
+	// 另一个分支
+	h = NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"department": "销售"}})
+	if _, err := h.Run(context.Background(), harnessTestCode); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	h.AssertPath(t, "step1")
+	h.AssertStatus(t, 11, StatusSkipped)
+
+	// 未设置模拟结果的步骤执行失败
+	h = NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"department": "研发"}})
+	if _, err := h.Run(context.Background(), harnessTestCode); err == nil || !strings.Contains(err.Error(), "步骤 step2 未设置模拟结果") {
+		t.Errorf("应报告未设置模拟结果: %v", err)
+	}
+}
+
+// TestHarness_Replay 测试录制真实回调后离线回放
+func TestHarness_Replay(t *testing.T) {
+	// 录制：业务回调第一次调用 step2 失败，重试后成功
+	attempts := 0
+	executor := NewExecutor()
+	recorder := NewRecorder(func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		if step.Name == "step1" {
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"department": "研发", "err": nil}}, nil
+		}
+		attempts++
+		if attempts == 1 {
+			return &ExecutorOut{Success: false, Error: "服务不可用", ErrorCode: "E500"}, nil
+		}
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"profileId": "P001", "err": nil}}, nil
+	})
+	executor.OnFunctionCall = recorder.OnFunctionCall
+	result := NewSimpleParser().ParseWorkflow(harnessTestCode)
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+	if err := recorder.Recording(result.FlowID).Save(path); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	recording, err := LoadRecording(path)
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if len(recording.Calls) != 3 || recording.FlowID != result.FlowID {
+		t.Fatalf("录制结果不正确: %+v", recording)
+	}
+
+	// 回放：不需要业务回调即可重现相同的执行过程
+	h := NewHarness().Replay(recording)
+	replayed, err := h.Run(context.Background(), harnessTestCode)
+	if err != nil {
+		t.Fatalf("回放失败: %v", err)
+	}
+	h.AssertPath(t, "step1", "step2", "step2")
+	h.AssertVariable(t, "档案编号", "P001")
+	if len(replayed.Steps[1].Logs) != 2 || replayed.Steps[1].Logs[0].Message != result.Steps[1].Logs[0].Message {
+		t.Errorf("回放的重试日志与录制时不一致: %+v", replayed.Steps[1].Logs)
+	}
+
+	// 工作流修改后输入与录制时不一致，step2 重试一次后失败
+	h = NewHarness().Replay(recording)
+	_, err = h.Run(context.Background(), strings.Replace(harnessTestCode, `step2(部门)`, `step2("测试")`, 1))
+	if err == nil || !strings.Contains(err.Error(), "回放不一致: 步骤 step2 第2次调用的输入") {
+		t.Errorf("应报告回放不一致: %v", err)
+	}
+}
+
+// TestRecorder_NilOutput 测试回调返回 (nil, nil) 的录制和回放，以及取走录制结果
+func TestRecorder_NilOutput(t *testing.T) {
+	recorder := NewRecorder(func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return nil, nil
+	})
+	executor := NewExecutor()
+	executor.OnFunctionCall = recorder.OnFunctionCall
+	result := NewSimpleParser().ParseWorkflow(harnessTestCode)
+	recordErr := executor.Start(context.Background(), result)
+	if recordErr == nil {
+		t.Fatal("回调返回空结果时步骤应失败")
+	}
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+	if err := recorder.Take(result.FlowID).Save(path); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if recording := recorder.Recording(result.FlowID); len(recording.Calls) != 0 {
+		t.Errorf("Take 后录制结果应被删除: %+v", recording.Calls)
+	}
+	recording, err := LoadRecording(path)
+	if err != nil || len(recording.Calls) != 1 || !recording.Calls[0].NilOutput {
+		t.Fatalf("应录制空结果: %+v %v", recording, err)
+	}
+
+	// 回放时同样返回空结果，工作流以相同的错误失败
+	h := NewHarness().Replay(recording)
+	if _, err := h.Run(context.Background(), harnessTestCode); err == nil || err.Error() != recordErr.Error() {
+		t.Errorf("回放结果与录制时不一致: %v，录制时: %v", err, recordErr)
+	}
+	h.AssertPath(t, "step1")
+	if calls := h.Calls(); !calls[0].NilOutput {
+		t.Errorf("回放的调用应记录空结果: %+v", calls[0])
+	}
+
+	recorder.Reset()
+	if len(recorder.recordings) != 0 {
+		t.Errorf("Reset 后不应有录制结果: %v", recorder.recordings)
+	}
+}
@@ -502,8 +502,8 @@ func main() {
 	}
 
 	// 第二个步骤是 step2 调用
-	if result.MainFunc.Statements[3].Status != "failed" {
-		t.Errorf("第二个步骤状态不正确: 期望 failed, 实际 %s", result.MainFunc.Statements[3].Status)
+	if result.MainFunc.Statements[4].Status != "failed" {
+		t.Errorf("第二个步骤状态不正确: 期望 failed, 实际 %s", result.MainFunc.Statements[4].Status)
 	}
 
 	t.Logf("✅ 错误恢复测试通过")
@@ -0,0 +1,341 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// 当前迭代在上下文中的键，打印语句等通过它把日志记录到迭代上
+type loopIterationKey struct{}
+
+// iterationFromContext 获取当前正在执行的循环迭代，不在循环中时返回nil
+func iterationFromContext(ctx context.Context) *LoopIteration {
+	iteration, _ := ctx.Value(loopIterationKey{}).(*LoopIteration)
+	return iteration
+}
+
+// range 的单个元素
+type rangeItem struct {
+	key   interface{}
+	value interface{}
+}
+
+// executeForStatement 执行for语句，每次迭代在循环体副本上执行，迭代结束后恢复循环作用域内的变量
+//
+// 从存储恢复执行时保留已完成的迭代，未完成的迭代在保存的语句上继续执行。
+func (e *Executor) executeForStatement(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
+	loop := stmt.Loop
+	if loop == nil {
+		return fmt.Errorf("第%d行for语句缺少循环信息", stmt.LineNumber)
+	}
+
+	run := flowRunFromContext(ctx)
+	resuming := run != nil && run.resuming && len(loop.Iterations) > 0
+
+	// 1. 保存循环作用域内变量的外层值，循环结束后恢复
+	// 恢复执行时变量可能是未完成迭代中赋的值，在循环内赋值的不是外层的值
+	scoped := loopScopedNames(stmt)
+	outer := make(map[string]VariableInfo, len(scoped))
+	first, last := stmt.LineNumber, lastLine(stmt)
+	for _, name := range scoped {
+		if info, exists := workflow.GetVariable(name); exists && !(resuming && info.LineNum >= first && info.LineNum <= last) {
+			outer[name] = info
+		}
+	}
+	defer func() {
+		for _, name := range scoped {
+			if info, exists := outer[name]; exists {
+				workflow.SetVariable(name, info)
+			} else {
+				workflow.DeleteVariable(name)
+			}
+		}
+	}()
+
+	next, current := 0, (*LoopIteration)(nil)
+	if resuming {
+		next, current = resumeIterations(loop)
+		// 中断时作用域内的变量已恢复为外层的值，未完成的迭代按中断时记录的变量继续
+		if current != nil {
+			for name, info := range current.Variables {
+				workflow.SetVariable(name, info)
+			}
+		}
+	} else {
+		loop.Total = 0
+		loop.Completed = 0
+		loop.Iterations = nil
+	}
+
+	// 2. 执行迭代
+	var flow error
+	switch loop.Kind {
+	case LoopKindRange:
+		value, err := EvaluateExpression(loop.RangeExpr, stmt.LineNumber, workflow)
+		if err != nil {
+			return fmt.Errorf("range表达式求值失败: %w", err)
+		}
+		count, itemAt, err := rangeItems(value)
+		if err != nil {
+			return fmt.Errorf("第%d行: %v", stmt.LineNumber, err)
+		}
+		// 整数range与条件循环一样受最大迭代次数保护，列表和map的大小由数据本身决定
+		if _, isNumber := toNumber(value); isNumber && count > stmt.GetMaxIterations() {
+			return fmt.Errorf("第%d行range次数 %d 超过最大迭代次数 %d", stmt.LineNumber, count, stmt.GetMaxIterations())
+		}
+		loop.Total = count
+
+		for i := next; i < count; i++ {
+			item := itemAt(i)
+			e.setLoopVariable(ctx, workflow, stmt, loop.KeyVar, item.key)
+			e.setLoopVariable(ctx, workflow, stmt, loop.ValueVar, item.value)
+			iteration := current
+			if i != next || iteration == nil {
+				iteration = newIteration(stmt, i)
+			}
+			iteration.Key, iteration.Value = item.key, item.value
+			stop, err := e.executeIteration(ctx, stmt, workflow, iteration, scoped)
+			if err != nil {
+				return err
+			}
+			if stop != nil {
+				flow = stop
+				break
+			}
+		}
+	default:
+		maxIterations := stmt.GetMaxIterations()
+		for i := next; ; i++ {
+			iteration := current
+			// 恢复执行的迭代在开始时已满足条件，不再重新判断
+			if i != next || iteration == nil {
+				if stmt.Condition != "" {
+					ok, err := e.evaluateCondition(stmt, workflow)
+					if err != nil {
+						return fmt.Errorf("循环条件评估失败: %w", err)
+					}
+					if !ok {
+						break
+					}
+				}
+				if i >= maxIterations {
+					return fmt.Errorf("第%d行循环超过最大迭代次数 %d", stmt.LineNumber, maxIterations)
+				}
+				iteration = newIteration(stmt, i)
+				iteration.Key = i
+			}
+			stop, err := e.executeIteration(ctx, stmt, workflow, iteration, scoped)
+			if err != nil {
+				return err
+			}
+			if stop != nil {
+				flow = stop
+				break
+			}
+		}
+	}
+
+	// 3. 更新语句状态为完成
+	stmt.Status = "completed"
+
+	// 4. 触发状态更新回调
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return err
+	}
+
+	// break 只结束当前循环，return 继续向上传递
+	if errors.Is(flow, errFlowReturn) {
+		return flow
+	}
+	return nil
+}
+
+// newIteration 在循环体副本上创建第 index 次迭代并加入迭代记录
+func newIteration(stmt *SimpleStatement, index int) *LoopIteration {
+	iteration := &LoopIteration{
+		Index:      index,
+		Statements: cloneStatements(stmt.Children),
+		Variables:  make(map[string]VariableInfo),
+		Logs:       make([]*StepLog, 0),
+	}
+	stmt.Loop.Iterations = append(stmt.Loop.Iterations, iteration)
+	return iteration
+}
+
+// resumeIterations 恢复执行时保留已完成的迭代，返回下一次迭代的序号和未完成的迭代（没有时为nil）
+func resumeIterations(loop *LoopInfo) (int, *LoopIteration) {
+	completed := 0
+	for completed < len(loop.Iterations) && loop.Iterations[completed].Status == StatusCompleted {
+		completed++
+	}
+	loop.Completed = completed
+	if completed == len(loop.Iterations) {
+		return completed, nil
+	}
+	loop.Iterations = loop.Iterations[:completed+1]
+	return completed, loop.Iterations[completed]
+}
+
+// executeIteration 执行一次迭代，返回值 stop 非nil表示需要结束循环（break/return）
+func (e *Executor) executeIteration(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult, iteration *LoopIteration, scoped []string) (stop error, err error) {
+	loop := stmt.Loop
+
+	// 检查取消信号
+	select {
+	case <-ctx.Done():
+		return nil, interruptedError(ctx, stmt, "循环执行")
+	default:
+	}
+
+	// 恢复执行的迭代保留开始时间和日志
+	if iteration.StartTime == nil {
+		now := time.Now()
+		iteration.StartTime = &now
+	}
+	iteration.Status = StatusRunning
+	iteration.EndTime = nil
+
+	// 触发状态更新回调
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	// 执行循环体副本
+	iterationCtx := context.WithValue(ctx, loopIterationKey{}, iteration)
+	execErr := e.executeChildren(iterationCtx, iteration.Statements, workflow)
+
+	// 等待本次迭代内启动的异步步骤，作用域内的变量在迭代结束后会被覆盖或恢复
+	if err := flowRunFromContext(ctx).wait(scoped); err != nil && execErr == nil {
+		execErr = err
+	}
+
+	// 记录迭代结果
+	end := time.Now()
+	iteration.EndTime = &end
+	iteration.Duration = end.Sub(*iteration.StartTime)
+	for _, name := range scoped {
+		if info, exists := workflow.GetVariable(name); exists {
+			iteration.Variables[name] = info
+		}
+	}
+
+	if execErr != nil && !isControlFlow(execErr) {
+		iteration.Status = StatusFailed
+		if ctx.Err() != nil {
+			iteration.Status = "cancelled"
+		}
+		return nil, execErr
+	}
+
+	iteration.Status = StatusCompleted
+	loop.Completed++
+
+	// 触发状态更新回调，业务侧可据此展示 Completed/Total 进度
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	if errors.Is(execErr, errLoopBreak) || errors.Is(execErr, errFlowReturn) {
+		return execErr, nil
+	}
+	return nil, nil
+}
+
+// setLoopVariable 设置range的下标或元素变量，_ 和空名称忽略
+func (e *Executor) setLoopVariable(ctx context.Context, workflow *SimpleParseResult, stmt *SimpleStatement, name string, value interface{}) {
+	if name == "" || name == "_" {
+		return
+	}
+	e.setVariable(ctx, workflow, VariableInfo{
+		Name:    name,
+		Type:    typeName(value),
+		Value:   value,
+		Source:  "range",
+		LineNum: stmt.LineNumber,
+		IsInput: false,
+	})
+}
+
+// rangeItems 返回range目标的元素个数和按下标取元素的函数，支持切片、数组、map（按键排序）和整数
+//
+// 整数按下标生成元素，不预先分配；负数与Go一样不执行迭代。
+func rangeItems(value interface{}) (int, func(i int) rangeItem, error) {
+	if value == nil {
+		return 0, nil, nil
+	}
+	if num, ok := toNumber(value); ok {
+		if !num.isInt {
+			return 0, nil, fmt.Errorf("无法对 %s 进行range", typeName(value))
+		}
+		return int(max(num.i, 0)), func(i int) rangeItem { return rangeItem{key: i, value: i} }, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return rv.Len(), func(i int) rangeItem { return rangeItem{key: i, value: rv.Index(i).Interface()} }, nil
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		return len(keys), func(i int) rangeItem {
+			return rangeItem{key: keys[i].Interface(), value: rv.MapIndex(keys[i]).Interface()}
+		}, nil
+	default:
+		return 0, nil, fmt.Errorf("无法对 %s 进行range", typeName(value))
+	}
+}
+
+// lastLine 返回语句及其嵌套语句中最大的行号
+func lastLine(stmt *SimpleStatement) int {
+	line := stmt.LineNumber
+	for _, group := range [][]*SimpleStatement{stmt.Children, stmt.Branches} {
+		for _, child := range group {
+			line = max(line, lastLine(child))
+		}
+	}
+	return line
+}
+
+// loopScopedNames 返回循环作用域内的变量：range变量和循环体内声明的变量
+func loopScopedNames(stmt *SimpleStatement) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if name != "" && name != "_" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if stmt.Loop != nil {
+		add(stmt.Loop.KeyVar)
+		add(stmt.Loop.ValueVar)
+	}
+
+	var walk func(statements []*SimpleStatement)
+	walk = func(statements []*SimpleStatement) {
+		for _, child := range statements {
+			for _, ret := range child.Returns {
+				add(ret.Value)
+			}
+			if child.Type == "var" {
+				if matches := varAssignmentRegex.FindStringSubmatch(child.Content); len(matches) == 3 {
+					add(matches[1])
+				}
+			}
+			if child.Loop != nil {
+				add(child.Loop.KeyVar)
+				add(child.Loop.ValueVar)
+			}
+			walk(child.Children)
+			walk(child.Branches)
+		}
+	}
+	walk(stmt.Children)
+	return names
+}
@@ -0,0 +1,270 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestExecutor_RangeLoop 测试对步骤返回的列表逐个调用步骤
+func TestExecutor_RangeLoop(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "职位": "工程师",
+}
+
+step1 = beiluo.test1.crm.list_candidates(position: string "职位") -> (candidates: []string "候选人列表", err: error "是否失败");
+step2 = beiluo.test1.crm.schedule_interview(name: string "候选人") -> (time: string "面试时间", err: error "是否失败");
+
+func main() {
+    候选人列表, step1Err := step1(input["职位"])
+    //desc: 逐个安排面试
+    for i, 候选人 := range 候选人列表 {
+        面试时间, step2Err := step2(候选人)
+        step2.Printf("%d: %s 面试时间 %s", i, 候选人, 面试时间)
+    }
+    sys.Println("完成")
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	forStmt := result.MainFunc.Statements[1]
+	if forStmt.Type != "for" || forStmt.Loop == nil || forStmt.Loop.Kind != LoopKindRange {
+		t.Fatalf("for语句解析不正确: %+v", forStmt)
+	}
+	if forStmt.Loop.KeyVar != "i" || forStmt.Loop.ValueVar != "候选人" || forStmt.Loop.RangeExpr != "候选人列表" {
+		t.Errorf("range信息不正确: %+v", forStmt.Loop)
+	}
+	if forStmt.Desc != "逐个安排面试" {
+		t.Errorf("for语句描述不正确: %s", forStmt.Desc)
+	}
+
+	var progress []string
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		switch step.Name {
+		case "step1":
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{
+				"candidates": []string{"张三", "李四", "王五"},
+				"err":        nil,
+			}}, nil
+		default:
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{
+				"time": "10:00-" + in.RealInput["name"].(string),
+				"err":  nil,
+			}}, nil
+		}
+	}
+	executor.OnWorkFlowUpdate = func(ctx context.Context, current *SimpleParseResult) error {
+		loop := current.MainFunc.Statements[1].Loop
+		if loop.Total > 0 {
+			progress = append(progress, fmt.Sprintf("%d/%d", loop.Completed, loop.Total))
+		}
+		return nil
+	}
+
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	loop := forStmt.Loop
+	if loop.Total != 3 || loop.Completed != 3 || len(loop.Iterations) != 3 {
+		t.Fatalf("迭代次数不正确: total=%d completed=%d iterations=%d", loop.Total, loop.Completed, len(loop.Iterations))
+	}
+	if !strings.Contains(strings.Join(progress, ","), "3/3") {
+		t.Errorf("缺少进度回调: %v", progress)
+	}
+
+	for i, iteration := range loop.Iterations {
+		if iteration.Status != StatusCompleted || iteration.StartTime == nil || iteration.EndTime == nil {
+			t.Errorf("迭代 %d 状态不正确: %s", i, iteration.Status)
+		}
+		if iteration.Statements[0].Status != StatusCompleted {
+			t.Errorf("迭代 %d 语句状态不正确: %s", i, iteration.Statements[0].Status)
+		}
+		if iteration.Variables["i"].Value != i {
+			t.Errorf("迭代 %d 下标变量不正确: %v", i, iteration.Variables["i"].Value)
+		}
+		if len(iteration.Logs) != 1 || !strings.Contains(iteration.Logs[0].Message, iteration.Value.(string)) {
+			t.Errorf("迭代 %d 日志不正确: %+v", i, iteration.Logs)
+		}
+	}
+	if loop.Iterations[1].Variables["面试时间"].Value != "10:00-李四" {
+		t.Errorf("迭代变量不正确: %v", loop.Iterations[1].Variables["面试时间"].Value)
+	}
+
+	// 循环体模板不执行，循环变量在循环结束后恢复为外层值
+	if forStmt.Children[0].Status != StatusPending {
+		t.Errorf("循环体模板状态不正确: %s", forStmt.Children[0].Status)
+	}
+	if value := result.Variables["候选人"].Value; value != nil {
+		t.Errorf("循环变量应恢复为外层值, 实际 %v", value)
+	}
+}
+
+// TestExecutor_LoopControlFlow 测试break、continue和对整数range
+func TestExecutor_LoopControlFlow(t *testing.T) {
+	code := `func main() {
+    for _, n := range 5 {
+        if n == 1 {
+            continue
+        }
+        if n == 3 {
+            break
+        }
+        sys.Printf("数字 %d", n)
+    }
+    for i := range 3 {
+        sys.Printf("计数 %d", i)
+    }
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if err := NewExecutor().Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	var messages []string
+	for _, log := range result.GlobalLogs {
+		messages = append(messages, log.Message)
+	}
+	want := "数字 0,数字 2,计数 0,计数 1,计数 2"
+	if strings.Join(messages, ",") != want {
+		t.Errorf("日志不正确: 期望 %s, 实际 %s", want, strings.Join(messages, ","))
+	}
+
+	loop := result.MainFunc.Statements[0].Loop
+	if loop.Completed != 4 || len(loop.Iterations) != 4 {
+		t.Errorf("break后迭代次数不正确: %d", loop.Completed)
+	}
+	// continue 后的语句被跳过
+	if status := loop.Iterations[1].Statements[2].Status; status != StatusSkipped {
+		t.Errorf("continue后的语句应被跳过, 实际 %s", status)
+	}
+}
+
+// TestExecutor_CondLoopMaxIterations 测试条件循环的最大迭代次数保护
+func TestExecutor_CondLoopMaxIterations(t *testing.T) {
+	code := `func main() {
+    //max_iterations: 5
+    for true {
+        sys.Println("循环")
+    }
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if got := result.MainFunc.Statements[0].GetMaxIterations(); got != 5 {
+		t.Fatalf("最大迭代次数不正确: %d", got)
+	}
+
+	err := NewExecutor().Start(context.Background(), result)
+	if err == nil || !strings.Contains(err.Error(), "最大迭代次数 5") {
+		t.Fatalf("期望超过最大迭代次数错误, 实际 %v", err)
+	}
+	if len(result.GlobalLogs) != 5 {
+		t.Errorf("迭代次数不正确: %d", len(result.GlobalLogs))
+	}
+	if result.MainFunc.Statements[0].Status != StatusFailed {
+		t.Errorf("for语句状态不正确: %s", result.MainFunc.Statements[0].Status)
+	}
+}
+
+// TestParseWorkflow_ForClause 测试三段式 for 循环在解析时报错
+func TestParseWorkflow_ForClause(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"func main() {\n    for i := 0; i < 3; i++ {\n        sys.Println(i)\n    }\n}", "第2行第9列"},
+		{"func main() {\n    for ; 次数 < 3; 次数++ {\n    }\n}", "第2行第19列"},
+	}
+	for _, tt := range tests {
+		result := NewSimpleParser().ParseWorkflow(tt.code)
+		if result.Success || !strings.HasPrefix(result.Error, tt.want+": 不支持三段式 for 循环") {
+			t.Errorf("应报告三段式 for 循环: %s", result.Error)
+		}
+	}
+}
+
+// TestExecutor_ReturnStopsWorkflow 测试return结束工作流，后续语句标记为跳过
+func TestExecutor_ReturnStopsWorkflow(t *testing.T) {
+	code := `step1 = beiluo.test1.devops.create(name: string "名称") -> (id: string "编号", err: error "是否失败");
+
+func main() {
+    编号, step1Err := step1("张三")
+    if step1Err != nil {
+        step1.Printf("失败: %v", step1Err)
+        return
+    }
+    sys.Println("不应执行")
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	returned := false
+	exited := false
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"id": "", "err": "账号已存在"}}, nil
+	}
+	executor.OnWorkFlowReturn = func(ctx context.Context, current *SimpleParseResult) error {
+		returned = true
+		return nil
+	}
+	executor.OnWorkFlowExit = func(ctx context.Context, current *SimpleParseResult) error {
+		exited = true
+		return nil
+	}
+
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if !returned || exited {
+		t.Errorf("回调不正确: returned=%v exited=%v", returned, exited)
+	}
+	if status := result.MainFunc.Statements[2].Status; status != StatusSkipped {
+		t.Errorf("return后的语句应被跳过, 实际 %s", status)
+	}
+	if len(result.GlobalLogs) != 0 {
+		t.Errorf("return后的语句不应执行: %+v", result.GlobalLogs)
+	}
+}
+
+// TestExecutor_RangeIntBounds 测试负数range不执行迭代，超过最大迭代次数的整数range直接失败
+func TestExecutor_RangeIntBounds(t *testing.T) {
+	code := `func main() {
+    n := 0 - 3
+    for i := range n {
+        sys.Printf("计数 %d", i)
+    }
+    sys.Println("完成")
+}`
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if err := NewExecutor().Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "完成" || result.MainFunc.Statements[1].Loop.Total != 0 {
+		t.Errorf("负数range不应执行迭代: %+v", result.GlobalLogs)
+	}
+
+	result = NewSimpleParser().ParseWorkflow(strings.Replace(code, "0 - 3", "1000000000000", 1))
+	err := NewExecutor().Start(context.Background(), result)
+	if err == nil || !strings.Contains(err.Error(), "超过最大迭代次数") {
+		t.Errorf("整数range应受最大迭代次数限制: %v", err)
+	}
+}
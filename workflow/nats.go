@@ -0,0 +1,221 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yunhanshu-net/pkg/dto/functions"
+	"github.com/yunhanshu-net/pkg/dto/runnerproject"
+)
+
+// NatsRequester 发送NATS请求并等待回复，*nats.Conn 实现了该接口
+type NatsRequester interface {
+	RequestWithContext(ctx context.Context, subj string, data []byte) (*nats.Msg, error)
+}
+
+// natsTimeoutHeader 请求头中请求方等待回复的剩余时间（毫秒），runner 侧超过该时间后取消函数的 ctx
+const natsTimeoutHeader = "X-Workflow-Timeout"
+
+// NatsFunctionCaller 通过NATS把步骤分发给 runner 执行：executor.OnFunctionCall = caller.OnFunctionCall
+//
+// 步骤函数 beiluo.<user>.<app>.<func> 发送到 runner.<user>.<app>.<version>.run，
+// 请求为 functions.RunFunctionReq，Router 为 /<func>，Body 为按参数名组成的输入参数；
+// 回复为 functions.RunFunctionResp，Code 为0时 Data 按参数名映射为输出参数，
+// 否则步骤失败，错误码为 MetaData["error_code"]，没有时为 Code。
+type NatsFunctionCaller struct {
+	Conn     NatsRequester
+	Versions map[string]string // "<user>.<app>" -> runner 版本，未设置时为 v0
+	Timeout  time.Duration     // 步骤未设置 timeout 时等待回复的时间，0 表示30秒
+}
+
+// runnerFunction 解析步骤函数名，返回执行函数的 runner 和路由
+func (c *NatsFunctionCaller) runnerFunction(function string) (*runnerproject.Runner, string, error) {
+	parts := strings.Split(function, ".")
+	if len(parts) != 4 || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return nil, "", fmt.Errorf("函数 %s 不是 beiluo.<user>.<app>.<func> 格式", function)
+	}
+	runner := &runnerproject.Runner{User: parts[1], Name: parts[2], Version: "v0"}
+	if version, exists := c.Versions[parts[1]+"."+parts[2]]; exists {
+		runner.Version = version
+	}
+	return runner, "/" + parts[3], nil
+}
+
+// OnFunctionCall 发送步骤到对应的 runner 并等待回复，ctx 的截止时间为步骤的 timeout
+func (c *NatsFunctionCaller) OnFunctionCall(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+	runner, router, err := c.runnerFunction(step.Function)
+	if err != nil {
+		return nil, err
+	}
+	body := make(map[string]interface{}, len(step.InputParams))
+	for _, param := range step.InputParams {
+		body[param.Name] = in.RealInput[param.Name]
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+	data, err := json.Marshal(&functions.RunFunctionReq{
+		Runner:   runner,
+		TraceID:  FlowIDFromContext(ctx),
+		Router:   router,
+		Method:   "POST",
+		Headers:  map[string]string{natsTimeoutHeader: strconv.FormatInt(time.Until(deadline).Milliseconds(), 10)},
+		BodyType: "json",
+		Body:     body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	msg, err := c.Conn.RequestWithContext(ctx, runner.GetRequestSubject(), data)
+	if errors.Is(err, nats.ErrNoResponders) {
+		return nil, fmt.Errorf("没有可用的 runner %s: %w", runner.GetRequestSubject(), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &functions.RunFunctionResp{}
+	if err := json.Unmarshal(msg.Data, resp); err != nil {
+		return &ExecutorOut{Success: false, Error: fmt.Sprintf("解析 runner 回复失败: %v", err)}, nil
+	}
+	if resp.Code != 0 {
+		code, _ := resp.MetaData["error_code"].(string)
+		if code == "" {
+			code = strconv.Itoa(resp.Code)
+		}
+		return &ExecutorOut{Success: false, Error: resp.Msg, ErrorCode: code}, nil
+	}
+	output, ok := resp.Data.(map[string]interface{})
+	if !ok && resp.Data != nil {
+		return &ExecutorOut{Success: false, Error: fmt.Sprintf("runner 返回的数据不是对象: %v", resp.Data)}, nil
+	}
+	return mapOutput(step, output), nil
+}
+
+// NatsHandler runner 侧执行一个函数，body 为按参数名组成的输入参数，返回按参数名组成的输出参数；
+// 返回的错误实现了 ErrorCode() string 时错误码会传给工作流，可以在 retry_on 中指定
+type NatsHandler func(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error)
+
+// NatsWorker runner 侧的步骤执行器，订阅 runner 的请求主题并按路由调用注册的函数
+//
+//	worker := workflow.NewNatsWorker(&runnerproject.Runner{User: "test1", Name: "hr", Version: "v1"})
+//	worker.Handle("create_user", createUser)
+//	sub, err := worker.Serve(conn)
+//
+// 多个实例订阅同一个队列组，请求在实例之间负载均衡。每个请求在单独的 goroutine 中执行，
+// 同时执行的请求达到 Concurrency 后，后面的请求在订阅中排队。
+type NatsWorker struct {
+	Concurrency int // 同时执行的请求数，0 表示100
+
+	runner *runnerproject.Runner
+
+	mu       sync.RWMutex
+	handlers map[string]NatsHandler
+}
+
+// NewNatsWorker 创建 runner 侧的步骤执行器
+func NewNatsWorker(runner *runnerproject.Runner) *NatsWorker {
+	return &NatsWorker{runner: runner, handlers: make(map[string]NatsHandler)}
+}
+
+// Handle 注册函数，router 为函数名（如 create_user）或路由（如 /create_user）
+func (w *NatsWorker) Handle(router string, handler NatsHandler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers["/"+strings.TrimPrefix(router, "/")] = handler
+}
+
+// Remove 删除注册的函数，之后的请求返回路由未找到
+func (w *NatsWorker) Remove(router string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.handlers, "/"+strings.TrimPrefix(router, "/"))
+}
+
+// Serve 订阅 runner 的请求主题，返回的订阅 Unsubscribe 后停止接收请求，已开始执行的请求继续执行
+func (w *NatsWorker) Serve(conn *nats.Conn) (*nats.Subscription, error) {
+	concurrency := w.Concurrency
+	if concurrency <= 0 {
+		concurrency = 100
+	}
+	slots := make(chan struct{}, concurrency)
+	subject := w.runner.GetRequestSubject()
+	sub, err := conn.QueueSubscribe(subject, subject, func(msg *nats.Msg) {
+		// 没有空闲的位置时阻塞订阅的回调，后面的消息由 nats 缓存
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots }()
+			// 请求方已超时等原因导致回复失败时，请求方按超时处理
+			if reply := w.handle(context.Background(), msg.Data); reply != nil {
+				_ = msg.Respond(reply)
+			}
+		}()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("订阅 %s 失败: %w", subject, err)
+	}
+	return sub, nil
+}
+
+// handle 执行一个请求并返回序列化后的回复，请求带有等待时间时函数的 ctx 在请求方超时后取消，
+// 此时请求方已不再等待回复，返回 nil
+func (w *NatsWorker) handle(ctx context.Context, data []byte) []byte {
+	req := &functions.RunFunctionReq{}
+	resp := &functions.RunFunctionResp{Msg: "ok"}
+	if err := json.Unmarshal(data, req); err != nil {
+		resp.Code, resp.Msg = 400, fmt.Sprintf("解析请求失败: %v", err)
+		return w.marshal(resp)
+	}
+	resp.TraceID = req.TraceID
+
+	w.mu.RLock()
+	handler, exists := w.handlers[req.Router]
+	w.mu.RUnlock()
+	if !exists {
+		resp.Code, resp.Msg = 404, fmt.Sprintf("路由未找到: %s", req.Router)
+		return w.marshal(resp)
+	}
+
+	if ms, err := strconv.ParseInt(req.Headers[natsTimeoutHeader], 10, 64); err == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+		defer cancel()
+	}
+	body, _ := req.Body.(map[string]interface{})
+	output, err := handler(ctx, body)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		resp.Code, resp.Msg = 500, err.Error()
+		if code := errorCode(err); code != "" {
+			resp.MetaData = map[string]interface{}{"error_code": code}
+		}
+		return w.marshal(resp)
+	}
+	resp.Data = output
+	return w.marshal(resp)
+}
+
+func (w *NatsWorker) marshal(resp *functions.RunFunctionResp) []byte {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(&functions.RunFunctionResp{Code: 500, Msg: fmt.Sprintf("序列化回复失败: %v", err), TraceID: resp.TraceID})
+	}
+	return data
+}
@@ -0,0 +1,182 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yunhanshu-net/pkg/dto/runnerproject"
+	"github.com/yunhanshu-net/pkg/logger"
+	"github.com/yunhanshu-net/pkg/x/natsx"
+)
+
+// natsTestLogger NATS连接关闭后仍会异步写日志，日志只初始化一次，避免与重新初始化竞争
+var natsTestLogger sync.Once
+
+// natsTestServer 启动内嵌的NATS服务器并返回连接，测试结束后关闭
+func natsTestServer(t *testing.T) *nats.Conn {
+	t.Helper()
+	natsTestLogger.Do(func() {
+		_ = logger.Init(logger.Config{Level: "error", Filename: filepath.Join(os.TempDir(), "workflow-nats-test.log")})
+	})
+	conn, srv, err := natsx.InitNatsWithRetry(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("启动NATS服务器失败: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Shutdown()
+		srv.WaitForShutdown()
+	})
+	return conn
+}
+
+// TestNatsFunctionCaller 测试通过NATS把步骤分发给 runner 执行
+func TestNatsFunctionCaller(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	worker := NewNatsWorker(&runnerproject.Runner{User: "test1", Name: "hr", Version: "v2"})
+	worker.Handle("query_department", func(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if attempts++; attempts == 1 {
+			return nil, &stepError{code: "E500", message: "服务繁忙"}
+		}
+		return map[string]interface{}{"department": body["username"].(string) + "-研发"}, nil
+	})
+	cancelled := make(chan error, 1)
+	worker.Handle("/create_profile", func(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error) {
+		if body["department"] == "超时-研发" {
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			return nil, ctx.Err()
+		}
+		return map[string]interface{}{"profileId": "P001", "err": nil}, nil
+	})
+	conn := natsTestServer(t)
+	sub, err := worker.Serve(conn)
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	defer sub.Unsubscribe()
+	caller := &NatsFunctionCaller{Conn: conn, Versions: map[string]string{"test1.hr": "v2"}}
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = caller.OnFunctionCall
+	code := strings.Replace(storeTestCode, `retry: 2,`, `retry: 2, retry_initial: 1,`, 1)
+	result := NewSimpleParser().ParseWorkflow(code)
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if variable, _ := result.GetVariable("档案编号"); variable.Value != "P001" {
+		t.Errorf("回复映射不正确: %v", variable.Value)
+	}
+	if attempts != 2 {
+		t.Errorf("错误码 E500 应重试一次，实际调用%d次", attempts)
+	}
+
+	// 步骤超时
+	code = strings.Replace(strings.Replace(storeTestCode, `"张三"`, `"超时"`, 1), `step2(部门)`, `step2(部门){timeout: 20}`, 1)
+	result = NewSimpleParser().ParseWorkflow(code)
+	var timeoutErr *StepTimeoutError
+	if err := executor.Start(context.Background(), result); err == nil || !errors.As(err, &timeoutErr) {
+		t.Errorf("应返回步骤超时: %v", err)
+	}
+	// 请求方超时后 runner 侧的函数被取消
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("runner 侧应因超时取消: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("请求方超时后 runner 侧的函数应被取消")
+	}
+
+	// 路由不存在
+	worker.Remove("create_profile")
+	result = NewSimpleParser().ParseWorkflow(storeTestCode)
+	if err := executor.Start(context.Background(), result); err == nil || !strings.Contains(err.Error(), "路由未找到: /create_profile") {
+		t.Errorf("应返回路由未找到: %v", err)
+	}
+
+	// 没有订阅该版本的 runner
+	caller.Versions["test1.hr"] = "v3"
+	result = NewSimpleParser().ParseWorkflow(storeTestCode)
+	if err := executor.Start(context.Background(), result); err == nil || !strings.Contains(err.Error(), "runner.test1.hr.v3.run") {
+		t.Errorf("应返回没有可用的 runner: %v", err)
+	}
+
+	if _, err := caller.OnFunctionCall(context.Background(), SimpleStep{Function: "create_user"}, &ExecutorIn{}); err == nil {
+		t.Error("函数名格式不正确时应返回错误")
+	}
+}
+
+// TestNatsWorker_Concurrency 测试请求并发执行，同时执行的请求数不超过 Concurrency
+func TestNatsWorker_Concurrency(t *testing.T) {
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	worker := NewNatsWorker(&runnerproject.Runner{User: "test1", Name: "hr", Version: "v1"})
+	worker.Concurrency = 2
+	worker.Handle("query_department", func(ctx context.Context, body map[string]interface{}) (map[string]interface{}, error) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return map[string]interface{}{"department": "研发"}, nil
+	})
+	conn := natsTestServer(t)
+	sub, err := worker.Serve(conn)
+	if err != nil {
+		t.Fatalf("订阅失败: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	caller := &NatsFunctionCaller{Conn: conn, Versions: map[string]string{"test1.hr": "v1"}}
+	step := SimpleStep{Function: "beiluo.test1.hr.query_department", OutputParams: []ParameterInfo{{Name: "department"}}}
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			out, err := caller.OnFunctionCall(context.Background(), step, &ExecutorIn{})
+			if err == nil && !out.Success {
+				err = errors.New(out.Error)
+			}
+			errs <- err
+		}()
+	}
+
+	// 前两个请求同时执行，第三个等待空闲的位置
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("请求应并发执行")
+		}
+	}
+	select {
+	case <-started:
+		t.Fatal("同时执行的请求数不应超过 Concurrency")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("请求失败: %v", err)
+		}
+	}
+	if maxActive != 2 {
+		t.Errorf("最大并发数不正确: %d", maxActive)
+	}
+}
@@ -450,3 +450,64 @@ func main() {
 		t.Errorf("解析性能不达标: 平均耗时 %v > 1ms", duration/1000)
 	}
 }
+
+// TestSimpleParser_ElseIfBranches 测试 if / else if / else 分支解析
+func TestSimpleParser_ElseIfBranches(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "级别": 2,
+}
+
+step1 = code.service.basic(level: int "级别") -> (result: string "结果", err: error "是否失败");
+
+func main() {
+    if input["级别"] == 1 {
+        sys.Println("级别 {1}")
+    } else if input["级别"] == 2 {
+        结果, step1Err := step1(input["级别"])
+        if step1Err != nil {
+            return
+        }
+    } else {
+        sys.Println("其他级别")
+    }
+    sys.Println("完成")
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	statements := result.MainFunc.Statements
+	if len(statements) != 2 {
+		t.Fatalf("主函数语句数量错误: 期望 2, 实际 %d", len(statements))
+	}
+
+	ifStmt := statements[0]
+	if ifStmt.Condition != `input["级别"] == 1` {
+		t.Errorf("if条件不正确: %q", ifStmt.Condition)
+	}
+	if len(ifStmt.Children) != 1 {
+		t.Errorf("if语句体数量错误: 期望 1, 实际 %d", len(ifStmt.Children))
+	}
+	if len(ifStmt.Branches) != 2 {
+		t.Fatalf("分支数量错误: 期望 2, 实际 %d", len(ifStmt.Branches))
+	}
+
+	elseIf := ifStmt.Branches[0]
+	if elseIf.Type != "branch" || elseIf.Condition != `input["级别"] == 2` || elseIf.LineNumber != 10 {
+		t.Errorf("else if分支不正确: 类型 %s, 条件 %q, 行号 %d", elseIf.Type, elseIf.Condition, elseIf.LineNumber)
+	}
+	if len(elseIf.Children) != 2 || elseIf.Children[1].Type != "if" {
+		t.Errorf("else if分支子语句不正确: %d", len(elseIf.Children))
+	}
+
+	elseBranch := ifStmt.Branches[1]
+	if elseBranch.Condition != "" || len(elseBranch.Children) != 1 {
+		t.Errorf("else分支不正确: 条件 %q, 子语句 %d", elseBranch.Condition, len(elseBranch.Children))
+	}
+
+	if statements[1].Content != `sys.Println("完成")` {
+		t.Errorf("if之后的语句不正确: %s", statements[1].Content)
+	}
+}
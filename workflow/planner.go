@@ -0,0 +1,223 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yunhanshu-net/pkg/llm"
+)
+
+// DiagUnknownFunction 生成的工作流使用了函数目录之外的函数，或步骤定义与目录不一致
+const DiagUnknownFunction = "unknown-function"
+
+// PlannerFunction 规划器可以使用的函数
+type PlannerFunction struct {
+	Name    string          `json:"name"`    // 函数名，如 beiluo.test1.hr.query_department
+	Desc    string          `json:"desc"`    // 函数功能描述
+	Inputs  []ParameterInfo `json:"inputs"`  // 输入参数，按调用顺序
+	Outputs []ParameterInfo `json:"outputs"` // 输出参数，按返回顺序，通常最后一个为 err: error
+}
+
+// Definition 返回函数的步骤定义（不含步骤名），如 beiluo.test1.hr.query_department(username: string "用户名") -> (department: string "部门", err: error "是否失败")
+func (f PlannerFunction) Definition() string {
+	return fmt.Sprintf("%s(%s) -> (%s)", f.Name, formatParams(f.Inputs), formatParams(f.Outputs))
+}
+
+func formatParams(params []ParameterInfo) string {
+	parts := make([]string, len(params))
+	for i, param := range params {
+		parts[i] = fmt.Sprintf("%s: %s %q", param.Name, param.Type, param.Desc)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Planner 根据自然语言描述生成工作流：请求大模型按函数目录编写工作流代码，解析并静态检查，
+// 有错误时把错误反馈给大模型修正，直到通过检查或达到最大尝试次数
+//
+//	planner := workflow.NewPlanner(client, functions...)
+//	result, err := planner.Plan(ctx, "查询张三的部门，然后为他创建档案")
+type Planner struct {
+	Client      llm.LLMClient
+	Model       string            // 模型名称，为空时使用客户端的默认模型
+	Functions   []PlannerFunction // 函数目录，生成的工作流只能使用其中的函数
+	MaxAttempts int               // 最多请求大模型的次数（包括修正），0 表示3次
+}
+
+// NewPlanner 创建工作流规划器
+func NewPlanner(client llm.LLMClient, functions ...PlannerFunction) *Planner {
+	return &Planner{Client: client, Functions: functions}
+}
+
+// PlanError 达到最大尝试次数后生成的工作流仍有错误
+type PlanError struct {
+	Attempts int      // 请求大模型的次数
+	Code     string   // 最后一次生成的工作流代码
+	Problems []string // 最后一次生成的工作流的错误，带行号
+}
+
+func (e *PlanError) Error() string {
+	return fmt.Sprintf("生成工作流失败，尝试%d次后仍有错误: %s", e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+// planResponse 大模型返回的JSON
+type planResponse struct {
+	Code string `json:"code" llm:"desc:完整的工作流代码"`
+}
+
+// Plan 生成并返回通过解析和静态检查的工作流，达到最大尝试次数仍有错误时返回 *PlanError
+func (p *Planner) Plan(ctx context.Context, description string) (*SimpleParseResult, error) {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	systemPrompt, err := p.systemPrompt()
+	if err != nil {
+		return nil, err
+	}
+	messages := []llm.Message{
+		llm.NewSystemMessage(systemPrompt),
+		llm.NewUserMessage(description),
+	}
+
+	planErr := &PlanError{}
+	for planErr.Attempts < maxAttempts {
+		planErr.Attempts++
+		req := llm.NewJSONRequest(p.Model, messages...)
+		req.Temperature = 0.1
+		resp, err := p.Client.ChatCompletion(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("请求大模型失败: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("大模型没有返回结果")
+		}
+		content := resp.Choices[0].Message.Content
+
+		var result *SimpleParseResult
+		planErr.Code, result, planErr.Problems = p.check(content)
+		if len(planErr.Problems) == 0 {
+			return result, nil
+		}
+		messages = append(messages,
+			llm.NewAssistantMessage(content),
+			llm.NewUserMessage("工作流有以下错误，请修正后返回完整的工作流代码：\n"+strings.Join(planErr.Problems, "\n")),
+		)
+	}
+	return nil, planErr
+}
+
+// check 解析大模型的回复并检查工作流，返回工作流代码、解析结果和全部错误
+func (p *Planner) check(content string) (string, *SimpleParseResult, []string) {
+	resp := &planResponse{}
+	if err := json.Unmarshal([]byte(content), resp); err != nil {
+		return "", nil, []string{fmt.Sprintf("返回的不是有效的JSON: %v", err)}
+	}
+	code := strings.TrimSpace(resp.Code)
+	if code == "" {
+		return "", nil, []string{"code 字段为空"}
+	}
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		if len(result.Errors) == 0 {
+			return code, result, []string{result.Error}
+		}
+		problems := make([]string, len(result.Errors))
+		for i, parseErr := range result.Errors {
+			problems[i] = parseErr.Error()
+		}
+		return code, result, problems
+	}
+
+	var problems []string
+	for _, d := range append(p.checkFunctions(result), Validate(result)...) {
+		if d.Severity == SeverityError {
+			problems = append(problems, d.String())
+		}
+	}
+	return code, result, problems
+}
+
+// checkFunctions 检查步骤使用的函数是否在函数目录中，参数定义是否与目录一致
+func (p *Planner) checkFunctions(result *SimpleParseResult) []Diagnostic {
+	functions := make(map[string]PlannerFunction, len(p.Functions))
+	for _, function := range p.Functions {
+		functions[function.Name] = function
+	}
+
+	var diagnostics []Diagnostic
+	for _, step := range result.Steps {
+		function, exists := functions[step.Function]
+		if !exists {
+			diagnostics = append(diagnostics, Diagnostic{Line: step.LineNumber, Severity: SeverityError, Code: DiagUnknownFunction,
+				Message: fmt.Sprintf("步骤 %s 使用的函数 %s 不在可用函数列表中", step.Name, step.Function)})
+			continue
+		}
+		if !sameParams(step.InputParams, function.Inputs) || !sameParams(step.OutputParams, function.Outputs) {
+			diagnostics = append(diagnostics, Diagnostic{Line: step.LineNumber, Severity: SeverityError, Code: DiagUnknownFunction,
+				Message: fmt.Sprintf("步骤 %s 的定义与函数不一致，应为 %s = %s", step.Name, step.Name, function.Definition())})
+		}
+	}
+	return diagnostics
+}
+
+// sameParams 参数名和类型是否一致，不比较描述
+func sameParams(got, want []ParameterInfo) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].Name != want[i].Name || normalizeType(got[i].Type) != normalizeType(want[i].Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// systemPrompt 返回介绍工作流语法和函数目录的系统提示词
+func (p *Planner) systemPrompt() (string, error) {
+	schema, err := llm.GenerateJSONSchema(planResponse{})
+	if err != nil {
+		return "", fmt.Errorf("生成JSON Schema失败: %w", err)
+	}
+
+	var catalog strings.Builder
+	for _, function := range p.Functions {
+		fmt.Fprintf(&catalog, "- %s\n", function.Definition())
+		if function.Desc != "" {
+			fmt.Fprintf(&catalog, "  %s\n", function.Desc)
+		}
+	}
+
+	return fmt.Sprintf(`你是工作流编排助手，请根据用户的需求编写工作流代码。
+
+工作流语法（Go语法的子集）：
+1. 用户提到的输入数据定义在 var input = map[string]interface{}{"参数名": 值} 中，通过 input["参数名"] 使用
+2. 步骤定义：step1 = 函数名(参数名: 类型 "描述", ...) -> (返回值名: 类型 "描述", ...);  步骤定义必须与可用函数列表中的完全一致
+3. 在 func main() {} 中调用步骤：部门, step1Err := step1(input["用户名"])，返回值按顺序赋给变量，变量可以使用中文
+4. 支持 if/else、for range、sys.Printf/步骤名.Printf 打印日志、return 结束工作流
+5. 每个步骤调用后检查错误：if step1Err != nil { step1.Printf("失败: %%v", step1Err); return }
+
+示例：
+var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = beiluo.test1.hr.query_department(username: string "用户名") -> (department: string "部门", err: error "是否失败");
+
+func main() {
+    部门, step1Err := step1(input["用户名"])
+    if step1Err != nil {
+        step1.Printf("查询部门失败: %%v", step1Err)
+        return
+    }
+    sys.Printf("部门: %%s", 部门)
+}
+
+可用函数（只能使用这些函数）：
+%s
+按以下JSON Schema返回：
+%s`, catalog.String(), schema), nil
+}
@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yunhanshu-net/pkg/llm"
+)
+
+// plannerTestClient 按顺序返回预设回复的大模型客户端，记录每次请求
+type plannerTestClient struct {
+	replies  []string
+	requests []*llm.ChatCompletionRequest
+}
+
+func (c *plannerTestClient) ChatCompletion(ctx context.Context, req *llm.ChatCompletionRequest) (*llm.ChatCompletionResponse, error) {
+	c.requests = append(c.requests, req)
+	reply := c.replies[min(len(c.requests), len(c.replies))-1]
+	return &llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.NewAssistantMessage(reply)}}}, nil
+}
+
+func (c *plannerTestClient) GetClientInfo() llm.ClientInfo { return llm.ClientInfo{Provider: "test"} }
+
+func (c *plannerTestClient) HealthCheck(ctx context.Context) error { return nil }
+
+// plannerTestReply 把工作流代码包装为大模型的JSON回复
+func plannerTestReply(code string) string {
+	data, _ := json.Marshal(map[string]string{"code": code})
+	return string(data)
+}
+
+var plannerTestFunctions = []PlannerFunction{
+	{
+		Name:    "beiluo.test1.hr.query_department",
+		Desc:    "查询用户所在部门",
+		Inputs:  []ParameterInfo{{Name: "username", Type: "string", Desc: "用户名"}},
+		Outputs: []ParameterInfo{{Name: "department", Type: "string", Desc: "部门"}, {Name: "err", Type: "error", Desc: "是否失败"}},
+	},
+	{
+		Name:    "beiluo.test1.hr.create_profile",
+		Desc:    "为用户创建档案",
+		Inputs:  []ParameterInfo{{Name: "department", Type: "string", Desc: "部门"}},
+		Outputs: []ParameterInfo{{Name: "profileId", Type: "string", Desc: "档案编号"}, {Name: "err", Type: "error", Desc: "是否失败"}},
+	},
+}
+
+// TestPlanner_Repair 测试生成的工作流有错误时反馈给大模型修正
+func TestPlanner_Repair(t *testing.T) {
+	broken := strings.Replace(storeTestCode, "beiluo.test1.hr.create_profile", "beiluo.test1.hr.create_user", 1)
+	broken = strings.Replace(broken, "step2(部门)", "step2(岗位)", 1)
+	client := &plannerTestClient{replies: []string{"不是JSON", plannerTestReply(broken), plannerTestReply(storeTestCode)}}
+	planner := NewPlanner(client, plannerTestFunctions...)
+
+	result, err := planner.Plan(context.Background(), "查询张三的部门，然后为他创建档案")
+	if err != nil {
+		t.Fatalf("生成失败: %v", err)
+	}
+	if !result.Success || len(result.Steps) != 2 || result.Steps[1].Function != "beiluo.test1.hr.create_profile" {
+		t.Errorf("工作流不正确: %+v", result.Steps)
+	}
+	if len(client.requests) != 3 {
+		t.Fatalf("应请求3次: %d", len(client.requests))
+	}
+
+	system := client.requests[0].Messages[0].Content
+	if !strings.Contains(system, plannerTestFunctions[0].Definition()) || !strings.Contains(system, "查询用户所在部门") {
+		t.Errorf("系统提示词应包含函数目录: %s", system)
+	}
+	if client.requests[0].ResponseFormat == nil || client.requests[0].ResponseFormat.Type != "json_object" {
+		t.Errorf("应使用JSON模式: %+v", client.requests[0].ResponseFormat)
+	}
+
+	// 第三次请求带有前两次的回复和错误
+	messages := client.requests[2].Messages
+	if len(messages) != 6 || messages[3].Role != "user" || messages[5].Role != "user" {
+		t.Fatalf("修正请求的消息不正确: %+v", messages)
+	}
+	if !strings.Contains(messages[3].Content, "JSON") {
+		t.Errorf("应反馈JSON错误: %s", messages[3].Content)
+	}
+	feedback := messages[5].Content
+	if !strings.Contains(feedback, "第6行") || !strings.Contains(feedback, "beiluo.test1.hr.create_user") || !strings.Contains(feedback, "岗位") {
+		t.Errorf("应反馈函数目录和静态检查的错误: %s", feedback)
+	}
+}
+
+// TestPlanner_MaxAttempts 测试达到最大尝试次数后返回 *PlanError
+func TestPlanner_MaxAttempts(t *testing.T) {
+	code := strings.Replace(storeTestCode, "func main() {", "func main() {\n    if {", 1)
+	client := &plannerTestClient{replies: []string{plannerTestReply(code)}}
+	planner := NewPlanner(client, plannerTestFunctions...)
+	planner.MaxAttempts = 2
+
+	_, err := planner.Plan(context.Background(), "查询张三的部门，然后为他创建档案")
+	var planErr *PlanError
+	if !errors.As(err, &planErr) {
+		t.Fatalf("应返回 *PlanError: %v", err)
+	}
+	if planErr.Attempts != 2 || len(client.requests) != 2 || planErr.Code != code || len(planErr.Problems) == 0 {
+		t.Errorf("错误信息不正确: %+v", planErr)
+	}
+}
@@ -0,0 +1,134 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordedCall 一次业务回调的输入和结果
+type RecordedCall struct {
+	Step     string                 `json:"step"`               // 步骤名，如 step1
+	Function string                 `json:"function"`           // 步骤函数，如 beiluo.test1.hr.create_user
+	Input    map[string]interface{} `json:"input"`              // 实际输入参数
+	Output   *ExecutorOut           `json:"output,omitempty"`   // 回调返回的结果
+	Error    string                 `json:"error,omitempty"`    // 回调返回的错误
+	Timeout  bool                   `json:"timeout,omitempty"`  // 回调因步骤超时结束
+	Duration time.Duration          `json:"duration,omitempty"` // 回调耗时
+	// 回调返回 (nil, nil)，执行器按步骤失败处理，回放时同样返回 (nil, nil)
+	NilOutput bool `json:"nil_output,omitempty"`
+}
+
+// Recording 一个流程的全部业务回调，按调用顺序排列
+type Recording struct {
+	FlowID string          `json:"flow_id"`
+	Calls  []*RecordedCall `json:"calls"`
+}
+
+// Save 把录制结果保存为JSON文件
+func (r *Recording) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化录制结果失败: %w", err)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadRecording 从 Save 保存的JSON文件加载录制结果
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	recording := &Recording{}
+	if err := json.Unmarshal(data, recording); err != nil {
+		return nil, fmt.Errorf("解析录制结果失败: %w", err)
+	}
+	return recording, nil
+}
+
+// Recorder 录制业务回调的真实输入输出，用于在测试中通过 Harness.Replay 离线重现问题
+//
+//	recorder := workflow.NewRecorder(executor.OnFunctionCall)
+//	executor.OnFunctionCall = recorder.OnFunctionCall
+//	...
+//	recorder.Recording(flowID).Save("testdata/flow.json")
+//
+// 按流程ID分别录制，子工作流中的回调录制在子流程ID下。录制结果一直保留到 Take 或 Reset，
+// 长时间运行时在流程结束后（如 OnWorkFlowExit 中）用 Take 取走录制结果。
+type Recorder struct {
+	next OnFunctionCall
+
+	mu         sync.Mutex
+	recordings map[string]*Recording
+}
+
+// NewRecorder 创建录制器，next 为实际执行步骤的业务回调
+func NewRecorder(next OnFunctionCall) *Recorder {
+	return &Recorder{next: next, recordings: make(map[string]*Recording)}
+}
+
+// OnFunctionCall 调用 next 并记录输入和结果，可直接设置为 Executor.OnFunctionCall
+func (r *Recorder) OnFunctionCall(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+	start := time.Now()
+	out, err := r.next(ctx, step, in)
+
+	call := &RecordedCall{
+		Step:     step.Name,
+		Function: step.Function,
+		Input:    copyMetadata(in.RealInput),
+		Output:   out,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		call.Error = err.Error()
+		call.Timeout = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	} else if out == nil {
+		call.NilOutput = true
+	}
+
+	flowID := FlowIDFromContext(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recording, exists := r.recordings[flowID]
+	if !exists {
+		recording = &Recording{FlowID: flowID}
+		r.recordings[flowID] = recording
+	}
+	recording.Calls = append(recording.Calls, call)
+	return out, err
+}
+
+// Recording 返回指定流程已录制的回调，没有录制时返回空的录制结果
+func (r *Recorder) Recording(flowID string) *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recording := &Recording{FlowID: flowID}
+	if existing, exists := r.recordings[flowID]; exists {
+		recording.Calls = append(recording.Calls, existing.Calls...)
+	}
+	return recording
+}
+
+// Take 返回指定流程已录制的回调并从录制器中删除，没有录制时返回空的录制结果
+func (r *Recorder) Take(flowID string) *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recording, exists := r.recordings[flowID]
+	if !exists {
+		return &Recording{FlowID: flowID}
+	}
+	delete(r.recordings, flowID)
+	return recording
+}
+
+// Reset 删除所有录制结果
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recordings = make(map[string]*Recording)
+}
@@ -0,0 +1,196 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// 重试间隔的增长方式
+const (
+	BackoffFixed       = "fixed"       // 固定间隔：retry_initial
+	BackoffLinear      = "linear"      // 线性增长：retry_initial * 第几次重试
+	BackoffExponential = "exponential" // 指数增长：retry_initial * 2^(第几次重试-1)
+)
+
+// retry_on 中的特殊取值，其他取值视为业务错误码
+const (
+	RetryOnTimeout = "timeout" // 步骤超时
+	RetryOnError   = "error"   // 任意非超时错误
+)
+
+// RetryPolicy 步骤的重试策略，由 retry、retry_backoff、retry_initial、retry_max、retry_jitter、retry_on 元数据组成
+type RetryPolicy struct {
+	MaxRetries int           `json:"max_retries"` // 最大重试次数，不含第一次执行
+	Backoff    string        `json:"backoff"`     // 重试间隔增长方式：fixed、linear、exponential
+	Initial    time.Duration `json:"initial"`     // 第一次重试前的等待时间
+	Max        time.Duration `json:"max"`         // 重试间隔上限，0表示不限制
+	Jitter     float64       `json:"jitter"`      // 随机抖动比例（0~1），实际间隔在 间隔*(1±jitter) 之间
+	RetryOn    []string      `json:"retry_on"`    // 需要重试的失败类型，为空时所有失败都重试
+}
+
+// NewRetryPolicy 从元数据构建重试策略，后面的元数据覆盖前面的（如语句元数据覆盖步骤定义元数据）
+//
+// retry_initial、retry_max 支持毫秒数或 "500ms"、"2s" 格式。
+func NewRetryPolicy(metadata ...map[string]interface{}) RetryPolicy {
+	defaults := GetDefaultMetadata()
+	policy := RetryPolicy{
+		MaxRetries: defaults.RetryCount,
+		Backoff:    defaults.RetryBackoff,
+		Initial:    defaults.RetryInitial,
+		Max:        defaults.RetryMax,
+		Jitter:     defaults.RetryJitter,
+	}
+
+	for _, meta := range metadata {
+		// 支持 retry 和 retry_count 两种键名
+		for _, key := range []string{"retry_count", "retry"} {
+			if value, ok := meta[key].(int); ok {
+				policy.MaxRetries = value
+			}
+		}
+		if value, ok := meta["retry_backoff"].(string); ok {
+			policy.Backoff = value
+		}
+		if value, ok := metadataDuration(meta["retry_initial"]); ok {
+			policy.Initial = value
+		}
+		if value, ok := metadataDuration(meta["retry_max"]); ok {
+			policy.Max = value
+		}
+		switch value := meta["retry_jitter"].(type) {
+		case float64:
+			policy.Jitter = value
+		case int:
+			policy.Jitter = float64(value)
+		}
+		switch value := meta["retry_on"].(type) {
+		case []string:
+			policy.RetryOn = value
+		case string:
+			policy.RetryOn = []string{value}
+		}
+	}
+	return policy
+}
+
+// metadataDuration 解析毫秒数或时长字符串
+func metadataDuration(value interface{}) (time.Duration, bool) {
+	switch v := value.(type) {
+	case int:
+		return time.Duration(v) * time.Millisecond, true
+	case float64:
+		return time.Duration(v * float64(time.Millisecond)), true
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Delay 返回第 retry 次重试（从1开始）前的等待时间
+//
+// 间隔按浮点数计算，未设置 retry_max 时指数退避超出 time.Duration 范围也不会溢出为负数，而是取最大值。
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+
+	delay := float64(p.Initial)
+	switch p.Backoff {
+	case BackoffFixed:
+	case BackoffExponential:
+		delay *= math.Pow(2, float64(retry-1))
+	default:
+		delay *= float64(retry)
+	}
+	if p.Max > 0 && delay > float64(p.Max) {
+		delay = float64(p.Max)
+	}
+
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay *= 1 - jitter + 2*jitter*rand.Float64()
+	}
+	if delay >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+// ShouldRetry 判断本次失败是否需要重试
+func (p RetryPolicy) ShouldRetry(err error) bool {
+	if len(p.RetryOn) == 0 {
+		return true
+	}
+	code := errorCode(err)
+	for _, on := range p.RetryOn {
+		switch on {
+		case RetryOnTimeout:
+			if isTimeout(err) {
+				return true
+			}
+		case RetryOnError:
+			if !isTimeout(err) {
+				return true
+			}
+		default:
+			if code != "" && code == on {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stepError 业务回调返回 Success=false 时的错误
+type stepError struct {
+	code    string
+	message string
+}
+
+func (e *stepError) Error() string {
+	return fmt.Sprintf("步骤执行失败: %s", e.message)
+}
+
+// ErrorCode 业务错误码，用于匹配 retry_on
+func (e *stepError) ErrorCode() string {
+	return e.code
+}
+
+// errorCode 获取错误携带的业务错误码：ExecutorOut.ErrorCode，或业务回调返回的实现了 ErrorCode() string 的错误
+func errorCode(err error) string {
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return ""
+}
+
+// failureMessage 返回用于日志的失败原因
+func failureMessage(err error) string {
+	var stepErr *stepError
+	if errors.As(err, &stepErr) {
+		return stepErr.message
+	}
+	return err.Error()
+}
+
+// sleepContext 等待指定时间，ctx结束时立即返回ctx的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,173 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRetryPolicy_Delay 测试不同增长方式的重试间隔
+func TestRetryPolicy_Delay(t *testing.T) {
+	cases := []struct {
+		policy RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{RetryPolicy{Backoff: BackoffFixed, Initial: 100 * time.Millisecond}, 3, 100 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffLinear, Initial: 100 * time.Millisecond}, 3, 300 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffExponential, Initial: 100 * time.Millisecond}, 1, 100 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffExponential, Initial: 100 * time.Millisecond}, 4, 800 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffExponential, Initial: 100 * time.Millisecond, Max: 500 * time.Millisecond}, 4, 500 * time.Millisecond},
+		{RetryPolicy{Backoff: BackoffExponential, Initial: time.Second, Max: time.Minute}, 100, time.Minute},
+		// 未设置上限时超出 time.Duration 范围取最大值，不溢出为负数
+		{RetryPolicy{Backoff: BackoffExponential, Initial: time.Second}, 100, math.MaxInt64},
+		{RetryPolicy{Backoff: BackoffExponential, Initial: time.Second}, 2000, math.MaxInt64},
+		{RetryPolicy{Backoff: BackoffLinear, Initial: math.MaxInt64 / 2}, 3, math.MaxInt64},
+	}
+	for _, c := range cases {
+		if got := c.policy.Delay(c.retry); got != c.want {
+			t.Errorf("%s 第%d次重试: 期望 %v, 实际 %v", c.policy.Backoff, c.retry, c.want, got)
+		}
+	}
+
+	// 抖动后的间隔在 间隔*(1±jitter) 之间
+	policy := RetryPolicy{Backoff: BackoffFixed, Initial: time.Second, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		if got := policy.Delay(1); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("抖动后的间隔超出范围: %v", got)
+		}
+	}
+	policy = RetryPolicy{Backoff: BackoffExponential, Initial: time.Second, Jitter: 0.2}
+	if got := policy.Delay(100); got <= 0 {
+		t.Errorf("抖动后的间隔不应溢出: %v", got)
+	}
+}
+
+// TestNewRetryPolicy 测试从元数据解析重试策略
+func TestNewRetryPolicy(t *testing.T) {
+	code := `step1 = beiluo.test1.devops.create(name: string "名称") -> (id: string "编号", err: error "是否失败") {retry_backoff: exponential, retry_initial: 200}
+
+func main() {
+    编号, step1Err := step1("张三"){retry: 3, retry_initial: 100, retry_max: "1s", retry_jitter: 0.2, retry_on: [timeout, "E1001"]}
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	policy := NewRetryPolicy(result.Steps[0].Metadata, result.MainFunc.Statements[0].Metadata)
+	if policy.MaxRetries != 3 || policy.Backoff != BackoffExponential {
+		t.Errorf("重试次数或增长方式不正确: %+v", policy)
+	}
+	if policy.Initial != 100*time.Millisecond || policy.Max != time.Second || policy.Jitter != 0.2 {
+		t.Errorf("重试间隔不正确: %+v", policy)
+	}
+	if strings.Join(policy.RetryOn, ",") != "timeout,E1001" {
+		t.Errorf("retry_on 不正确: %v", policy.RetryOn)
+	}
+
+	// 默认策略与原来的行为一致：所有失败都重试，间隔线性增长
+	defaults := NewRetryPolicy()
+	if defaults.MaxRetries != 0 || defaults.Delay(2) != 2*time.Second || !defaults.ShouldRetry(fmt.Errorf("失败")) {
+		t.Errorf("默认重试策略不正确: %+v", defaults)
+	}
+}
+
+// TestExecutor_RetryOnErrorCode 测试按错误码重试并记录每次尝试
+func TestExecutor_RetryOnErrorCode(t *testing.T) {
+	code := `step1 = beiluo.test1.devops.create(name: string "名称") -> (id: string "编号", err: error "是否失败");
+
+func main() {
+    编号, step1Err := step1("张三"){retry: 3, retry_initial: 1, retry_on: [E1001]}
+}`
+
+	run := func(codes ...string) (*SimpleParseResult, int, error) {
+		result := NewSimpleParser().ParseWorkflow(code)
+		if !result.Success {
+			t.Fatalf("解析失败: %s", result.Error)
+		}
+		attempts := 0
+		executor := NewExecutor()
+		executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+			attempts++
+			if attempts <= len(codes) {
+				return &ExecutorOut{Success: false, Error: "服务繁忙", ErrorCode: codes[attempts-1]}, nil
+			}
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"id": "A001", "err": nil}}, nil
+		}
+		err := executor.Start(context.Background(), result)
+		return result, attempts, err
+	}
+
+	// E1001 重试两次后成功
+	result, attempts, err := run("E1001", "E1001")
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	stmt := result.MainFunc.Statements[0]
+	if attempts != 3 || stmt.RetryCount != 2 || stmt.Status != StatusCompleted {
+		t.Errorf("重试结果不正确: attempts=%d retry=%d status=%s", attempts, stmt.RetryCount, stmt.Status)
+	}
+	logs := result.Steps[0].Logs
+	if len(logs) != 3 {
+		t.Fatalf("尝试记录数量不正确: %+v", logs)
+	}
+	for i, log := range logs[:2] {
+		if log.Attempt != i+1 || log.Level != "warn" || !strings.Contains(log.Error, "服务繁忙") {
+			t.Errorf("第%d次尝试记录不正确: %+v", i+1, log)
+		}
+	}
+	if logs[2].Attempt != 3 || logs[2].Level != "info" || logs[2].Error != "" {
+		t.Errorf("成功的尝试记录不正确: %+v", logs[2])
+	}
+
+	// 其他错误码不重试
+	result, attempts, err = run("E2002")
+	if err == nil || attempts != 1 {
+		t.Fatalf("期望不重试直接失败: attempts=%d err=%v", attempts, err)
+	}
+	if status := result.MainFunc.Statements[0].Status; status != StatusFailed {
+		t.Errorf("步骤状态不正确: %s", status)
+	}
+	if logs := result.Steps[0].Logs; len(logs) != 1 || logs[0].Level != "error" {
+		t.Errorf("失败的尝试记录不正确: %+v", logs)
+	}
+}
+
+// TestExecutor_RetrySleepCancelled 测试重试等待期间取消工作流立即结束
+func TestExecutor_RetrySleepCancelled(t *testing.T) {
+	code := `step1 = beiluo.test1.devops.create(name: string "名称") -> (id: string "编号", err: error "是否失败");
+
+func main() {
+    编号, step1Err := step1("张三"){retry: 3, retry_initial: 10000}
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return nil, fmt.Errorf("连接失败")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := executor.Start(ctx, result)
+	if err == nil || !strings.Contains(err.Error(), "被取消") {
+		t.Fatalf("期望取消错误, 实际 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("重试等待未响应取消: 耗时 %v", elapsed)
+	}
+	if status := result.MainFunc.Statements[0].Status; status != "cancelled" {
+		t.Errorf("步骤状态不正确: %s", status)
+	}
+}
@@ -0,0 +1,477 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// OverlapPolicy 任务上一次运行尚未结束时新触发的处理方式
+type OverlapPolicy string
+
+const (
+	OverlapSkip  OverlapPolicy = "skip"  // 跳过本次触发，默认
+	OverlapQueue OverlapPolicy = "queue" // 排队，上一次运行结束后按顺序执行
+	OverlapAllow OverlapPolicy = "allow" // 同时运行
+)
+
+// 触发方式
+const (
+	TriggerCron     = "cron"     // cron 表达式
+	TriggerInterval = "interval" // 固定间隔
+	TriggerNats     = "nats"     // NATS 消息
+	TriggerCatchUp  = "catch_up" // 补执行错过的触发
+	TriggerManual   = "manual"   // Scheduler.Trigger 手动触发
+)
+
+// 触发记录的状态，流程执行结束后为流程的状态（completed、failed、timeout、cancelled 等）
+const (
+	TriggerQueued  = "queued"  // 排队等待上一次运行结束
+	TriggerSkipped = "skipped" // 上一次运行尚未结束，按 OverlapSkip 跳过
+	TriggerMissed  = "missed"  // 错过的触发，超出 CatchUp 未补执行
+)
+
+// 计时器晚于计划时间超过该时长触发时，视为错过了这次触发（如进程阻塞、系统休眠）
+const missedTolerance = time.Second
+
+// Job 定时或事件触发的工作流任务
+//
+// Cron、Interval 和 Subject 至少设置一个，可以同时设置；每次触发重新解析 Code，
+// Input 与触发参数（NATS 消息体或 Trigger 的参数）合并后作为 InputVars，同名时触发参数优先。
+type Job struct {
+	Name     string                 // 任务名，唯一
+	Code     string                 // 工作流代码
+	Cron     string                 // cron 表达式，如 "0 2 * * *"、"@hourly"、"@every 10m"，见 ParseCron
+	Interval time.Duration          // 固定触发间隔，从调度器启动（或 LastRun）开始计时
+	Subject  string                 // NATS 主题，收到的消息体（JSON对象）作为输入参数
+	Input    map[string]interface{} // 默认输入参数
+	Overlap  OverlapPolicy          // 上一次运行尚未结束时的处理方式，默认 OverlapSkip
+	CatchUp  int                    // 错过触发时最多补执行的次数（只补最近的几次），0 表示不补执行
+	LastRun  time.Time              // 上一次计划触发的时间，如进程重启前的最后一次触发，从该时间开始计算错过的触发
+}
+
+// TriggerRecord 任务的一次触发
+type TriggerRecord struct {
+	Job         string    `json:"job"`              // 任务名
+	FlowID      string    `json:"flow_id"`          // 流程ID，跳过和错过的触发为空
+	Trigger     string    `json:"trigger"`          // 触发方式：cron、interval、nats、catch_up、manual
+	ScheduledAt time.Time `json:"scheduled_at"`     // 计划触发时间，NATS 和手动触发为收到的时间
+	StartedAt   time.Time `json:"started_at"`       // 流程开始执行的时间，排队时为零值
+	EndedAt     time.Time `json:"ended_at"`         // 流程结束的时间
+	Status      string    `json:"status"`           // queued、running、skipped、missed，结束后为流程的状态
+	Error       string    `json:"error,omitempty"`  // 流程的错误信息
+	Missed      int       `json:"missed,omitempty"` // 错过的触发次数，连续错过的触发合并为一条 missed 记录，ScheduledAt 为其中最早的一次
+}
+
+// NatsSubscriber 订阅NATS主题，*nats.Conn 实现了该接口
+type NatsSubscriber interface {
+	QueueSubscribe(subj, queue string, cb nats.MsgHandler) (*nats.Subscription, error)
+}
+
+// Scheduler 按 cron 表达式、固定间隔或 NATS 消息触发工作流
+//
+//	scheduler := workflow.NewScheduler(executor)
+//	scheduler.Conn = nc
+//	scheduler.Add(workflow.Job{Name: "日报", Code: code, Cron: "0 9 * * 1-5"})
+//	scheduler.Start(ctx)
+//	defer scheduler.Stop()
+type Scheduler struct {
+	Executor     *Executor
+	Conn         NatsSubscriber // 任务设置了 Subject 时必须设置
+	HistoryLimit int            // 每个任务保留的触发记录数，0 表示100条
+
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	ctx     context.Context // Start 的ctx，流程在其下执行
+	stop    context.CancelFunc
+	running sync.WaitGroup // 计时器、排队和执行中的流程
+}
+
+// scheduledJob 调度器中的任务及其运行状态
+type scheduledJob struct {
+	Job
+	schedules []scheduleTrigger
+	stop      context.CancelFunc
+	sub       *nats.Subscription
+	active    int // 执行中的流程数
+	queue     []*queuedRun
+	history   []*TriggerRecord
+}
+
+// scheduleTrigger 时间触发计划及其触发方式
+type scheduleTrigger struct {
+	schedule Schedule
+	trigger  string
+	last     time.Time // 上一次计划触发的时间，Stop 后再次 Start 时从这里继续，不重复补执行
+}
+
+// queuedRun 排队等待执行的触发
+type queuedRun struct {
+	record *TriggerRecord
+	input  map[string]interface{}
+}
+
+// NewScheduler 创建调度器
+func NewScheduler(executor *Executor) *Scheduler {
+	return &Scheduler{Executor: executor, jobs: make(map[string]*scheduledJob)}
+}
+
+// Add 添加任务，检查工作流代码和触发配置；调度器已启动时立即开始调度
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" {
+		return fmt.Errorf("任务名不能为空")
+	}
+	if result := NewSimpleParser().ParseWorkflow(job.Code); !result.Success {
+		return fmt.Errorf("任务 %s 的工作流解析失败: %s", job.Name, result.Error)
+	}
+	switch job.Overlap {
+	case "":
+		job.Overlap = OverlapSkip
+	case OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return fmt.Errorf("任务 %s 的 Overlap 无效: %s", job.Name, job.Overlap)
+	}
+
+	scheduled := &scheduledJob{Job: job}
+	if job.Cron != "" {
+		schedule, err := ParseCron(job.Cron)
+		if err != nil {
+			return fmt.Errorf("任务 %s: %w", job.Name, err)
+		}
+		scheduled.schedules = append(scheduled.schedules, scheduleTrigger{schedule: schedule, trigger: TriggerCron})
+	}
+	if job.Interval < 0 {
+		return fmt.Errorf("任务 %s 的 Interval 不能为负数", job.Name)
+	}
+	if job.Interval > 0 {
+		scheduled.schedules = append(scheduled.schedules, scheduleTrigger{schedule: everySchedule(job.Interval), trigger: TriggerInterval})
+	}
+	if len(scheduled.schedules) == 0 && job.Subject == "" {
+		return fmt.Errorf("任务 %s 需要设置 Cron、Interval 或 Subject", job.Name)
+	}
+	if job.Subject != "" && s.Conn == nil {
+		return fmt.Errorf("任务 %s 设置了 Subject，调度器需要设置 Conn", job.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("任务已存在: %s", job.Name)
+	}
+	if s.ctx != nil {
+		if err := s.startJob(scheduled); err != nil {
+			return err
+		}
+	}
+	s.jobs[job.Name] = scheduled
+	return nil
+}
+
+// Remove 删除任务，停止触发并取消排队的触发，执行中的流程继续执行
+func (s *Scheduler) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, exists := s.jobs[name]
+	if !exists {
+		return fmt.Errorf("任务不存在: %s", name)
+	}
+	s.stopJob(job)
+	delete(s.jobs, name)
+	return nil
+}
+
+// Start 开始调度所有任务，流程在 ctx 下执行，ctx 结束后停止触发并取消执行中的流程
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return fmt.Errorf("调度器已启动")
+	}
+	s.ctx, s.stop = context.WithCancel(ctx)
+	for _, job := range s.jobs {
+		if err := s.startJob(job); err != nil {
+			for _, started := range s.jobs {
+				s.stopJob(started)
+			}
+			s.stop()
+			s.ctx = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop 停止触发并取消排队的触发，取消执行中的流程（包括等待信号的流程），等待它们结束后返回
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	for _, job := range s.jobs {
+		s.stopJob(job)
+	}
+	if s.stop != nil {
+		s.stop()
+	}
+	s.mu.Unlock()
+	s.running.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.stop = nil, nil
+}
+
+// Trigger 手动触发任务，按任务的 Overlap 处理，返回流程ID；跳过或排队时流程ID为空
+func (s *Scheduler) Trigger(name string, input map[string]interface{}) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, exists := s.jobs[name]
+	if !exists {
+		return "", fmt.Errorf("任务不存在: %s", name)
+	}
+	if s.ctx == nil {
+		return "", fmt.Errorf("调度器未启动")
+	}
+	record := s.fire(job, TriggerManual, time.Now(), input)
+	return record.FlowID, nil
+}
+
+// History 返回任务的触发记录，按触发顺序排列
+func (s *Scheduler) History(name string) []TriggerRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, exists := s.jobs[name]
+	if !exists {
+		return nil
+	}
+	records := make([]TriggerRecord, len(job.history))
+	for i, record := range job.history {
+		records[i] = *record
+	}
+	return records
+}
+
+// startJob 启动任务的计时器和NATS订阅，调用方持有锁
+func (s *Scheduler) startJob(job *scheduledJob) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	if job.Subject != "" {
+		// 同一个队列组中只有一个调度器实例收到消息，多实例部署时每条消息只触发一次
+		sub, err := s.Conn.QueueSubscribe(job.Subject, "workflow.scheduler."+job.Name, func(msg *nats.Msg) {
+			s.onMessage(job, msg)
+		})
+		if err != nil {
+			cancel()
+			return fmt.Errorf("任务 %s 订阅 %s 失败: %w", job.Name, job.Subject, err)
+		}
+		job.sub = sub
+	}
+	job.stop = cancel
+	for i := range job.schedules {
+		schedule := &job.schedules[i]
+		last := schedule.last
+		if last.IsZero() {
+			last = job.LastRun
+		}
+		if last.IsZero() {
+			last = time.Now()
+		}
+		s.running.Add(1)
+		go s.loop(ctx, job, schedule, last)
+	}
+	return nil
+}
+
+// stopJob 停止任务的计时器和订阅，取消排队的触发，调用方持有锁
+func (s *Scheduler) stopJob(job *scheduledJob) {
+	if job.stop != nil {
+		job.stop()
+		job.stop = nil
+	}
+	if job.sub != nil {
+		_ = job.sub.Unsubscribe()
+		job.sub = nil
+	}
+	for _, queued := range job.queue {
+		queued.record.Status = TriggerSkipped
+	}
+	job.queue = nil
+}
+
+// loop 从 last 开始按计划触发任务，直到 ctx 结束或计划不再触发
+func (s *Scheduler) loop(ctx context.Context, job *scheduledJob, schedule *scheduleTrigger, last time.Time) {
+	defer s.running.Done()
+	for {
+		next := schedule.schedule.Next(last)
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// 到期的计划时间，计时器按时触发时只有 next
+		now := time.Now()
+		due, dropped, first := dueTimes(schedule.schedule, next, now, job.CatchUp+1)
+		if len(due) == 0 {
+			continue
+		}
+		last = due[len(due)-1]
+
+		s.mu.Lock()
+		if ctx.Err() != nil {
+			s.mu.Unlock()
+			return
+		}
+		schedule.last = last
+		missed := due
+		if now.Sub(last) < missedTolerance {
+			missed = due[:len(due)-1]
+		}
+		// 只补执行最近的 CatchUp 次，更早的合并为一条错过的记录
+		if extra := len(missed) - job.CatchUp; extra > 0 {
+			if dropped == 0 {
+				first = missed[0]
+			}
+			dropped += extra
+			missed = missed[extra:]
+		}
+		if dropped > 0 {
+			s.record(job, &TriggerRecord{Job: job.Name, Trigger: schedule.trigger, ScheduledAt: first, Status: TriggerMissed, Missed: dropped})
+		}
+		for _, t := range missed {
+			s.fire(job, TriggerCatchUp, t, nil)
+		}
+		if now.Sub(last) < missedTolerance {
+			s.fire(job, schedule.trigger, last, nil)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// dueTimes 返回从 from 到 now 到期的计划时间，只保留最近的 keep 次，更早的只返回次数和其中最早的时间
+//
+// LastRun 很早（如停机一年、@every 1s）时不逐个保存错过的触发，固定间隔的计划直接跳过整段间隔。
+func dueTimes(schedule Schedule, from, now time.Time, keep int) (due []time.Time, dropped int, first time.Time) {
+	t := from
+	if every, ok := schedule.(everySchedule); ok && every > 0 {
+		if n := int(now.Sub(t)/time.Duration(every)) + 1 - keep; n > 0 {
+			first, dropped = t, n
+			t = t.Add(time.Duration(n) * time.Duration(every))
+		}
+	}
+	for ; !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		if len(due) == keep {
+			if dropped == 0 {
+				first = due[0]
+			}
+			dropped++
+			due = append(due[:0], due[1:]...)
+		}
+		due = append(due, t)
+	}
+	return due, dropped, first
+}
+
+// onMessage 收到NATS消息时触发任务，消息体为JSON对象；消息需要回复时回复流程ID或错误
+func (s *Scheduler) onMessage(job *scheduledJob, msg *nats.Msg) {
+	reply := struct {
+		FlowID string `json:"flow_id"`
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}{}
+
+	input := make(map[string]interface{})
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &input); err != nil {
+			reply.Error = fmt.Sprintf("消息不是JSON对象: %v", err)
+		}
+	}
+	if reply.Error == "" {
+		s.mu.Lock()
+		if s.ctx == nil || job.sub == nil {
+			reply.Error = "任务已停止"
+		} else {
+			record := s.fire(job, TriggerNats, time.Now(), input)
+			reply.FlowID, reply.Status = record.FlowID, record.Status
+		}
+		s.mu.Unlock()
+	}
+
+	if msg.Reply != "" {
+		data, _ := json.Marshal(reply)
+		_ = msg.Respond(data)
+	}
+}
+
+// fire 按任务的 Overlap 执行、排队或跳过一次触发，调用方持有锁
+func (s *Scheduler) fire(job *scheduledJob, trigger string, scheduledAt time.Time, input map[string]interface{}) *TriggerRecord {
+	record := &TriggerRecord{Job: job.Name, Trigger: trigger, ScheduledAt: scheduledAt}
+	s.record(job, record)
+	switch {
+	case job.active == 0 || job.Overlap == OverlapAllow:
+		s.run(job, record, input)
+	case job.Overlap == OverlapQueue:
+		record.Status = TriggerQueued
+		job.queue = append(job.queue, &queuedRun{record: record, input: input})
+	default:
+		record.Status = TriggerSkipped
+	}
+	return record
+}
+
+// record 保存触发记录，超出 HistoryLimit 时删除最早的记录，调用方持有锁
+func (s *Scheduler) record(job *scheduledJob, record *TriggerRecord) {
+	limit := s.HistoryLimit
+	if limit <= 0 {
+		limit = 100
+	}
+	job.history = append(job.history, record)
+	if len(job.history) > limit {
+		job.history = append([]*TriggerRecord(nil), job.history[len(job.history)-limit:]...)
+	}
+}
+
+// run 解析工作流并在后台执行，结束后执行排队的下一次触发，调用方持有锁
+func (s *Scheduler) run(job *scheduledJob, record *TriggerRecord, input map[string]interface{}) {
+	workflow := NewSimpleParser().ParseWorkflow(job.Code)
+	for key, value := range job.Input {
+		workflow.InputVars[key] = value
+	}
+	for key, value := range input {
+		workflow.InputVars[key] = value
+	}
+	record.FlowID = workflow.FlowID
+	record.StartedAt = time.Now()
+	record.Status = string(StatusRunning)
+	job.active++
+
+	ctx := s.ctx
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		err := s.Executor.Start(ctx, workflow)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		record.EndedAt = time.Now()
+		record.Status = string(workflow.Status)
+		if err != nil {
+			record.Error = err.Error()
+			// 流程未能开始执行（如流程ID重复）时状态未更新
+			if workflow.Status == "" || workflow.Status == StatusRunning {
+				record.Status = string(StatusFailed)
+			}
+		}
+		job.active--
+		if len(job.queue) > 0 && job.active == 0 {
+			next := job.queue[0]
+			job.queue = job.queue[1:]
+			s.run(job, next.record, next.input)
+		}
+	}()
+}
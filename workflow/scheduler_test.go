@@ -0,0 +1,304 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const schedulerTestCode = `var input = map[string]interface{}{
+    "报表": "日报",
+}
+
+step1 = beiluo.test1.report.generate(name: string "报表") -> (reportId: string "报表编号", err: error "是否失败");
+
+func main() {
+    报表编号, err := step1(input["报表"])
+}`
+
+// schedulerTestConn 记录订阅的消息处理函数，代替NATS服务器
+type schedulerTestConn struct {
+	handlers map[string]nats.MsgHandler
+	queues   map[string]string
+}
+
+func (c *schedulerTestConn) QueueSubscribe(subj, queue string, cb nats.MsgHandler) (*nats.Subscription, error) {
+	c.handlers[subj] = cb
+	c.queues[subj] = queue
+	return &nats.Subscription{Subject: subj, Queue: queue}, nil
+}
+
+// waitHistory 等待任务的触发记录满足条件
+func waitHistory(t *testing.T, s *Scheduler, name string, done func([]TriggerRecord) bool) []TriggerRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		history := s.History(name)
+		if done(history) {
+			return history
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待触发记录超时: %+v", history)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// finished 所有触发都已结束
+func finished(n int) func([]TriggerRecord) bool {
+	return func(history []TriggerRecord) bool {
+		if len(history) < n {
+			return false
+		}
+		for _, record := range history {
+			if record.Status == TriggerQueued || record.Status == string(StatusRunning) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// TestParseCron 测试 cron 表达式的下一次触发时间
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC) // 星期五
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 9-17 * * mon-fri", time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{"30 2 1,15 * *", time.Date(2024, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * 5", time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := schedule.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%s: 下一次触发为 %v，期望 %v", tt.expr, got, tt.want)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "* * * abc *", "@every -1m"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q 应解析失败", expr)
+		}
+	}
+}
+
+// TestScheduler_Overlap 测试固定间隔触发和上一次运行尚未结束时的跳过、排队
+func TestScheduler_Overlap(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"reportId": "R1"}, Delay: 50 * time.Millisecond})
+	s := NewScheduler(h.Executor)
+	for _, job := range []Job{
+		{Name: "skip", Code: schedulerTestCode, Interval: 20 * time.Millisecond},
+		{Name: "queue", Code: schedulerTestCode, Interval: 20 * time.Millisecond, Overlap: OverlapQueue},
+	} {
+		if err := s.Add(job); err != nil {
+			t.Fatalf("添加任务失败: %v", err)
+		}
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	completed := func(n int) func([]TriggerRecord) bool {
+		return func(history []TriggerRecord) bool {
+			count := 0
+			for _, record := range history {
+				if record.Status == string(StatusCompleted) {
+					count++
+				}
+			}
+			return len(history) >= 4 && count >= n
+		}
+	}
+	waitHistory(t, s, "skip", completed(1))
+	waitHistory(t, s, "queue", completed(2))
+	// Stop 取消执行中的流程
+	s.Stop()
+
+	statuses := func(name string) map[string]int {
+		counts := make(map[string]int)
+		for _, record := range s.History(name) {
+			counts[record.Status]++
+			if record.Trigger != TriggerInterval {
+				t.Errorf("触发方式应为 interval: %+v", record)
+			}
+		}
+		return counts
+	}
+	if counts := statuses("skip"); counts[TriggerSkipped] == 0 || counts[string(StatusCompleted)] == 0 || counts[TriggerQueued] != 0 {
+		t.Errorf("上一次运行未结束时应跳过: %v", counts)
+	}
+	// 排队的触发依次执行，停止时未执行的被跳过
+	if counts := statuses("queue"); counts[string(StatusCompleted)] < 2 || counts[TriggerQueued] != 0 || counts[string(StatusRunning)] != 0 {
+		t.Errorf("上一次运行结束后应执行排队的触发: %v", counts)
+	}
+	for _, record := range s.History("queue") {
+		if record.Status == string(StatusCompleted) && (record.FlowID == "" || record.StartedAt.Before(record.ScheduledAt)) {
+			t.Errorf("触发记录不正确: %+v", record)
+		}
+	}
+}
+
+// TestScheduler_CatchUp 测试重启后补执行最近错过的触发
+func TestScheduler_CatchUp(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"reportId": "R1"}})
+	s := NewScheduler(h.Executor)
+	lastRun := time.Now().Add(-3*time.Hour - 5*time.Minute)
+	err := s.Add(Job{Name: "日报", Code: schedulerTestCode, Interval: time.Hour, Overlap: OverlapQueue, CatchUp: 2, LastRun: lastRun})
+	if err != nil {
+		t.Fatalf("添加任务失败: %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	history := waitHistory(t, s, "日报", finished(3))
+	s.Stop()
+
+	if len(history) != 3 || history[0].Status != TriggerMissed || history[0].FlowID != "" {
+		t.Fatalf("超出 CatchUp 的触发应记录为错过: %+v", history)
+	}
+	for i, record := range history[1:] {
+		if record.Trigger != TriggerCatchUp || record.Status != string(StatusCompleted) ||
+			!record.ScheduledAt.Equal(lastRun.Add(time.Duration(i+2)*time.Hour)) {
+			t.Errorf("应补执行最近错过的触发: %+v", record)
+		}
+	}
+	if len(h.Calls()) != 2 {
+		t.Errorf("应补执行2次，实际执行%d次", len(h.Calls()))
+	}
+
+	// 再次启动时从上一次触发继续，不重复补执行
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("再次启动失败: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+	if history := s.History("日报"); len(history) != 3 || len(h.Calls()) != 2 {
+		t.Errorf("再次启动后不应重复补执行: %+v", history)
+	}
+}
+
+// TestScheduler_CatchUpFarPast 测试 LastRun 很早时错过的触发合并为一条记录，不逐个保存
+func TestScheduler_CatchUpFarPast(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"reportId": "R1"}})
+	s := NewScheduler(h.Executor)
+	lastRun := time.Now().Add(-365 * 24 * time.Hour)
+	if err := s.Add(Job{Name: "心跳", Code: schedulerTestCode, Interval: time.Second, CatchUp: 1, LastRun: lastRun}); err != nil {
+		t.Fatalf("添加任务失败: %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	history := waitHistory(t, s, "心跳", func(history []TriggerRecord) bool {
+		return finished(2)(history) && history[1].Trigger == TriggerCatchUp
+	})
+	s.Stop()
+
+	missed := history[0]
+	if missed.Status != TriggerMissed || !missed.ScheduledAt.Equal(lastRun.Add(time.Second)) || missed.Missed < 365*24*3600-3 {
+		t.Fatalf("错过的触发应合并为一条记录: %+v", missed)
+	}
+	if catchUp := history[1]; catchUp.Status != string(StatusCompleted) || time.Since(catchUp.ScheduledAt) > 3*time.Second {
+		t.Errorf("应只补执行最近一次触发: %+v", catchUp)
+	}
+}
+
+// TestScheduler_StopWaiting 测试停止调度器时取消等待信号的流程
+func TestScheduler_StopWaiting(t *testing.T) {
+	s := NewScheduler(NewExecutor())
+	code := "func main() {\n    审批意见 := wait(\"manager_approval\")\n}"
+	if err := s.Add(Job{Name: "审批", Code: code, Cron: "@yearly"}); err != nil {
+		t.Fatalf("添加任务失败: %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	flowID, err := s.Trigger("审批", nil)
+	if err != nil {
+		t.Fatalf("手动触发失败: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if workflow, err := s.Executor.Get(flowID); err == nil && workflow.Status == StatusWaiting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("流程应在等待信号")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop 应取消等待信号的流程后返回")
+	}
+	if history := s.History("审批"); history[0].Status != "cancelled" {
+		t.Errorf("流程应被取消: %+v", history[0])
+	}
+}
+
+// TestScheduler_Nats 测试NATS消息触发，消息体作为输入参数
+func TestScheduler_Nats(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"reportId": "R1"}})
+	conn := &schedulerTestConn{handlers: make(map[string]nats.MsgHandler), queues: make(map[string]string)}
+	s := NewScheduler(h.Executor)
+	s.Conn = conn
+	if err := s.Add(Job{Name: "报表", Code: schedulerTestCode, Subject: "report.requested"}); err != nil {
+		t.Fatalf("添加任务失败: %v", err)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	defer s.Stop()
+	if conn.queues["report.requested"] != "workflow.scheduler.报表" {
+		t.Errorf("应使用队列订阅: %v", conn.queues)
+	}
+
+	conn.handlers["report.requested"](&nats.Msg{Subject: "report.requested", Data: []byte(`{"报表": "周报"}`)})
+	history := waitHistory(t, s, "报表", finished(1))
+	if history[0].Trigger != TriggerNats || history[0].Status != string(StatusCompleted) || history[0].FlowID == "" {
+		t.Errorf("触发记录不正确: %+v", history[0])
+	}
+	if calls := h.Calls(); len(calls) != 1 || calls[0].Input["name"] != "周报" {
+		t.Errorf("消息体应作为输入参数: %+v", calls)
+	}
+
+	// 手动触发
+	flowID, err := s.Trigger("报表", map[string]interface{}{"报表": "月报"})
+	if err != nil || flowID == "" {
+		t.Fatalf("手动触发失败: %q %v", flowID, err)
+	}
+	history = waitHistory(t, s, "报表", finished(2))
+	if history[1].FlowID != flowID || history[1].Trigger != TriggerManual {
+		t.Errorf("手动触发记录不正确: %+v", history[1])
+	}
+
+	if err := s.Add(Job{Name: "空任务", Code: schedulerTestCode}); err == nil {
+		t.Error("未设置触发方式的任务应添加失败")
+	}
+	if err := s.Add(Job{Name: "报表", Code: schedulerTestCode, Cron: "@hourly"}); err == nil {
+		t.Error("同名任务应添加失败")
+	}
+}
@@ -2,16 +2,21 @@ package workflow
 
 import (
 	"fmt"
+	"go/token"
 	"math/rand"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // 简单解析器
 type SimpleParser struct{}
 
+// 打印语句：接收者为 sys、fmt 或步骤名，方法为 Print/Printf/Println
+var printStatementRegex = regexp.MustCompile(`^([\w\p{Han}]+)\.(Printf|Println|Print)\(`)
+
 // 生成FlowID
 func generateFlowID() string {
 	return fmt.Sprintf("flow_%d_%d", time.Now().UnixNano(), rand.Intn(10000))
@@ -27,6 +32,14 @@ type DefaultMetadata struct {
 	LogLevel    string         `json:"log_level"`    // 日志级别，默认info
 	AIModel     string         `json:"ai_model"`     // AI模型，默认空
 	ErrContinue bool           `json:"err_continue"` // 错误时是否继续执行，默认false（出错终止）
+
+	RetryBackoff string        `json:"retry_backoff"` // 重试间隔增长方式，默认linear
+	RetryInitial time.Duration `json:"retry_initial"` // 第一次重试前的等待时间，默认1秒
+	RetryMax     time.Duration `json:"retry_max"`     // 重试间隔上限，默认0不限制
+	RetryJitter  float64       `json:"retry_jitter"`  // 重试间隔随机抖动比例，默认0
+
+	MaxIterations int `json:"max_iterations"` // 条件循环的最大迭代次数，默认1000
+	MaxParallel   int `json:"max_parallel"`   // 同时执行的异步步骤数量上限，默认10
 }
 
 // 获取默认元数据
@@ -40,20 +53,52 @@ func GetDefaultMetadata() DefaultMetadata {
 		LogLevel:    "info",
 		AIModel:     "",
 		ErrContinue: false, // 默认出错终止
+
+		RetryBackoff: BackoffLinear,
+		RetryInitial: time.Second,
+		RetryMax:     0,
+		RetryJitter:  0,
+
+		MaxIterations: 1000,
+		MaxParallel:   10,
 	}
 }
 
 // 解析结果
 type SimpleParseResult struct {
-	FlowID string `json:"flow_id"`
-
-	Success    bool                    `json:"success"`     // 解析是否成功
-	InputVars  map[string]interface{}  `json:"input_vars"`  // 输入变量
-	Steps      []*SimpleStep           `json:"steps"`       // 工作流步骤
-	MainFunc   *SimpleMainFunc         `json:"main_func"`   // 主函数
-	Variables  map[string]VariableInfo `json:"variables"`   // 变量映射表
-	GlobalLogs []*StepLog              `json:"global_logs"` // 全局日志
-	Error      string                  `json:"error"`       // 错误信息
+	FlowID string          `json:"flow_id"`
+	Status StatementStatus `json:"status"` // 工作流执行状态：running、completed、failed、cancelled、timeout，未执行时为空
+
+	Success    bool                    `json:"success"`          // 解析是否成功
+	InputVars  map[string]interface{}  `json:"input_vars"`       // 输入变量
+	Steps      []*SimpleStep           `json:"steps"`            // 工作流步骤
+	MainFunc   *SimpleMainFunc         `json:"main_func"`        // 主函数
+	Variables  map[string]VariableInfo `json:"variables"`        // 变量映射表
+	GlobalLogs []*StepLog              `json:"global_logs"`      // 全局日志
+	Error      string                  `json:"error"`            // 错误信息
+	Errors     []ParseError            `json:"errors,omitempty"` // 解析错误列表，带行号和列号
+
+	MaxParallel int           `json:"max_parallel"` // 异步步骤最大并发数，通过 //max_parallel: N 设置，0表示使用默认值
+	Timeout     time.Duration `json:"timeout"`      // 工作流整体超时时间，通过 //workflow_timeout: 毫秒 设置，0表示无限制
+
+	ParentFlowID string   `json:"parent_flow_id,omitempty"` // 作为子工作流执行时，父流程的ID
+	ChildFlowIDs []string `json:"child_flow_ids,omitempty"` // 启动的子工作流的流程ID，按启动顺序
+
+	Compensations []*Compensation `json:"compensations,omitempty"` // 已完成步骤的补偿记录，按步骤完成顺序
+
+	EventSeq uint64     `json:"event_seq,omitempty"` // 最近一次发布的执行事件序号，见 Executor.Subscribe
+	Paused   *PauseInfo `json:"paused,omitempty"`    // 流程暂停时暂停的位置，见 Executor.Pause
+
+	// 保护 Variables，异步步骤执行时与业务侧读取并发；每个工作流一把锁，快照使用新的锁
+	variablesMu *sync.RWMutex
+}
+
+// variablesLock 返回保护 Variables 的锁，解析、加载和执行前创建，直接构造的结果在首次使用时创建
+func (r *SimpleParseResult) variablesLock() *sync.RWMutex {
+	if r.variablesMu == nil {
+		r.variablesMu = &sync.RWMutex{}
+	}
+	return r.variablesMu
 }
 
 // 变量信息
@@ -103,42 +148,81 @@ const (
 
 // 步骤日志
 type StepLog struct {
-	Timestamp time.Time `json:"timestamp"` // 日志时间
-	Level     string    `json:"level"`     // 日志级别 (info, warn, error)
-	Message   string    `json:"message"`   // 日志内容
-	Source    string    `json:"source"`    // 日志来源 (step1.Printf, fmt.Print等)
+	Timestamp time.Time `json:"timestamp"`         // 日志时间
+	Level     string    `json:"level"`             // 日志级别 (info, warn, error)
+	Message   string    `json:"message"`           // 日志内容
+	Source    string    `json:"source"`            // 日志来源 (step1.Printf, fmt.Print等)
+	Attempt   int       `json:"attempt,omitempty"` // 重试记录对应的尝试序号，从1开始
+	Error     string    `json:"error,omitempty"`   // 重试记录对应的错误信息
 }
 
 // 语句
 type SimpleStatement struct {
-	Type       string                 `json:"type"`        // 语句类型
-	Content    string                 `json:"content"`     // 语句内容
-	LineNumber int                    `json:"line_number"` // 行号
-	Children   []*SimpleStatement     `json:"children"`    // 嵌套语句，如if语句的body
-	Condition  string                 `json:"condition"`   // 条件表达式，如if语句的条件
-	Function   string                 `json:"function"`    // 函数名，如step1()
-	Args       []*ArgumentInfo        `json:"args"`        // 函数输入参数信息
-	Returns    []*ArgumentInfo        `json:"returns"`     // 函数输出参数信息
-	Metadata   map[string]interface{} `json:"metadata"`    // 元数据配置，如 {retry:1, timeout:2000}
-	Status     StatementStatus        `json:"status"`      // 执行状态
-	RetryCount int                    `json:"retry_count"` // 重试次数
-	Desc       string                 `json:"desc"`        // 步骤描述信息
-	StartTime  *time.Time             `json:"start_time"`  // 开始执行时间
-	EndTime    *time.Time             `json:"end_time"`    // 结束执行时间
-	Duration   time.Duration          `json:"duration"`    // 执行耗时
+	Type       string                 `json:"type"`             // 语句类型
+	Content    string                 `json:"content"`          // 语句内容
+	LineNumber int                    `json:"line_number"`      // 行号
+	Children   []*SimpleStatement     `json:"children"`         // 嵌套语句，如if语句的body
+	Branches   []*SimpleStatement     `json:"branches"`         // if语句的 else if / else 分支，类型为branch
+	Condition  string                 `json:"condition"`        // 条件表达式，如if语句的条件，else分支为空
+	Function   string                 `json:"function"`         // 函数名，如step1()
+	Args       []*ArgumentInfo        `json:"args"`             // 函数输入参数信息
+	Returns    []*ArgumentInfo        `json:"returns"`          // 函数输出参数信息
+	Metadata   map[string]interface{} `json:"metadata"`         // 元数据配置，如 {retry:1, timeout:2000}
+	Status     StatementStatus        `json:"status"`           // 执行状态
+	RetryCount int                    `json:"retry_count"`      // 重试次数
+	Cached     bool                   `json:"cached,omitempty"` // 步骤结果来自缓存（cache 元数据）
+	Desc       string                 `json:"desc"`             // 步骤描述信息
+	StartTime  *time.Time             `json:"start_time"`       // 开始执行时间
+	EndTime    *time.Time             `json:"end_time"`         // 结束执行时间
+	Duration   time.Duration          `json:"duration"`         // 执行耗时
+	Loop       *LoopInfo              `json:"loop"`             // 循环信息（仅for语句），Children为循环体模板
+	Wait       *WaitInfo              `json:"wait,omitempty"`   // 等待信号信息（仅wait语句）
+}
+
+// 循环类型
+const (
+	LoopKindRange = "range" // for i, item := range 列表
+	LoopKindCond  = "cond"  // for 条件 {}，条件为空时为无限循环，受最大迭代次数保护
+)
+
+// 循环信息
+type LoopInfo struct {
+	Kind       string           `json:"kind"`       // 循环类型：range、cond
+	KeyVar     string           `json:"key_var"`    // range的下标变量，如 i
+	ValueVar   string           `json:"value_var"`  // range的元素变量，如 item
+	RangeExpr  string           `json:"range_expr"` // range的目标表达式
+	Total      int              `json:"total"`      // 总迭代次数，条件循环为0表示未知
+	Completed  int              `json:"completed"`  // 已完成迭代次数
+	Iterations []*LoopIteration `json:"iterations"` // 每次迭代的执行记录
+}
+
+// 循环迭代记录
+type LoopIteration struct {
+	Index      int                     `json:"index"`      // 迭代序号，从0开始
+	Key        interface{}             `json:"key"`        // range的下标或map的键
+	Value      interface{}             `json:"value"`      // range的元素
+	Statements []*SimpleStatement      `json:"statements"` // 本次迭代执行的语句（循环体的副本）
+	Variables  map[string]VariableInfo `json:"variables"`  // 本次迭代作用域内的变量
+	Logs       []*StepLog              `json:"logs"`       // 本次迭代产生的日志
+	Status     StatementStatus         `json:"status"`     // 执行状态
+	StartTime  *time.Time              `json:"start_time"` // 开始执行时间
+	EndTime    *time.Time              `json:"end_time"`   // 结束执行时间
+	Duration   time.Duration           `json:"duration"`   // 执行耗时
 }
 
 // 简单步骤定义
 type SimpleStep struct {
-	Name         string                 `json:"name"`          // 步骤名称
-	Function     string                 `json:"function"`      // 函数名
-	InputParams  []ParameterInfo        `json:"input_params"`  // 输入参数定义
-	OutputParams []ParameterInfo        `json:"output_params"` // 输出参数定义
-	IsStatic     bool                   `json:"is_static"`     // 是否为静态工作流
-	CaseID       string                 `json:"case_id"`       // 用例ID
-	Logs         []*StepLog             `json:"logs"`          // 步骤日志
-	Desc         string                 `json:"desc"`          // 步骤描述信息
-	Metadata     map[string]interface{} `json:"metadata"`      // 元数据配置
+	Name         string                 `json:"name"`                   // 步骤名称
+	LineNumber   int                    `json:"line_number"`            // 定义所在行号
+	Function     string                 `json:"function"`               // 函数名
+	InputParams  []ParameterInfo        `json:"input_params"`           // 输入参数定义
+	OutputParams []ParameterInfo        `json:"output_params"`          // 输出参数定义
+	IsStatic     bool                   `json:"is_static"`              // 是否为静态工作流
+	CaseID       string                 `json:"case_id"`                // 用例ID
+	Logs         []*StepLog             `json:"logs"`                   // 步骤日志
+	Desc         string                 `json:"desc"`                   // 步骤描述信息
+	Metadata     map[string]interface{} `json:"metadata"`               // 元数据配置
+	SubWorkflow  *SubWorkflowRef        `json:"sub_workflow,omitempty"` // 引用的子工作流，函数名为 workflow.名称.版本 时设置
 }
 
 // 添加步骤日志
@@ -163,134 +247,63 @@ func (r *SimpleParseResult) AddGlobalLog(level, message, source string) {
 	r.GlobalLogs = append(r.GlobalLogs, log)
 }
 
-// 简单类型定义
-type SimpleTypeDef struct {
-	Type string `json:"type"` // 类型
-	Name string `json:"name"` // 名称
-}
-
-// 创建简单解析器
-func NewSimpleParser() *SimpleParser {
-	return &SimpleParser{}
+// GetVariable 并发安全地读取变量
+func (r *SimpleParseResult) GetVariable(name string) (VariableInfo, bool) {
+	mu := r.variablesLock()
+	mu.RLock()
+	defer mu.RUnlock()
+	info, exists := r.Variables[name]
+	return info, exists
 }
 
-// 解析工作流
-func (p *SimpleParser) ParseWorkflow(code string) *SimpleParseResult {
-	result := &SimpleParseResult{
-		FlowID:     generateFlowID(),
-		Success:    true,
-		InputVars:  make(map[string]interface{}),
-		Steps:      []*SimpleStep{},
-		MainFunc:   &SimpleMainFunc{Statements: []*SimpleStatement{}},
-		Variables:  make(map[string]VariableInfo),
-		GlobalLogs: make([]*StepLog, 0),
-	}
-
-	// 检查空代码
-	code = strings.TrimSpace(code)
-	if code == "" {
-		result.Success = false
-		result.Error = "代码为空"
-		return result
+// SetVariable 并发安全地写入变量
+func (r *SimpleParseResult) SetVariable(name string, info VariableInfo) {
+	mu := r.variablesLock()
+	mu.Lock()
+	defer mu.Unlock()
+	if r.Variables == nil {
+		r.Variables = make(map[string]VariableInfo)
 	}
-
-	lines := strings.Split(code, "\n")
-
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "//") {
-			continue
-		}
-
-		// 解析输入变量
-		if strings.HasPrefix(line, "var input") {
-			inputVars, err := p.parseInputVars(code)
-			if err != nil {
-				result.Success = false
-				result.Error = err.Error()
-				return result
-			}
-			result.InputVars = inputVars
-			continue
-		}
-
-		// 解析步骤定义 - 单行格式
-		if strings.Contains(line, "=") && (strings.Contains(line, "->") || strings.Contains(line, "beiluo.")) {
-			step, err := p.parseStep(line, i+1, lines)
-			if err != nil {
-				result.Success = false
-				result.Error = err.Error()
-				return result
-			}
-			result.Steps = append(result.Steps, step)
-		}
-
-		// 解析main函数
-		if strings.HasPrefix(line, "func main()") {
-			mainFunc, err := p.parseMainFunction(code, lines, result)
-			if err != nil {
-				result.Success = false
-				result.Error = err.Error()
-				return result
-			}
-			result.MainFunc = mainFunc
-		}
-	}
-
-	// 检查是否有main函数
-	if result.MainFunc == nil || len(result.MainFunc.Statements) == 0 {
-		result.Success = false
-		result.Error = "缺少main函数"
-		return result
-	}
-
-	return result
+	r.Variables[name] = info
 }
 
-// 解析输入变量
-func (p *SimpleParser) parseInputVars(code string) (map[string]interface{}, error) {
-	// 找到 var input = map[string]interface{}{ ... } 部分
-	start := strings.Index(code, "var input")
-	if start == -1 {
-		return nil, fmt.Errorf("未找到输入变量定义")
-	}
+// DeleteVariable 并发安全地删除变量
+func (r *SimpleParseResult) DeleteVariable(name string) {
+	mu := r.variablesLock()
+	mu.Lock()
+	defer mu.Unlock()
+	delete(r.Variables, name)
+}
 
-	// 找到第二个 { (map[string]interface{ 后面的 {)
-	firstBrace := strings.Index(code[start:], "{")
-	if firstBrace == -1 {
-		return nil, fmt.Errorf("输入变量定义格式错误")
+// VariablesSnapshot 返回变量映射表的副本，执行过程中业务侧应通过它读取变量
+func (r *SimpleParseResult) VariablesSnapshot() map[string]VariableInfo {
+	mu := r.variablesLock()
+	mu.RLock()
+	defer mu.RUnlock()
+	snapshot := make(map[string]VariableInfo, len(r.Variables))
+	for name, info := range r.Variables {
+		snapshot[name] = info
 	}
-	firstBrace += start
+	return snapshot
+}
 
-	// 找到第二个 {
-	braceStart := strings.Index(code[firstBrace+1:], "{")
-	if braceStart == -1 {
-		return nil, fmt.Errorf("输入变量定义格式错误")
-	}
-	braceStart += firstBrace + 1
-
-	// 找到匹配的 }
-	braceCount := 0
-	braceEnd := -1
-	for i := braceStart; i < len(code); i++ {
-		if code[i] == '{' {
-			braceCount++
-		} else if code[i] == '}' {
-			braceCount--
-			if braceCount == 0 {
-				braceEnd = i
-				break
-			}
-		}
+// GetMaxParallel 获取异步步骤最大并发数
+func (r *SimpleParseResult) GetMaxParallel() int {
+	if r.MaxParallel > 0 {
+		return r.MaxParallel
 	}
+	return GetDefaultMetadata().MaxParallel
+}
 
-	if braceEnd == -1 {
-		return nil, fmt.Errorf("输入变量定义括号不匹配")
-	}
+// 简单类型定义
+type SimpleTypeDef struct {
+	Type string `json:"type"` // 类型
+	Name string `json:"name"` // 名称
+}
 
-	// 提取map内容
-	mapContent := code[braceStart+1 : braceEnd]
-	return p.parseMapContent(mapContent)
+// 创建简单解析器
+func NewSimpleParser() *SimpleParser {
+	return &SimpleParser{}
 }
 
 // 解析map内容
@@ -335,96 +348,26 @@ func (p *SimpleParser) parseMapContent(content string) (map[string]interface{},
 	return result, nil
 }
 
-// 解析步骤定义（公开方法）
+// 解析步骤定义（公开方法），单独解析的步骤没有行号和描述
 func (p *SimpleParser) ParseStep(line string) (*SimpleStep, error) {
-	return p.parseStep(line, 0, nil)
-}
-
-// 解析步骤定义
-func (p *SimpleParser) parseStep(line string, lineNumber int, lines []string) (*SimpleStep, error) {
-	step := &SimpleStep{}
-
-	// 根据 = 分隔
-	parts := strings.SplitN(line, "=", 2)
-	if len(parts) != 2 {
-		return step, fmt.Errorf("步骤定义格式错误: %s", line)
+	d := newDSLParser(p, strings.TrimSpace(line), &SimpleParseResult{})
+	d.tokenize()
+	if len(d.scanErrors) > 0 {
+		return nil, fmt.Errorf("步骤定义格式错误: %s", d.scanErrors[0].Msg)
 	}
-
-	step.Name = strings.TrimSpace(parts[0])
-
-	// 根据 -> 分隔输入和输出
-	arrowParts := strings.SplitN(parts[1], "->", 2)
-	if len(arrowParts) != 2 {
-		return step, fmt.Errorf("步骤定义缺少 -> 分隔符: %s", line)
+	end := d.statementEnd(0)
+	if end < 2 || d.toks[0].tok != token.IDENT || d.toks[1].tok != token.ASSIGN {
+		return nil, fmt.Errorf("步骤定义格式错误: %s", line)
 	}
-
-	inputPart := strings.TrimSpace(arrowParts[0])
-	outputPart := strings.TrimSpace(arrowParts[1])
-
-	// 解析输入部分
-	inputTypes, function, isStatic, caseID, err := p.parseInputPart(inputPart)
+	step, _, err := d.parseStep(0, end)
 	if err != nil {
-		return step, err
-	}
-
-	step.Function = function
-	step.InputParams = inputTypes
-	step.IsStatic = isStatic
-	step.CaseID = caseID
-
-	// 解析输出部分和元数据
-	outputTypes, metadata, err := p.parseOutputPartWithMetadata(outputPart)
-	if err != nil {
-		return step, err
-	}
-
-	step.OutputParams = outputTypes
-	step.Metadata = metadata
-	step.Logs = make([]*StepLog, 0)
-
-	// 提取描述信息
-	if lines != nil && lineNumber > 0 {
-		step.Desc = p.extractDescription(lines, lineNumber-1)
+		return nil, err
 	}
-
+	step.LineNumber = 0
+	step.Desc = ""
 	return step, nil
 }
 
-// 解析输入部分
-func (p *SimpleParser) parseInputPart(inputPart string) ([]ParameterInfo, string, bool, string, error) {
-	// 检查是否为静态工作流 [用例ID]
-	// 静态工作流的特征是：函数名[用例ID]，且用例ID不包含复杂类型字符
-	if strings.Contains(inputPart, "[") && strings.Contains(inputPart, "]") {
-		// 使用更精确的正则表达式匹配静态工作流格式
-		// 静态工作流格式：functionName[caseID]，其中caseID通常是简单的字符串或数字
-		re := regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_.]*)\[([^\]]+)\]$`)
-		matches := re.FindStringSubmatch(inputPart)
-		if len(matches) == 3 {
-			function := strings.TrimSpace(matches[1])
-			caseID := strings.TrimSpace(matches[2])
-			return []ParameterInfo{}, function, true, caseID, nil
-		}
-		// 如果不匹配静态工作流格式，继续处理为动态工作流
-	}
-
-	// 动态工作流 function(param: type "desc", param: type "desc")
-	if strings.Contains(inputPart, "(") && strings.Contains(inputPart, ")") {
-		// 提取函数名
-		parenIndex := strings.Index(inputPart, "(")
-		function := strings.TrimSpace(inputPart[:parenIndex])
-
-		// 提取参数部分
-		paramPart := inputPart[parenIndex+1 : strings.LastIndex(inputPart, ")")]
-
-		// 解析参数定义
-		inputParams := p.parseParameterDefinitions(paramPart)
-
-		return inputParams, function, false, "", nil
-	}
-
-	return nil, "", false, "", fmt.Errorf("无法解析输入部分: %s", inputPart)
-}
-
 // 解析输出部分和元数据
 func (p *SimpleParser) parseOutputPartWithMetadata(outputPart string) ([]ParameterInfo, map[string]interface{}, error) {
 	// 移除分号
@@ -459,7 +402,11 @@ func (p *SimpleParser) parseOutputPartWithMetadata(outputPart string) ([]Paramet
 		outputPart = outputPart[1 : len(outputPart)-1]
 	}
 
-	// 解析输出参数
+	return p.parseOutputParams(outputPart), metadata, nil
+}
+
+// 解析输出参数，outputPart 不含括号和元数据
+func (p *SimpleParser) parseOutputParams(outputPart string) []ParameterInfo {
 	var outputParams []ParameterInfo
 	// 检查是否为新格式（包含冒号和引号）
 	if strings.Contains(outputPart, ":") && strings.Contains(outputPart, "\"") {
@@ -468,8 +415,7 @@ func (p *SimpleParser) parseOutputPartWithMetadata(outputPart string) ([]Paramet
 		// 旧格式：bool 验证结果, err 是否失败
 		outputParams = p.parseLegacyOutputPart(outputPart)
 	}
-
-	return outputParams, metadata, nil
+	return outputParams
 }
 
 // 解析输出部分（保持向后兼容）
@@ -537,385 +483,6 @@ func (p *SimpleParser) parseTypeList(typeList string) ([]SimpleTypeDef, error) {
 	return result, nil
 }
 
-// 解析main函数
-func (p *SimpleParser) parseMainFunction(code string, lines []string, result *SimpleParseResult) (*SimpleMainFunc, error) {
-	mainFunc := &SimpleMainFunc{Statements: []*SimpleStatement{}}
-
-	// 找到main函数开始位置
-	mainStart := -1
-	for i, line := range lines {
-		if strings.HasPrefix(strings.TrimSpace(line), "func main()") {
-			mainStart = i
-			break
-		}
-	}
-
-	if mainStart == -1 {
-		return mainFunc, nil
-	}
-
-	// 找到main函数结束位置（匹配的右括号）
-	braceCount := 0
-	mainEnd := -1
-	for i := mainStart; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
-
-		// 计算大括号
-		for _, char := range line {
-			if char == '{' {
-				braceCount++
-			} else if char == '}' {
-				braceCount--
-				if braceCount == 0 {
-					mainEnd = i
-					break
-				}
-			}
-		}
-
-		if mainEnd != -1 {
-			break
-		}
-	}
-
-	if mainEnd == -1 {
-		mainEnd = len(lines) - 1
-	}
-
-	// 解析main函数内的语句（支持嵌套）
-	statements, _ := p.parseStatements(lines, mainStart+1, mainEnd, result)
-	mainFunc.Statements = statements
-
-	return mainFunc, nil
-}
-
-// 提取描述信息
-func (p *SimpleParser) extractDescription(lines []string, currentIndex int) string {
-	// 检查当前行之前是否有 //desc: 注释
-	for j := currentIndex - 1; j >= 0; j-- {
-		prevLine := strings.TrimSpace(lines[j])
-		if prevLine == "" {
-			continue
-		}
-		if strings.HasPrefix(prevLine, "//desc:") {
-			return strings.TrimSpace(prevLine[7:]) // 去掉 "//desc:" 前缀
-		}
-		if !strings.HasPrefix(prevLine, "//") {
-			break // 遇到非注释行，停止查找
-		}
-	}
-	return ""
-}
-
-// 解析语句列表（支持嵌套）
-func (p *SimpleParser) parseStatements(lines []string, start, end int, result *SimpleParseResult) ([]*SimpleStatement, int) {
-	var statements []*SimpleStatement
-
-	for i := start; i < end; i++ {
-		line := strings.TrimSpace(lines[i])
-		if line == "" || line == "{" || line == "}" {
-			continue
-		}
-
-		// 跳过纯注释行
-		if strings.HasPrefix(line, "//") {
-			continue
-		}
-
-		// 检查是否是if语句
-		if strings.HasPrefix(line, "if ") {
-			ifStmt, nextIndex := p.parseIfStatement(lines, i, result)
-			statements = append(statements, ifStmt)
-			i = nextIndex - 1 // -1 因为循环会+1
-			continue
-		}
-
-		// 解析普通语句
-		statement := p.parseStatement(line, i+1, result)
-		if statement != nil {
-			// 提取描述信息
-			statement.Desc = p.extractDescription(lines, i)
-			statements = append(statements, statement)
-		}
-	}
-
-	return statements, end
-}
-
-// 解析if语句
-func (p *SimpleParser) parseIfStatement(lines []string, start int, result *SimpleParseResult) (*SimpleStatement, int) {
-	// 简化为单分支if语句，多分支通过递归解析处理
-	line := strings.TrimSpace(lines[start])
-
-	// 提取条件
-	condition := ""
-	if strings.HasPrefix(line, "if ") {
-		condition = strings.TrimSpace(line[3:])
-		// 移除末尾的 {
-		condition = strings.TrimSuffix(condition, "{")
-		condition = strings.TrimSpace(condition)
-	}
-
-	// 找到if语句的结束位置
-	braceCount := 0
-	ifEnd := -1
-	for i := start; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
-
-		// 计算大括号
-		for _, char := range line {
-			if char == '{' {
-				braceCount++
-			} else if char == '}' {
-				braceCount--
-				if braceCount == 0 {
-					ifEnd = i
-					break
-				}
-			}
-		}
-
-		if ifEnd != -1 {
-			break
-		}
-	}
-
-	if ifEnd == -1 {
-		ifEnd = len(lines) - 1
-	}
-
-	// 解析if语句体内的语句
-	children, _ := p.parseStatements(lines, start+1, ifEnd, result)
-
-	// 提取描述信息
-	desc := p.extractDescription(lines, start)
-
-	return &SimpleStatement{
-		Type:       "if",
-		Content:    line,
-		LineNumber: start + 1,
-		Condition:  condition,
-		Children:   children,
-		Status:     StatusPending,
-		RetryCount: 0,
-		Desc:       desc,
-	}, ifEnd + 1
-}
-
-// 解析语句
-func (p *SimpleParser) parseStatement(line string, lineNumber int, result *SimpleParseResult) *SimpleStatement {
-	line = strings.TrimSpace(line)
-	if line == "" {
-		return nil
-	}
-
-	// 移除末尾的分号
-	line = strings.TrimSuffix(line, ";")
-
-	// 判断语句类型
-	// 注意：已移除打印语句支持，执行引擎会自动处理日志记录
-
-	// 注意：已移除所有打印语句支持，执行引擎会自动处理日志记录
-
-	if strings.HasPrefix(line, "return") {
-		return &SimpleStatement{
-			Type:       "return",
-			Content:    line,
-			LineNumber: lineNumber,
-			Status:     StatusPending,
-			RetryCount: 0,
-		}
-	}
-
-	if strings.Contains(line, " := ") && !strings.Contains(line, "(") {
-		// 变量赋值 - 解析为var
-		parts := strings.SplitN(line, " := ", 2)
-		if len(parts) == 2 {
-			varName := strings.TrimSpace(parts[0])
-			_ = strings.TrimSpace(parts[1]) // 暂时不使用，后续可以用于类型推断
-
-			// 建立变量映射
-			result.Variables[varName] = VariableInfo{
-				Name:    varName,
-				Type:    "string", // 默认为string，可以根据值推断
-				Source:  "assignment",
-				LineNum: lineNumber,
-				IsInput: false,
-			}
-
-			return &SimpleStatement{
-				Type:       "var",
-				Content:    line,
-				LineNumber: lineNumber,
-				Status:     StatusPending,
-				RetryCount: 0,
-			}
-		}
-	}
-
-	if strings.Contains(line, " := ") && strings.Contains(line, "(") {
-		// 函数调用赋值 - 解析为function-call
-		stmt := &SimpleStatement{
-			Type:       "function-call",
-			Content:    line,
-			LineNumber: lineNumber,
-			Metadata:   make(map[string]interface{}),
-			Status:     StatusPending,
-			RetryCount: 0,
-		}
-
-		// 解析函数名和参数
-		parts := strings.SplitN(line, " := ", 2)
-		if len(parts) == 2 {
-			funcCall := strings.TrimSpace(parts[1])
-			if strings.Contains(funcCall, "(") && strings.Contains(funcCall, ")") {
-				// 检查是否有元数据
-				if strings.Contains(funcCall, "){") && strings.Contains(funcCall, "}") {
-					// 分离函数调用和元数据
-					braceIndex := strings.Index(funcCall, "){")
-					funcPart := funcCall[:braceIndex+1] // 包含右括号
-					metadataPart := funcCall[braceIndex+1:]
-
-					// 解析元数据
-					stmt.Metadata = p.parseMetadata(metadataPart)
-					funcCall = funcPart
-				}
-
-				// 提取函数名
-				funcStart := strings.Index(funcCall, "(")
-				funcName := strings.TrimSpace(funcCall[:funcStart])
-				stmt.Function = funcName
-
-				// 提取参数
-				paramStart := funcStart + 1
-				paramEnd := strings.LastIndex(funcCall, ")")
-				if paramEnd > paramStart {
-					paramStr := strings.TrimSpace(funcCall[paramStart:paramEnd])
-					if paramStr != "" {
-						stmt.Args = p.parseArguments(paramStr, funcName, result)
-					}
-				}
-
-				// 解析返回变量并建立映射
-				stmt.Returns = p.parseReturnVariables(parts[0], funcName, lineNumber, result)
-			}
-		}
-
-		return stmt
-	}
-
-	if strings.Contains(line, " = ") && strings.Contains(line, "(") {
-		// 函数调用赋值 - 解析为function-call
-		stmt := &SimpleStatement{
-			Type:       "function-call",
-			Content:    line,
-			LineNumber: lineNumber,
-			Metadata:   make(map[string]interface{}),
-			Status:     StatusPending,
-			RetryCount: 0,
-		}
-
-		// 解析函数名和参数
-		parts := strings.SplitN(line, " = ", 2)
-		if len(parts) == 2 {
-			funcCall := strings.TrimSpace(parts[1])
-			if strings.Contains(funcCall, "(") && strings.Contains(funcCall, ")") {
-				// 检查是否有元数据
-				if strings.Contains(funcCall, "){") && strings.Contains(funcCall, "}") {
-					// 分离函数调用和元数据
-					braceIndex := strings.Index(funcCall, "){")
-					funcPart := funcCall[:braceIndex+1] // 包含右括号
-					metadataPart := funcCall[braceIndex+1:]
-
-					// 解析元数据
-					stmt.Metadata = p.parseMetadata(metadataPart)
-					funcCall = funcPart
-				}
-
-				// 提取函数名
-				funcStart := strings.Index(funcCall, "(")
-				funcName := strings.TrimSpace(funcCall[:funcStart])
-				stmt.Function = funcName
-
-				// 提取参数
-				paramStart := funcStart + 1
-				paramEnd := strings.LastIndex(funcCall, ")")
-				if paramEnd > paramStart {
-					paramStr := strings.TrimSpace(funcCall[paramStart:paramEnd])
-					if paramStr != "" {
-						stmt.Args = p.parseArguments(paramStr, funcName, result)
-					}
-				}
-			}
-		}
-
-		return stmt
-	}
-
-	if strings.Contains(line, " = ") {
-		// 变量赋值
-		return &SimpleStatement{
-			Type:       "assign",
-			Content:    line,
-			LineNumber: lineNumber,
-			Status:     StatusPending,
-			RetryCount: 0,
-		}
-	}
-
-	if strings.Contains(line, "(") && strings.Contains(line, ")") {
-		// 纯函数调用（无赋值）
-		stmt := &SimpleStatement{
-			Type:       "function-call",
-			Content:    line,
-			LineNumber: lineNumber,
-			Metadata:   make(map[string]interface{}),
-			Status:     StatusPending,
-			RetryCount: 0,
-		}
-
-		// 检查是否有元数据
-		funcCall := line
-		if strings.Contains(line, "){") && strings.Contains(line, "}") {
-			// 分离函数调用和元数据
-			braceIndex := strings.Index(line, "){")
-			funcPart := line[:braceIndex+1] // 包含右括号
-			metadataPart := line[braceIndex+1:]
-
-			// 解析元数据
-			stmt.Metadata = p.parseMetadata(metadataPart)
-			funcCall = funcPart
-		}
-
-		// 解析函数名和参数
-		funcStart := strings.Index(funcCall, "(")
-		funcName := strings.TrimSpace(funcCall[:funcStart])
-		stmt.Function = funcName
-
-		// 提取参数
-		paramStart := funcStart + 1
-		paramEnd := strings.LastIndex(funcCall, ")")
-		if paramEnd > paramStart {
-			paramStr := strings.TrimSpace(funcCall[paramStart:paramEnd])
-			if paramStr != "" {
-				stmt.Args = p.parseArguments(paramStr, funcName, result)
-			}
-		}
-
-		return stmt
-	}
-
-	// 其他语句
-	return &SimpleStatement{
-		Type:       "other",
-		Content:    line,
-		LineNumber: lineNumber,
-		Status:     StatusPending,
-		RetryCount: 0,
-		Desc:       "",
-	}
-}
-
 // 打印解析结果
 func (r *SimpleParseResult) Print() {
 	fmt.Println("=== 简单解析结果 ===")
@@ -1018,16 +585,34 @@ func (r *SimpleParseResult) printStatements(statements []*SimpleStatement, depth
 				}
 			}
 		}
-		// 处理if语句
-		if stmt.Type == "if" && stmt.Condition != "" {
+		// 处理if语句和分支
+		if (stmt.Type == "if" || stmt.Type == "branch") && stmt.Condition != "" {
 			fmt.Printf("%s   条件: %s\n", indent, stmt.Condition)
 		}
 
+		// 处理for语句
+		if stmt.Type == "for" && stmt.Loop != nil {
+			if stmt.Loop.Kind == LoopKindRange {
+				fmt.Printf("%s   遍历: %s (下标: %s, 元素: %s)\n", indent, stmt.Loop.RangeExpr, stmt.Loop.KeyVar, stmt.Loop.ValueVar)
+			} else {
+				fmt.Printf("%s   循环条件: %s\n", indent, stmt.Condition)
+			}
+			if stmt.Loop.Total > 0 || stmt.Loop.Completed > 0 {
+				fmt.Printf("%s   进度: %d/%d\n", indent, stmt.Loop.Completed, stmt.Loop.Total)
+			}
+		}
+
 		// 递归打印子语句
 		if len(stmt.Children) > 0 {
 			fmt.Printf("%s   子语句:\n", indent)
 			r.printStatements(stmt.Children, depth+2)
 		}
+
+		// 递归打印分支
+		if len(stmt.Branches) > 0 {
+			fmt.Printf("%s   分支:\n", indent)
+			r.printStatements(stmt.Branches, depth+2)
+		}
 	}
 }
 
@@ -1041,8 +626,8 @@ func (p *SimpleParser) parseMetadata(metadataStr string) map[string]interface{}
 		metadataStr = metadataStr[1 : len(metadataStr)-1]
 	}
 
-	// 按逗号分割键值对
-	pairs := strings.Split(metadataStr, ",")
+	// 按逗号分割键值对，列表 [a, b] 内的逗号不分割
+	pairs := p.splitParameters(metadataStr)
 	for _, pair := range pairs {
 		pair = strings.TrimSpace(pair)
 		if pair == "" {
@@ -1066,6 +651,15 @@ func (p *SimpleParser) parseMetadata(metadataStr string) map[string]interface{}
 			parsedValue = false
 		} else if num, err := strconv.Atoi(value); err == nil {
 			parsedValue = num
+		} else if num, err := strconv.ParseFloat(value, 64); err == nil {
+			parsedValue = num
+		} else if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			// 列表，如 retry_on: [timeout, "E1001"]
+			items := make([]string, 0)
+			for _, item := range p.splitParameters(value[1 : len(value)-1]) {
+				items = append(items, strings.Trim(item, "\""))
+			}
+			parsedValue = items
 		} else if strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
 			// 字符串字面量
 			parsedValue = value[1 : len(value)-1]
@@ -1257,68 +851,42 @@ func (p *SimpleParser) parseSingleParameterDefinition(paramStr string) *Paramete
 	}
 }
 
-// 解析函数参数为ArgumentInfo结构体
-func (p *SimpleParser) parseArguments(paramStr string, funcName string, result *SimpleParseResult) []*ArgumentInfo {
-	var args []*ArgumentInfo
-
-	// 从步骤定义中获取输入参数信息
-	var inputParams []ParameterInfo
-	for _, step := range result.Steps {
-		if step.Name == funcName {
-			inputParams = step.InputParams
-			break
-		}
-	}
-
-	// 按逗号分割参数
-	params := strings.Split(paramStr, ",")
-	for i, param := range params {
-		param = strings.TrimSpace(param)
-		if param == "" {
-			continue
-		}
-
-		arg := &ArgumentInfo{
-			Value: param,
-		}
+// newArgument 创建步骤调用的参数，desc 为步骤定义中对应输入参数的描述，literalType/isLiteral 为按字面量解析的结果
+func (p *SimpleParser) newArgument(param, desc, literalType string, isLiteral bool, result *SimpleParseResult) *ArgumentInfo {
+	arg := &ArgumentInfo{
+		Value: param,
+		Desc:  desc,
+	}
+
+	// 判断参数类型
+	if strings.HasPrefix(param, "input[") && strings.HasSuffix(param, "]") {
+		// 输入参数：input["用户名"]
+		arg.IsInput = true
+		arg.IsVariable = true
+		arg.Type = "input"
+		arg.Source = "input"
+	} else if isLiteral {
+		// 字面量：数字、字符串、true/false、[]T{...}、map[string]T{...}
+		arg.IsLiteral = true
+		arg.Type = literalType
+	} else if strings.Contains(param, "\"") || strings.Contains(param, "'") {
+		// 字符串字面量
+		arg.IsLiteral = true
+		arg.Type = "string"
+	} else {
+		// 变量引用
+		arg.IsVariable = true
+		arg.Type = "variable"
+		arg.Source = param
 
-		// 从输入参数定义中获取描述信息
-		if i < len(inputParams) {
-			arg.Desc = inputParams[i].Desc
+		// 从变量映射表中获取类型信息
+		if varInfo, exists := result.Variables[param]; exists {
+			arg.Type = varInfo.Type
+			arg.Source = varInfo.Source
 		}
-
-		// 判断参数类型
-		if strings.HasPrefix(param, "input[") && strings.HasSuffix(param, "]") {
-			// 输入参数：input["用户名"]
-			arg.IsInput = true
-			arg.IsVariable = true
-			arg.Type = "input"
-			arg.Source = "input"
-		} else if strings.Contains(param, "\"") || strings.Contains(param, "'") {
-			// 字符串字面量
-			arg.IsLiteral = true
-			arg.Type = "string"
-		} else if _, err := strconv.Atoi(param); err == nil {
-			// 数字字面量
-			arg.IsLiteral = true
-			arg.Type = "int"
-		} else {
-			// 变量引用
-			arg.IsVariable = true
-			arg.Type = "variable"
-			arg.Source = param
-
-			// 从变量映射表中获取类型信息
-			if varInfo, exists := result.Variables[param]; exists {
-				arg.Type = varInfo.Type
-				arg.Source = varInfo.Source
-			}
-		}
-
-		args = append(args, arg)
 	}
 
-	return args
+	return arg
 }
 
 // 解析返回变量并建立映射
@@ -1409,6 +977,138 @@ func (s *SimpleStatement) ResetRetry() {
 	s.RetryCount = 0
 }
 
+// Clone 深拷贝语句及其嵌套语句和分支，执行状态、计时和循环记录重置为初始值
+func (s *SimpleStatement) Clone() *SimpleStatement {
+	clone := *s
+	clone.Status = StatusPending
+	clone.RetryCount = 0
+	clone.StartTime = nil
+	clone.EndTime = nil
+	clone.Duration = 0
+	clone.Children = cloneStatements(s.Children)
+	clone.Branches = cloneStatements(s.Branches)
+	if s.Metadata != nil {
+		clone.Metadata = make(map[string]interface{}, len(s.Metadata))
+		for key, value := range s.Metadata {
+			clone.Metadata[key] = value
+		}
+	}
+	if s.Loop != nil {
+		loop := *s.Loop
+		loop.Total = 0
+		loop.Completed = 0
+		loop.Iterations = nil
+		clone.Loop = &loop
+	}
+	if s.Wait != nil {
+		clone.Wait = &WaitInfo{Signal: s.Wait.Signal, Timeout: s.Wait.Timeout, Vars: s.Wait.Vars}
+	}
+	return &clone
+}
+
+func cloneStatements(statements []*SimpleStatement) []*SimpleStatement {
+	if statements == nil {
+		return nil
+	}
+	clones := make([]*SimpleStatement, len(statements))
+	for i, stmt := range statements {
+		clones[i] = stmt.Clone()
+	}
+	return clones
+}
+
+// Snapshot 深拷贝工作流的当前状态，包括语句状态、变量、日志和循环记录
+//
+// 快照与原工作流不共享可变数据，执行期间可以被其他goroutine安全地读取和保存。
+func (r *SimpleParseResult) Snapshot() *SimpleParseResult {
+	snapshot := *r
+	snapshot.variablesMu = &sync.RWMutex{}
+	snapshot.InputVars = copyMetadata(r.InputVars)
+	snapshot.GlobalLogs = copyLogs(r.GlobalLogs)
+	snapshot.Variables = r.VariablesSnapshot()
+	snapshot.ChildFlowIDs = append([]string(nil), r.ChildFlowIDs...)
+	if r.Compensations != nil {
+		snapshot.Compensations = make([]*Compensation, len(r.Compensations))
+		for i, compensation := range r.Compensations {
+			compensationCopy := *compensation
+			compensationCopy.Input = copyMetadata(compensation.Input)
+			compensationCopy.Logs = copyLogs(compensation.Logs)
+			snapshot.Compensations[i] = &compensationCopy
+		}
+	}
+
+	if r.Steps != nil {
+		snapshot.Steps = make([]*SimpleStep, len(r.Steps))
+		for i, step := range r.Steps {
+			stepCopy := *step
+			stepCopy.Logs = copyLogs(step.Logs)
+			stepCopy.Metadata = copyMetadata(step.Metadata)
+			snapshot.Steps[i] = &stepCopy
+		}
+	}
+	if r.MainFunc != nil {
+		snapshot.MainFunc = &SimpleMainFunc{Statements: snapshotStatements(r.MainFunc.Statements)}
+	}
+	return &snapshot
+}
+
+// snapshotStatements 深拷贝语句，与 Clone 不同，保留执行状态和循环记录
+func snapshotStatements(statements []*SimpleStatement) []*SimpleStatement {
+	if statements == nil {
+		return nil
+	}
+	snapshots := make([]*SimpleStatement, len(statements))
+	for i, stmt := range statements {
+		snapshot := *stmt
+		snapshot.Children = snapshotStatements(stmt.Children)
+		snapshot.Branches = snapshotStatements(stmt.Branches)
+		snapshot.Metadata = copyMetadata(stmt.Metadata)
+		if stmt.Loop != nil {
+			loop := *stmt.Loop
+			if stmt.Loop.Iterations != nil {
+				loop.Iterations = make([]*LoopIteration, len(stmt.Loop.Iterations))
+				for j, iteration := range stmt.Loop.Iterations {
+					iterationCopy := *iteration
+					iterationCopy.Statements = snapshotStatements(iteration.Statements)
+					iterationCopy.Logs = copyLogs(iteration.Logs)
+					if iteration.Variables != nil {
+						iterationCopy.Variables = make(map[string]VariableInfo, len(iteration.Variables))
+						for name, info := range iteration.Variables {
+							iterationCopy.Variables[name] = info
+						}
+					}
+					loop.Iterations[j] = &iterationCopy
+				}
+			}
+			snapshot.Loop = &loop
+		}
+		if stmt.Wait != nil {
+			wait := *stmt.Wait
+			snapshot.Wait = &wait
+		}
+		snapshots[i] = &snapshot
+	}
+	return snapshots
+}
+
+func copyMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
+
+func copyLogs(logs []*StepLog) []*StepLog {
+	if logs == nil {
+		return nil
+	}
+	return append(make([]*StepLog, 0, len(logs)), logs...)
+}
+
 // 获取步骤名称（用于日志记录）
 func (s *SimpleStatement) GetStepName() string {
 	if s.Type == "function-call" && s.Function != "" {
@@ -1450,6 +1150,7 @@ func (s *SimpleStatement) GetMergedMetadata() map[string]interface{} {
 	merged["debug"] = defaultMeta.Debug
 	merged["log_level"] = defaultMeta.LogLevel
 	merged["ai_model"] = defaultMeta.AIModel
+	merged["max_iterations"] = defaultMeta.MaxIterations
 
 	// 覆盖语句中的元数据
 	for key, value := range s.Metadata {
@@ -1556,3 +1257,14 @@ func (s *SimpleStatement) GetAIModel() string {
 	}
 	return GetDefaultMetadata().AIModel
 }
+
+// 获取条件循环的最大迭代次数
+func (s *SimpleStatement) GetMaxIterations() int {
+	meta := s.GetMergedMetadata()
+	if maxIterations, exists := meta["max_iterations"]; exists {
+		if maxVal, ok := maxIterations.(int); ok && maxVal > 0 {
+			return maxVal
+		}
+	}
+	return GetDefaultMetadata().MaxIterations
+}
@@ -274,8 +274,8 @@ func main() {
 	}
 
 	statements := result.MainFunc.Statements
-	if len(statements) != 6 {
-		t.Errorf("主函数语句数量错误: 期望 6, 实际 %d", len(statements))
+	if len(statements) != 4 {
+		t.Errorf("主函数语句数量错误: 期望 4, 实际 %d", len(statements))
 	}
 
 	// 验证条件判断语句
@@ -301,8 +301,18 @@ func main() {
 		t.Errorf("if分支语句描述错误: 期望 '执行高级解析', 实际 '%s'", ifStmt.Desc)
 	}
 
-	// 验证else分支中的步骤调用（在if语句外面）
-	elseStmt := statements[3] // else分支实际上是独立的语句
+	// 验证else分支中的步骤调用
+	if len(stmt2.Branches) != 1 {
+		t.Fatalf("else分支数量错误: 期望 1, 实际 %d", len(stmt2.Branches))
+	}
+	elseBranch := stmt2.Branches[0]
+	if elseBranch.Type != "branch" || elseBranch.Condition != "" {
+		t.Errorf("else分支不正确: 类型 %s, 条件 %q", elseBranch.Type, elseBranch.Condition)
+	}
+	if len(elseBranch.Children) != 2 {
+		t.Fatalf("else分支子语句数量错误: 期望 2, 实际 %d", len(elseBranch.Children))
+	}
+	elseStmt := elseBranch.Children[0]
 	if elseStmt.Type != "function-call" {
 		t.Errorf("else分支语句类型错误: 期望 function-call, 实际 %s", elseStmt.Type)
 	}
@@ -0,0 +1,196 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrWorkflowNotFound 存储中不存在指定的工作流
+var ErrWorkflowNotFound = errors.New("工作流不存在")
+
+// WorkflowStore 工作流状态存储
+//
+// 设置 Executor.Store 后，执行器在每次状态更新时保存整个工作流，
+// 进程重启后可以通过 Executor.Resume 从存储中恢复执行。
+type WorkflowStore interface {
+	// Save 按 FlowID 保存工作流，已存在时覆盖
+	Save(ctx context.Context, workflow *SimpleParseResult) error
+	// Load 按 FlowID 加载工作流，不存在时返回 ErrWorkflowNotFound
+	Load(ctx context.Context, flowID string) (*SimpleParseResult, error)
+	// List 列出所有工作流
+	List(ctx context.Context) ([]*SimpleParseResult, error)
+}
+
+// MarshalJSON 变量值为error时保存错误信息，避免序列化为 {} 后丢失
+func (v VariableInfo) MarshalJSON() ([]byte, error) {
+	type variableInfo VariableInfo
+	info := variableInfo(v)
+	if err, ok := info.Value.(error); ok {
+		info.Value = err.Error()
+	}
+	return json.Marshal(info)
+}
+
+// encodeWorkflow 序列化工作流，变量在锁保护下读取
+func encodeWorkflow(workflow *SimpleParseResult) ([]byte, error) {
+	mu := workflow.variablesLock()
+	mu.RLock()
+	defer mu.RUnlock()
+	return json.Marshal(workflow)
+}
+
+// decodeWorkflow 反序列化工作流，并将元数据中的数字、列表还原为解析器产生的类型
+func decodeWorkflow(data []byte) (*SimpleParseResult, error) {
+	workflow := &SimpleParseResult{variablesMu: &sync.RWMutex{}}
+	if err := json.Unmarshal(data, workflow); err != nil {
+		return nil, fmt.Errorf("工作流反序列化失败: %w", err)
+	}
+	if workflow.Variables == nil {
+		workflow.Variables = make(map[string]VariableInfo)
+	}
+	if workflow.InputVars == nil {
+		workflow.InputVars = make(map[string]interface{})
+	}
+	for _, step := range workflow.Steps {
+		normalizeMetadata(step.Metadata)
+	}
+	if workflow.MainFunc != nil {
+		normalizeStatements(workflow.MainFunc.Statements)
+	}
+	return workflow, nil
+}
+
+func normalizeStatements(statements []*SimpleStatement) {
+	for _, stmt := range statements {
+		normalizeMetadata(stmt.Metadata)
+		normalizeStatements(stmt.Children)
+		normalizeStatements(stmt.Branches)
+		if stmt.Loop != nil {
+			for _, iteration := range stmt.Loop.Iterations {
+				normalizeStatements(iteration.Statements)
+			}
+		}
+	}
+}
+
+// normalizeMetadata JSON中的数字统一为float64，整数还原为int，字符串列表还原为[]string
+func normalizeMetadata(metadata map[string]interface{}) {
+	for key, value := range metadata {
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < math.MaxInt32 {
+				metadata[key] = int(v)
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			metadata[key] = items
+		}
+	}
+}
+
+// FileStore 基于文件的工作流存储，每个工作流保存为 <dir>/<FlowID>.json
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 创建文件存储，目录不存在时自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建工作流存储目录失败: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(flowID string) (string, error) {
+	if flowID == "" || strings.ContainsAny(flowID, `/\`) || flowID == "." || flowID == ".." {
+		return "", fmt.Errorf("非法的流程ID: %q", flowID)
+	}
+	return filepath.Join(s.dir, flowID+".json"), nil
+}
+
+// Save 先写临时文件再重命名，进程崩溃时不会留下写了一半的文件
+func (s *FileStore) Save(ctx context.Context, workflow *SimpleParseResult) error {
+	path, err := s.path(workflow.FlowID)
+	if err != nil {
+		return err
+	}
+	data, err := encodeWorkflow(workflow)
+	if err != nil {
+		return fmt.Errorf("工作流序列化失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, workflow.FlowID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("保存工作流失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存工作流失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存工作流失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存工作流失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("保存工作流失败: %w", err)
+	}
+	return nil
+}
+
+// Load 加载工作流
+func (s *FileStore) Load(ctx context.Context, flowID string) (*SimpleParseResult, error) {
+	path, err := s.path(flowID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, flowID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("加载工作流失败: %w", err)
+	}
+	return decodeWorkflow(data)
+}
+
+// List 按 FlowID 排序列出所有工作流
+func (s *FileStore) List(ctx context.Context) ([]*SimpleParseResult, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取工作流存储目录失败: %w", err)
+	}
+
+	var flowIDs []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		flowIDs = append(flowIDs, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(flowIDs)
+
+	workflows := make([]*SimpleParseResult, 0, len(flowIDs))
+	for _, flowID := range flowIDs {
+		workflow, err := s.Load(ctx, flowID)
+		if err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, workflow)
+	}
+	return workflows, nil
+}
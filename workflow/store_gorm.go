@@ -0,0 +1,90 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WorkflowRecord 工作流在数据库中的记录，Data 为整个工作流的JSON。
+// Data 不用 text 类型（MySQL 中最大64KB），按长度交给方言选择：MySQL 为 longtext，PostgreSQL 和 SQLite 为 text
+type WorkflowRecord struct {
+	FlowID    string    `json:"flow_id" gorm:"column:flow_id;primaryKey;size:64"`
+	Status    string    `json:"status" gorm:"column:status;size:32;index"`
+	Data      string    `json:"data" gorm:"column:data;size:4294967295"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (WorkflowRecord) TableName() string {
+	return "workflow_records"
+}
+
+// GormStore 基于GORM的工作流存储
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore 创建GORM存储并自动迁移表结构
+func NewGormStore(db *gorm.DB) (*GormStore, error) {
+	if err := db.AutoMigrate(&WorkflowRecord{}); err != nil {
+		return nil, fmt.Errorf("迁移工作流表失败: %w", err)
+	}
+	return &GormStore{db: db}, nil
+}
+
+// Save 按 FlowID 插入或更新记录
+func (s *GormStore) Save(ctx context.Context, workflow *SimpleParseResult) error {
+	data, err := encodeWorkflow(workflow)
+	if err != nil {
+		return fmt.Errorf("工作流序列化失败: %w", err)
+	}
+	record := &WorkflowRecord{
+		FlowID: workflow.FlowID,
+		Status: string(workflow.Status),
+		Data:   string(data),
+	}
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "flow_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "data", "updated_at"}),
+	}).Create(record).Error
+	if err != nil {
+		return fmt.Errorf("保存工作流失败: %w", err)
+	}
+	return nil
+}
+
+// Load 加载工作流
+func (s *GormStore) Load(ctx context.Context, flowID string) (*SimpleParseResult, error) {
+	var record WorkflowRecord
+	err := s.db.WithContext(ctx).Where("flow_id = ?", flowID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, flowID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("加载工作流失败: %w", err)
+	}
+	return decodeWorkflow([]byte(record.Data))
+}
+
+// List 按创建时间列出所有工作流
+func (s *GormStore) List(ctx context.Context) ([]*SimpleParseResult, error) {
+	var records []WorkflowRecord
+	if err := s.db.WithContext(ctx).Order("created_at, flow_id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询工作流失败: %w", err)
+	}
+
+	workflows := make([]*SimpleParseResult, 0, len(records))
+	for _, record := range records {
+		workflow, err := decodeWorkflow([]byte(record.Data))
+		if err != nil {
+			return nil, err
+		}
+		workflows = append(workflows, workflow)
+	}
+	return workflows, nil
+}
@@ -0,0 +1,183 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const storeTestCode = `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = beiluo.test1.hr.query_department(username: string "用户名") -> (department: string "部门", err: error "是否失败");
+step2 = beiluo.test1.hr.create_profile(department: string "部门") -> (profileId: string "档案编号", err: error "是否失败");
+
+func main() {
+    部门, step1Err := step1(input["用户名"]){retry: 2, retry_on: [timeout, "E500"]}
+    档案编号, step2Err := step2(部门)
+    sys.Printf("档案编号: %s", 档案编号)
+}`
+
+// testStoreRoundTrip 保存、加载、列出工作流
+func testStoreRoundTrip(t *testing.T, store WorkflowStore) {
+	ctx := context.Background()
+	result := NewSimpleParser().ParseWorkflow(storeTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	result.FlowID = "flow-1"
+	result.Status = StatusRunning
+	result.MainFunc.Statements[0].Status = StatusCompleted
+	result.SetVariable("部门", VariableInfo{Value: "研发", Type: "string"})
+	result.SetVariable("step1Err", VariableInfo{Value: errors.New("部门不存在"), Type: "error"})
+
+	if err := store.Save(ctx, result); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	// 重复保存覆盖原记录
+	result.Status = StatusFailed
+	if err := store.Save(ctx, result); err != nil {
+		t.Fatalf("再次保存失败: %v", err)
+	}
+
+	loaded, err := store.Load(ctx, "flow-1")
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if loaded.Status != StatusFailed || loaded.MainFunc.Statements[0].Status != StatusCompleted {
+		t.Errorf("状态不正确: %s, %s", loaded.Status, loaded.MainFunc.Statements[0].Status)
+	}
+	if value, _ := loaded.GetVariable("部门"); value.Value != "研发" {
+		t.Errorf("变量不正确: %v", value.Value)
+	}
+	if value, _ := loaded.GetVariable("step1Err"); value.Value != "部门不存在" {
+		t.Errorf("error变量应保存错误信息: %v", value.Value)
+	}
+
+	// 元数据还原为解析器产生的类型，重试策略不受影响
+	metadata := loaded.MainFunc.Statements[0].Metadata
+	if retry, ok := metadata["retry"].(int); !ok || retry != 2 {
+		t.Errorf("retry 应还原为int: %#v", metadata["retry"])
+	}
+	if !reflect.DeepEqual(metadata["retry_on"], []string{"timeout", "E500"}) {
+		t.Errorf("retry_on 应还原为[]string: %#v", metadata["retry_on"])
+	}
+
+	if _, err := store.Load(ctx, "flow-404"); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Errorf("期望 ErrWorkflowNotFound, 实际 %v", err)
+	}
+
+	result.FlowID = "flow-2"
+	if err := store.Save(ctx, result); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	workflows, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("列出失败: %v", err)
+	}
+	if len(workflows) != 2 || workflows[0].FlowID != "flow-1" || workflows[1].FlowID != "flow-2" {
+		t.Errorf("列出结果不正确: %d", len(workflows))
+	}
+}
+
+// TestFileStore 测试文件存储
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	testStoreRoundTrip(t, store)
+
+	if err := store.Save(context.Background(), &SimpleParseResult{FlowID: "../flow"}); err == nil {
+		t.Error("非法的流程ID应保存失败")
+	}
+}
+
+// TestGormStore 测试GORM存储
+func TestGormStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	store, err := NewGormStore(db)
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	testStoreRoundTrip(t, store)
+}
+
+// TestExecutor_Resume 测试进程中断后从存储恢复执行
+func TestExecutor_Resume(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	result := NewSimpleParser().ParseWorkflow(storeTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	// 第一次执行：step1 完成后，step2 执行期间进程被中断
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	executor := NewExecutor()
+	executor.Store = store
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		if step.Name == "step1" {
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"department": "研发", "err": nil}}, nil
+		}
+		cancel()
+		return nil, ctx.Err()
+	}
+	if err := executor.Start(ctx, result); err == nil {
+		t.Fatal("第一次执行应被取消")
+	}
+
+	saved, err := store.Load(context.Background(), result.FlowID)
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if saved.Status != "cancelled" {
+		t.Errorf("工作流状态不正确: %s", saved.Status)
+	}
+
+	// 恢复执行：step1 不再执行，step2 读取保存的变量
+	var calls []string
+	resumed := NewExecutor()
+	resumed.Store = store
+	resumed.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		calls = append(calls, fmt.Sprintf("%s(%v)", step.Name, in.RealInput["department"]))
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"profileId": "P001", "err": nil}}, nil
+	}
+	if err := resumed.Resume(context.Background(), result.FlowID); err != nil {
+		t.Fatalf("恢复执行失败: %v", err)
+	}
+	if len(calls) != 1 || calls[0] != "step2(研发)" {
+		t.Errorf("恢复执行的步骤不正确: %v", calls)
+	}
+
+	final, err := store.Load(context.Background(), result.FlowID)
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if final.Status != StatusCompleted {
+		t.Errorf("工作流状态不正确: %s", final.Status)
+	}
+	if len(final.GlobalLogs) != 1 || final.GlobalLogs[0].Message != "档案编号: P001" {
+		t.Errorf("日志不正确: %+v", final.GlobalLogs)
+	}
+
+	// 已完成的工作流不能再次恢复
+	if err := resumed.Resume(context.Background(), result.FlowID); err == nil {
+		t.Error("已完成的工作流不应再次恢复")
+	}
+}
@@ -0,0 +1,178 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 子工作流步骤的函数名前缀：step1 = workflow.名称.版本(...) -> (...)
+const subWorkflowPrefix = "workflow."
+
+// ErrSubWorkflowNotFound 注册表中不存在指定的子工作流
+var ErrSubWorkflowNotFound = errors.New("子工作流不存在")
+
+// SubWorkflowRef 步骤引用的子工作流
+type SubWorkflowRef struct {
+	Name    string `json:"name"`    // 工作流名称
+	Version string `json:"version"` // 版本，为空时使用最新注册的版本
+}
+
+func (r SubWorkflowRef) String() string {
+	if r.Version == "" {
+		return r.Name
+	}
+	return r.Name + "." + r.Version
+}
+
+// parseSubWorkflowRef 解析 workflow.名称 或 workflow.名称.版本，不是子工作流时返回nil
+func parseSubWorkflowRef(function string) (*SubWorkflowRef, error) {
+	rest, ok := strings.CutPrefix(function, subWorkflowPrefix)
+	if !ok {
+		return nil, nil
+	}
+	parts := strings.Split(rest, ".")
+	if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+		return nil, fmt.Errorf("子工作流引用格式错误，应为 workflow.名称 或 workflow.名称.版本: %s", function)
+	}
+	ref := &SubWorkflowRef{Name: parts[0]}
+	if len(parts) == 2 {
+		ref.Version = parts[1]
+	}
+	return ref, nil
+}
+
+// WorkflowRegistry 子工作流注册表，按名称和版本查找工作流代码
+type WorkflowRegistry interface {
+	// Lookup 返回工作流代码，version 为空时返回最新版本，不存在时返回 ErrSubWorkflowNotFound
+	Lookup(ctx context.Context, name, version string) (string, error)
+}
+
+// MemoryRegistry 内存中的子工作流注册表
+type MemoryRegistry struct {
+	mu     sync.RWMutex
+	flows  map[string]map[string]string // 名称 -> 版本 -> 代码
+	latest map[string]string            // 名称 -> 最新注册的版本
+}
+
+// NewMemoryRegistry 创建内存注册表
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		flows:  make(map[string]map[string]string),
+		latest: make(map[string]string),
+	}
+}
+
+// Register 注册工作流的一个版本，同一版本重复注册时覆盖
+func (r *MemoryRegistry) Register(name, version, code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.flows[name] == nil {
+		r.flows[name] = make(map[string]string)
+	}
+	r.flows[name][version] = code
+	r.latest[name] = version
+}
+
+func (r *MemoryRegistry) Lookup(ctx context.Context, name, version string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if version == "" {
+		version = r.latest[name]
+	}
+	code, exists := r.flows[name][version]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrSubWorkflowNotFound, SubWorkflowRef{Name: name, Version: version})
+	}
+	return code, nil
+}
+
+// 正在执行的子工作流调用链在上下文中的键，用于检测循环调用
+type subWorkflowStackKey struct{}
+
+// subWorkflowCall 返回执行子工作流的步骤回调
+//
+// 子工作流作为独立流程在同一执行器中执行，流程ID为 childID，上下文只继承父流程步骤调用的取消和截止时间，
+// 不携带父流程的运行时状态（如当前循环迭代），父流程取消或超时时子工作流随之停止。步骤参数按参数说明（没有说明时按参数名）传给子工作流的 input，
+// 子工作流结束后按返回值说明读取同名变量作为步骤的输出。
+func (e *Executor) subWorkflowCall(parentID, childID string) OnFunctionCall {
+	return func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		ref := *step.SubWorkflow
+		stack, _ := ctx.Value(subWorkflowStackKey{}).([]string)
+		for _, name := range stack {
+			if name == ref.Name {
+				return nil, fmt.Errorf("子工作流循环调用: %s -> %s", strings.Join(stack, " -> "), ref.Name)
+			}
+		}
+		childCtx, cancel := detachedContext(ctx)
+		defer cancel()
+		childCtx = context.WithValue(childCtx, subWorkflowStackKey{}, append(stack[:len(stack):len(stack)], ref.Name))
+
+		if e.Registry == nil {
+			return nil, fmt.Errorf("执行器未设置子工作流注册表")
+		}
+		code, err := e.Registry.Lookup(childCtx, ref.Name, ref.Version)
+		if err != nil {
+			return nil, err
+		}
+		child := NewSimpleParser().ParseWorkflow(code)
+		if !child.Success {
+			return nil, fmt.Errorf("子工作流 %s 解析失败: %s", ref, child.Error)
+		}
+		child.FlowID = childID
+		child.ParentFlowID = parentID
+		for _, param := range step.InputParams {
+			if value, exists := in.RealInput[param.Name]; exists {
+				child.InputVars[subWorkflowKey(param)] = value
+			}
+		}
+
+		if err := e.Start(childCtx, child); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return &ExecutorOut{
+				Success: false,
+				Error:   fmt.Sprintf("子工作流 %s(%s) 执行失败: %v", ref, childID, err),
+			}, nil
+		}
+
+		output := make(map[string]interface{})
+		for _, param := range step.OutputParams {
+			if param.Type == "error" {
+				output[param.Name] = nil
+				continue
+			}
+			if variable, exists := child.GetVariable(subWorkflowKey(param)); exists {
+				output[param.Name] = variable.Value
+			}
+		}
+		return &ExecutorOut{Success: true, WantOutput: output}, nil
+	}
+}
+
+// detachedContext 返回只继承 parent 取消（包括取消原因）和截止时间的上下文，不携带 parent 中的值
+func detachedContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	cancel := func() { cancelCause(context.Canceled) }
+	if deadline, ok := parent.Deadline(); ok {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+		cancel = func() { cancelDeadline(); cancelCause(context.Canceled) }
+	}
+	stop := context.AfterFunc(parent, func() { cancelCause(context.Cause(parent)) })
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// subWorkflowKey 参数对应的子工作流 input 键或变量名
+func subWorkflowKey(param ParameterInfo) string {
+	if param.Desc != "" {
+		return param.Desc
+	}
+	return param.Name
+}
@@ -0,0 +1,207 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const subWorkflowChildCode = `var input = map[string]interface{}{
+    "姓名": "",
+}
+
+step1 = beiluo.test1.hr.create_employee(name: string "姓名") -> (workId: string "工号", err: error "是否失败");
+
+func main() {
+    工号, step1Err := step1(input["姓名"])
+    if step1Err != nil {
+        return
+    }
+    sys.Printf("入职完成: %s", 工号)
+}`
+
+const subWorkflowParentCode = `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = workflow.onboarding.v1(name: string "姓名") -> (workId: string "工号", err: error "是否失败");
+
+func main() {
+    工号, step1Err := step1(input["用户名"])
+    if step1Err != nil {
+        return
+    }
+    sys.Printf("父流程: %s", 工号)
+}`
+
+// TestExecutor_SubWorkflow 测试子工作流的输入输出映射和父子流程关联
+func TestExecutor_SubWorkflow(t *testing.T) {
+	registry := NewMemoryRegistry()
+	registry.Register("onboarding", "v1", subWorkflowChildCode)
+
+	executor := NewExecutor()
+	executor.Registry = registry
+	// 已结束的子流程从 Store 获取
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	executor.Store = store
+	var calls []string
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		calls = append(calls, step.Function)
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"workId": "EMP-" + in.RealInput["name"].(string), "err": nil}}, nil
+	}
+
+	parent := NewSimpleParser().ParseWorkflow(subWorkflowParentCode)
+	if !parent.Success {
+		t.Fatalf("解析失败: %s", parent.Error)
+	}
+	if ref := parent.Steps[0].SubWorkflow; ref == nil || ref.Name != "onboarding" || ref.Version != "v1" {
+		t.Fatalf("子工作流引用不正确: %+v", ref)
+	}
+	if err := executor.Start(context.Background(), parent); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	if len(calls) != 1 || calls[0] != "beiluo.test1.hr.create_employee" {
+		t.Errorf("业务回调不正确: %v", calls)
+	}
+	if variable, _ := parent.GetVariable("工号"); variable.Value != "EMP-张三" {
+		t.Errorf("子工作流输出未映射到父流程: %v", variable.Value)
+	}
+	if len(parent.GlobalLogs) != 1 || parent.GlobalLogs[0].Message != "父流程: EMP-张三" {
+		t.Errorf("父流程日志不正确: %+v", parent.GlobalLogs)
+	}
+
+	if len(parent.ChildFlowIDs) != 1 {
+		t.Fatalf("父流程应记录子流程ID: %v", parent.ChildFlowIDs)
+	}
+	child, err := executor.Get(parent.ChildFlowIDs[0])
+	if err != nil {
+		t.Fatalf("获取子流程失败: %v", err)
+	}
+	if child.ParentFlowID != parent.FlowID || child.Status != StatusCompleted || child.InputVars["姓名"] != "张三" {
+		t.Errorf("子流程状态不正确: parent=%s status=%s input=%v", child.ParentFlowID, child.Status, child.InputVars)
+	}
+	if len(child.GlobalLogs) != 1 || child.GlobalLogs[0].Message != "入职完成: EMP-张三" {
+		t.Errorf("子流程日志不正确: %+v", child.GlobalLogs)
+	}
+}
+
+// TestExecutor_SubWorkflowInLoop 测试循环中调用子工作流时，子流程的日志不记录到父流程的迭代上
+func TestExecutor_SubWorkflowInLoop(t *testing.T) {
+	registry := NewMemoryRegistry()
+	registry.Register("onboarding", "v1", subWorkflowChildCode)
+
+	executor := NewExecutor()
+	executor.Registry = registry
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"workId": "EMP-" + in.RealInput["name"].(string), "err": nil}}, nil
+	}
+
+	parent := NewSimpleParser().ParseWorkflow(`var input = map[string]interface{}{
+    "名单": []string{"张三", "李四"},
+}
+
+step1 = workflow.onboarding.v1(name: string "姓名") -> (workId: string "工号", err: error "是否失败");
+
+func main() {
+    for _, 姓名 := range input["名单"] {
+        工号, step1Err := step1(姓名)
+        sys.Printf("父流程: %s", 工号)
+    }
+}`)
+	if !parent.Success {
+		t.Fatalf("解析失败: %s", parent.Error)
+	}
+	if err := executor.Start(context.Background(), parent); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+
+	loop := parent.MainFunc.Statements[0].Loop
+	if len(loop.Iterations) != 2 {
+		t.Fatalf("迭代次数不正确: %d", len(loop.Iterations))
+	}
+	for i, iteration := range loop.Iterations {
+		if len(iteration.Logs) != 1 || !strings.HasPrefix(iteration.Logs[0].Message, "父流程: EMP-") {
+			t.Errorf("第%d次迭代的日志不应包含子流程的日志: %+v", i, iteration.Logs)
+		}
+	}
+}
+
+// TestExecutor_SubWorkflowCancel 测试停止父流程时子流程随之停止
+func TestExecutor_SubWorkflowCancel(t *testing.T) {
+	registry := NewMemoryRegistry()
+	registry.Register("onboarding", "v1", subWorkflowChildCode)
+
+	started := make(chan struct{})
+	executor := NewExecutor()
+	executor.Registry = registry
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	executor.Store = store
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	parent := NewSimpleParser().ParseWorkflow(subWorkflowParentCode)
+	done := make(chan error, 1)
+	go func() { done <- executor.Start(context.Background(), parent) }()
+	<-started
+
+	if running := executor.Running(); len(running) != 2 {
+		t.Fatalf("父子流程都应在运行: %v", running)
+	}
+	if err := executor.Stop(context.Background(), parent.FlowID); err != nil {
+		t.Fatalf("停止失败: %v", err)
+	}
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "被取消") {
+		t.Errorf("父流程应被取消: %v", err)
+	}
+
+	// 子流程在父流程返回后很快结束
+	deadline := time.Now().Add(time.Second)
+	for len(executor.Running()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if running := executor.Running(); len(running) != 0 {
+		t.Fatalf("子流程应已停止: %v", running)
+	}
+	child, err := executor.Get(parent.ChildFlowIDs[0])
+	if err != nil || child.Status != "cancelled" {
+		t.Errorf("子流程状态不正确: %v, %v", child, err)
+	}
+}
+
+// TestExecutor_SubWorkflowErrors 测试子工作流不存在和循环调用
+func TestExecutor_SubWorkflowErrors(t *testing.T) {
+	registry := NewMemoryRegistry()
+	registry.Register("onboarding", "v1", strings.Replace(subWorkflowParentCode, "onboarding.v1", "onboarding", 1))
+
+	executor := NewExecutor()
+	executor.Registry = registry
+
+	recursive := NewSimpleParser().ParseWorkflow(subWorkflowParentCode)
+	err := executor.Start(context.Background(), recursive)
+	if err == nil || !strings.Contains(err.Error(), "子工作流循环调用: onboarding -> onboarding") {
+		t.Errorf("应检测到循环调用: %v", err)
+	}
+
+	missing := NewSimpleParser().ParseWorkflow(strings.Replace(subWorkflowParentCode, "onboarding.v1", "offboarding", 1))
+	err = executor.Start(context.Background(), missing)
+	if !errors.Is(err, ErrSubWorkflowNotFound) {
+		t.Errorf("应返回子工作流不存在: %v", err)
+	}
+
+	invalid := NewSimpleParser().ParseWorkflow(strings.Replace(subWorkflowParentCode, "onboarding.v1", "a.b.c", 1))
+	if invalid.Success || !strings.Contains(invalid.Error, "子工作流引用格式错误") {
+		t.Errorf("应报告引用格式错误: %s", invalid.Error)
+	}
+}
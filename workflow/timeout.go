@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StatusTimeout 步骤或工作流执行超时
+const StatusTimeout StatementStatus = "timeout"
+
+// StepTimeoutError 步骤单次执行超过 timeout 元数据设置的时间
+type StepTimeoutError struct {
+	Step    string        // 步骤名
+	Line    int           // 语句行号
+	Timeout time.Duration // 超时时间
+	Attempt int           // 第几次尝试，从1开始
+}
+
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("步骤 %s 执行超时(第%d行，第%d次尝试): 超过 %v", e.Step, e.Line, e.Attempt, e.Timeout)
+}
+
+// Unwrap 使 errors.Is(err, context.DeadlineExceeded) 成立
+func (e *StepTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WorkflowTimeoutError 工作流整体执行超过 //workflow_timeout 设置的时间
+type WorkflowTimeoutError struct {
+	FlowID  string        // 流程ID
+	Timeout time.Duration // 超时时间
+}
+
+func (e *WorkflowTimeoutError) Error() string {
+	return fmt.Sprintf("工作流 %s 执行超时: 超过 %v", e.FlowID, e.Timeout)
+}
+
+// Unwrap 使 errors.Is(err, context.DeadlineExceeded) 成立
+func (e *WorkflowTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// isTimeout 判断是否为步骤超时或工作流超时，此时语句状态已设置为 timeout
+func isTimeout(err error) bool {
+	var stepErr *StepTimeoutError
+	var flowErr *WorkflowTimeoutError
+	return errors.As(err, &stepErr) || errors.As(err, &flowErr)
+}
+
+// interruptedError 工作流上下文结束时设置语句状态并返回对应错误：整体超时为 timeout，其他为 cancelled
+func interruptedError(ctx context.Context, stmt *SimpleStatement, action string) error {
+	var timeoutErr *WorkflowTimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		stmt.Status = StatusTimeout
+		return timeoutErr
+	}
+	stmt.Status = "cancelled"
+	return fmt.Errorf("%s被取消: %v", action, ctx.Err())
+}
+
+// callStep 调用业务回调（或子工作流）call，timeout 非nil时单次调用超时返回 *StepTimeoutError
+//
+// 回调在单独的goroutine中执行，即使业务回调忽略ctx一直不返回，执行器也会在超时或取消时结束等待。
+// 结束等待前取消传给回调的ctx（超时时 context.Cause 为 *StepTimeoutError），
+// 回调应据此停止执行并返回，否则goroutine会一直运行到回调返回，返回值被丢弃。
+func (e *Executor) callStep(ctx context.Context, call OnFunctionCall, stmt *SimpleStatement, step SimpleStep, in *ExecutorIn, timeout *time.Duration, attempt int) (*ExecutorOut, error) {
+	var callCtx context.Context
+	var cancel context.CancelFunc
+	if timeout != nil {
+		callCtx, cancel = context.WithTimeoutCause(ctx, *timeout, &StepTimeoutError{
+			Step:    step.Name,
+			Line:    stmt.LineNumber,
+			Timeout: *timeout,
+			Attempt: attempt + 1,
+		})
+	} else {
+		callCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	type callResult struct {
+		out *ExecutorOut
+		err error
+	}
+	done := make(chan callResult, 1)
+	go func() {
+		out, err := call(callCtx, step, in)
+		done <- callResult{out: out, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		// 回调因本次调用超时返回错误时，统一为超时错误
+		if res.err != nil && ctx.Err() == nil && callCtx.Err() != nil {
+			return nil, context.Cause(callCtx)
+		}
+		return res.out, res.err
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, context.Cause(callCtx)
+	}
+}
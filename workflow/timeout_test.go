@@ -0,0 +1,151 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const timeoutTestCode = `step1 = beiluo.test1.devops.devops_script_create(username: string "用户名") -> (workId: string "工号", err: error "是否失败");
+
+func main() {
+    工号, step1Err := step1("张三"){timeout: 50, retry_count: %d, retry_initial: 10}
+    sys.Printf("工号: %%s", 工号)
+}`
+
+// TestExecutor_StepTimeout 测试业务回调忽略ctx时执行器按timeout结束步骤
+func TestExecutor_StepTimeout(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(fmt.Sprintf(timeoutTestCode, 0))
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	hang := make(chan struct{})
+	defer close(hang)
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		// 模拟不处理ctx的业务回调
+		<-hang
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"workId": "EMP001", "err": nil}}, nil
+	}
+
+	err := executor.Start(context.Background(), result)
+	var timeoutErr *StepTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("期望 *StepTimeoutError, 实际 %v", err)
+	}
+	if timeoutErr.Step != "step1" || timeoutErr.Timeout != 50*time.Millisecond || timeoutErr.Line != 4 {
+		t.Errorf("超时错误信息不正确: %+v", timeoutErr)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("超时错误应匹配 context.DeadlineExceeded")
+	}
+	if status := result.MainFunc.Statements[0].Status; status != StatusTimeout {
+		t.Errorf("步骤状态不正确: 期望 timeout, 实际 %s", status)
+	}
+	if len(result.GlobalLogs) != 0 {
+		t.Errorf("超时后不应继续执行: %+v", result.GlobalLogs)
+	}
+}
+
+// TestExecutor_StepTimeoutRetry 测试超时后按 retry_count 重试
+func TestExecutor_StepTimeoutRetry(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(fmt.Sprintf(timeoutTestCode, 1))
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	var attempts int32
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		// 第一次尝试等待到超时，第二次立即返回
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"workId": "EMP001", "err": nil}}, nil
+	}
+
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("尝试次数不正确: %d", attempts)
+	}
+	if status := result.MainFunc.Statements[0].Status; status != StatusCompleted {
+		t.Errorf("步骤状态不正确: %s", status)
+	}
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "工号: EMP001" {
+		t.Errorf("日志不正确: %+v", result.GlobalLogs)
+	}
+}
+
+// TestExecutor_WorkflowTimeout 测试工作流整体超时与调用方取消的区别
+func TestExecutor_WorkflowTimeout(t *testing.T) {
+	code := `//workflow_timeout: 100
+
+step1 = beiluo.test1.devops.devops_script_create(username: string "用户名") -> (workId: string "工号", err: error "是否失败");
+
+func main() {
+    工号, step1Err := step1("张三")
+    sys.Printf("工号: %s", 工号)
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if result.Timeout != 100*time.Millisecond {
+		t.Fatalf("工作流超时时间不正确: %v", result.Timeout)
+	}
+
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	err := executor.Start(context.Background(), result)
+	var timeoutErr *WorkflowTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("期望 *WorkflowTimeoutError, 实际 %v", err)
+	}
+	if timeoutErr.FlowID != result.FlowID {
+		t.Errorf("流程ID不正确: %s", timeoutErr.FlowID)
+	}
+	if status := result.MainFunc.Statements[0].Status; status != StatusTimeout {
+		t.Errorf("步骤状态不正确: 期望 timeout, 实际 %s", status)
+	}
+}
+
+// TestExecutor_StepTimeoutCancelsCallback 测试超时后取消传给业务回调的ctx，处理ctx的回调随之结束
+func TestExecutor_StepTimeoutCancelsCallback(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(fmt.Sprintf(timeoutTestCode, 0))
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	cause := make(chan error, 1)
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return nil, ctx.Err()
+	}
+
+	if err := executor.Start(context.Background(), result); !errors.As(err, new(*StepTimeoutError)) {
+		t.Fatalf("期望 *StepTimeoutError, 实际 %v", err)
+	}
+	select {
+	case err := <-cause:
+		if !errors.As(err, new(*StepTimeoutError)) {
+			t.Errorf("回调ctx的取消原因应为步骤超时: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("超时后回调的ctx未被取消")
+	}
+}
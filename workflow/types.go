@@ -0,0 +1,407 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// TypeMismatchError 步骤的参数或返回值无法转换为声明的类型
+type TypeMismatchError struct {
+	Step   string      // 步骤名
+	Line   int         // 语句行号
+	Param  string      // 参数名或返回值名
+	Output bool        // 是否为返回值
+	Type   string      // 声明的类型
+	Value  interface{} // 实际的值
+}
+
+func (e *TypeMismatchError) Error() string {
+	kind := "参数"
+	if e.Output {
+		kind = "返回值"
+	}
+	return fmt.Sprintf("第%d行: 步骤 %s 的%s %s 类型应为 %s，实际为 %s: %v", e.Line, e.Step, kind, e.Param, e.Type, typeName(e.Value), e.Value)
+}
+
+// ErrorCode 使 retry_on 可以按 type_mismatch 匹配
+func (e *TypeMismatchError) ErrorCode() string {
+	return "type_mismatch"
+}
+
+// 基本类型名对应的Go类型，float、number 按 float64 处理
+var basicTypes = map[string]reflect.Type{
+	"string":  reflect.TypeOf(""),
+	"bool":    reflect.TypeOf(false),
+	"int":     reflect.TypeOf(int(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"uint":    reflect.TypeOf(uint(0)),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"float":   reflect.TypeOf(float64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+	"number":  reflect.TypeOf(float64(0)),
+}
+
+// goType 返回类型名对应的Go类型，如 []string、map[string]int，无法确定时返回 nil
+func goType(t string) reflect.Type {
+	t = strings.TrimSpace(t)
+	switch {
+	case t == "interface{}" || t == "any":
+		return reflect.TypeOf((*interface{})(nil)).Elem()
+	case strings.HasPrefix(t, "[]"):
+		if elem := goType(t[2:]); elem != nil {
+			return reflect.SliceOf(elem)
+		}
+	case strings.HasPrefix(t, "map[string]"):
+		if elem := goType(t[len("map[string]"):]); elem != nil {
+			return reflect.MapOf(reflect.TypeOf(""), elem)
+		}
+	}
+	return basicTypes[t]
+}
+
+// coerceValue 把值转换为参数声明的类型，用于构建 ExecutorIn.RealInput
+//
+// 除 conformValue 的转换外，字符串可以解析为数字、布尔值和JSON列表/对象，数字和布尔值可以转为字符串。
+func coerceValue(value interface{}, t string) (interface{}, bool) {
+	return convertValue(value, t, true)
+}
+
+// conformValue 检查值是否符合返回值声明的类型，只做不改变含义的转换，
+// 如JSON解码得到的 float64 整数转为 int、[]interface{} 转为 []string
+func conformValue(value interface{}, t string) (interface{}, bool) {
+	return convertValue(value, t, false)
+}
+
+// convertValue 按类型名转换值，parse 为 true 时允许字符串与数字、布尔值等之间的转换；
+// 无法识别的类型（结构体名、interface{} 等）原样返回
+func convertValue(value interface{}, t string, parse bool) (interface{}, bool) {
+	t = strings.TrimSpace(t)
+	switch {
+	case t == "error":
+		switch value.(type) {
+		case nil, error, string:
+			return value, true
+		}
+		return nil, false
+	case strings.HasPrefix(t, "[]"):
+		return convertSlice(value, t, parse)
+	case strings.HasPrefix(t, "map[string]"):
+		return convertMap(value, t, parse)
+	}
+	target, exists := basicTypes[t]
+	if !exists {
+		return value, true
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case json.Number:
+			return v.String(), parse
+		}
+		_, isNumber := toNumber(value)
+		_, isBool := value.(bool)
+		if parse && (isNumber || isBool) {
+			return fmt.Sprint(value), true
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			return b, parse && err == nil
+		}
+	default:
+		if s, ok := value.(string); ok && parse {
+			value = json.Number(strings.TrimSpace(s))
+		}
+		num, ok := toNumber(value)
+		if !ok {
+			return nil, false
+		}
+		out := reflect.New(target).Elem()
+		switch target.Kind() {
+		case reflect.Float32, reflect.Float64:
+			out.SetFloat(num.f)
+			return out.Interface(), true
+		}
+		if !num.isInt {
+			// 1.0 这样的浮点数（JSON解码的整数）可以转为整数，1.5 不行
+			if num.f != float64(int64(num.f)) {
+				return nil, false
+			}
+			num.i = int64(num.f)
+		}
+		switch target.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if num.i < 0 || out.OverflowUint(uint64(num.i)) {
+				return nil, false
+			}
+			out.SetUint(uint64(num.i))
+		default:
+			if out.OverflowInt(num.i) {
+				return nil, false
+			}
+			out.SetInt(num.i)
+		}
+		return out.Interface(), true
+	}
+	return nil, false
+}
+
+// convertSlice 转换列表的每个元素，元素类型为基本类型时返回对应类型的切片，如 []string
+func convertSlice(value interface{}, t string, parse bool) (interface{}, bool) {
+	if value == nil {
+		return value, true
+	}
+	if s, ok := value.(string); ok && parse {
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, false
+		}
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elem := t[2:]
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		item, ok := convertValue(rv.Index(i).Interface(), elem, parse)
+		if !ok {
+			return nil, false
+		}
+		items[i] = item
+	}
+
+	sliceType := goType(t)
+	if sliceType == nil {
+		return items, true
+	}
+	out := reflect.MakeSlice(sliceType, len(items), len(items))
+	for i, item := range items {
+		if item != nil {
+			out.Index(i).Set(reflect.ValueOf(item))
+		}
+	}
+	return out.Interface(), true
+}
+
+// convertMap 转换键为字符串的 map 的每个值，值类型为基本类型时返回对应类型的 map，如 map[string]int
+func convertMap(value interface{}, t string, parse bool) (interface{}, bool) {
+	if value == nil {
+		return value, true
+	}
+	if s, ok := value.(string); ok && parse {
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, false
+		}
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	elem := t[len("map[string]"):]
+	items := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		item, ok := convertValue(iter.Value().Interface(), elem, parse)
+		if !ok {
+			return nil, false
+		}
+		items[iter.Key().String()] = item
+	}
+
+	mapType := goType(t)
+	if mapType == nil {
+		return items, true
+	}
+	out := reflect.MakeMapWithSize(mapType, len(items))
+	for key, item := range items {
+		v := reflect.Zero(mapType.Elem())
+		if item != nil {
+			v = reflect.ValueOf(item)
+		}
+		out.SetMapIndex(reflect.ValueOf(key), v)
+	}
+	return out.Interface(), true
+}
+
+// valueType 返回运行时值的类型名，nil 返回空
+func valueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "int"
+		}
+		return "float64"
+	}
+	return typeName(value)
+}
+
+// parseLiteral 解析字面量，返回值和推断的类型：整数为 int，浮点数为 float64，字符串为 string，
+// true/false 为 bool，[]T{...} 和 map[string]T{...} 为声明的类型；不是字面量时 ok 为 false
+func parseLiteral(text string) (value interface{}, t string, ok bool) {
+	expr, err := parser.ParseExpr(strings.TrimSpace(text))
+	if err != nil {
+		return nil, "", false
+	}
+	return literalValue(expr, "")
+}
+
+// literalValue 计算字面量表达式的值，t 为外层复合字面量省略的元素类型
+func literalValue(expr ast.Expr, t string) (interface{}, string, bool) {
+	switch n := expr.(type) {
+	case *ast.ParenExpr:
+		return literalValue(n.X, t)
+	case *ast.BasicLit:
+		switch n.Kind {
+		case token.INT:
+			i, err := strconv.ParseInt(n.Value, 0, 64)
+			return int(i), "int", err == nil
+		case token.FLOAT:
+			f, err := strconv.ParseFloat(n.Value, 64)
+			return f, "float64", err == nil
+		case token.STRING:
+			s, err := strconv.Unquote(n.Value)
+			return s, "string", err == nil
+		}
+	case *ast.Ident:
+		if n.Name == "true" || n.Name == "false" {
+			return n.Name == "true", "bool", true
+		}
+	case *ast.UnaryExpr:
+		value, valueType, ok := literalValue(n.X, t)
+		if !ok || (n.Op != token.SUB && n.Op != token.ADD) {
+			return nil, "", false
+		}
+		if n.Op == token.ADD {
+			switch value.(type) {
+			case int, float64:
+				return value, valueType, true
+			}
+			return nil, "", false
+		}
+		switch v := value.(type) {
+		case int:
+			return -v, valueType, true
+		case float64:
+			return -v, valueType, true
+		}
+	case *ast.CompositeLit:
+		if n.Type != nil {
+			t = types.ExprString(n.Type)
+		}
+		return compositeValue(n, t)
+	}
+	return nil, "", false
+}
+
+// compositeValue 计算 []T{...} 或 map[string]T{...} 的值并转换为对应类型
+func compositeValue(n *ast.CompositeLit, t string) (interface{}, string, bool) {
+	switch {
+	case strings.HasPrefix(t, "[]"):
+		items := make([]interface{}, len(n.Elts))
+		for i, elt := range n.Elts {
+			item, _, ok := literalValue(elt, t[2:])
+			if !ok {
+				return nil, "", false
+			}
+			items[i] = item
+		}
+		value, ok := conformValue(items, t)
+		return value, t, ok
+	case strings.HasPrefix(t, "map[string]"):
+		items := make(map[string]interface{}, len(n.Elts))
+		for _, elt := range n.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, "", false
+			}
+			key, _, _ := literalValue(kv.Key, "")
+			item, _, itemOK := literalValue(kv.Value, t[len("map[string]"):])
+			name, isString := key.(string)
+			if !isString || !itemOK {
+				return nil, "", false
+			}
+			items[name] = item
+		}
+		value, ok := conformValue(items, t)
+		return value, t, ok
+	}
+	return nil, "", false
+}
+
+// stepInput 按参数声明的类型构建步骤的实际输入参数
+//
+// 参数可以是 input["键"]、变量或字面量；值无法转换为声明的类型时返回 *TypeMismatchError。
+// bound 为预先取得的变量值，优先于工作流中变量的当前值，见 startAsync。
+func stepInput(stmt *SimpleStatement, step *SimpleStep, workflow *SimpleParseResult, bound map[string]interface{}) (map[string]interface{}, error) {
+	realInput := make(map[string]interface{})
+	for i, arg := range stmt.Args {
+		if i >= len(step.InputParams) {
+			break
+		}
+		paramDef := step.InputParams[i]
+
+		var value interface{} = arg.Value
+		if arg.IsInput {
+			// 输入参数：input["用户名"] -> 从InputVars中获取
+			key := strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(arg.Value, "input["), "]")), `"`)
+			if input, exists := workflow.InputVars[key]; exists {
+				value = input
+			}
+		} else if captured, exists := bound[arg.Value]; exists {
+			value = captured
+		} else if variable, exists := workflow.GetVariable(arg.Value); exists {
+			value = variable.Value
+		} else if literal, _, ok := parseLiteral(arg.Value); ok {
+			value = literal
+		}
+
+		converted, ok := coerceValue(value, paramDef.Type)
+		if !ok {
+			return nil, &TypeMismatchError{Step: step.Name, Line: stmt.LineNumber, Param: paramDef.Name, Type: paramDef.Type, Value: value}
+		}
+		realInput[paramDef.Name] = converted
+	}
+	return realInput, nil
+}
+
+// stepOutput 检查步骤返回值是否符合步骤定义的返回值类型，返回转换后的返回值
+func stepOutput(stmt *SimpleStatement, step *SimpleStep, output map[string]interface{}) (map[string]interface{}, error) {
+	outputs := make(map[string]interface{}, len(output))
+	for name, value := range output {
+		outputs[name] = value
+	}
+	for _, paramDef := range step.OutputParams {
+		value, exists := output[paramDef.Name]
+		if !exists {
+			continue
+		}
+		converted, ok := conformValue(value, paramDef.Type)
+		if !ok {
+			return nil, &TypeMismatchError{Step: step.Name, Line: stmt.LineNumber, Param: paramDef.Name, Output: true, Type: paramDef.Type, Value: value}
+		}
+		outputs[paramDef.Name] = converted
+	}
+	return outputs, nil
+}
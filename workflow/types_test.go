@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const typesTestCode = `var input = map[string]interface{}{
+    "手机号": "13800138000",
+}
+
+step1 = beiluo.test1.crm.create_user(phone: int "手机号", age: int "年龄", score: float64 "评分", tags: []string "标签", vip: bool "会员") -> (userId: int "用户编号", profile: map[string]interface{} "档案", err: error "是否失败");
+
+func main() {
+    年龄 := "18"
+    标签 := []string{"新用户", "广告"}
+    会员 := true
+    用户编号, 档案, err := step1(input["手机号"], 年龄, 4, 标签, 会员)
+    sys.Printf("用户编号: %d", 用户编号)
+}`
+
+// TestParseLiteral 测试字面量的值和类型推断
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		text  string
+		value interface{}
+		t     string
+	}{
+		{`18`, 18, "int"},
+		{`-3.5`, -3.5, "float64"},
+		{`+2`, 2, "int"},
+		{`"张三"`, "张三", "string"},
+		{"`多行`", "多行", "string"},
+		{`true`, true, "bool"},
+		{`[]string{"a", "b"}`, []string{"a", "b"}, "[]string"},
+		{`[]float64{1, 2.5}`, []float64{1, 2.5}, "[]float64"},
+		{`map[string]interface{}{"年龄": 18, "标签": []string{"a"}}`, map[string]interface{}{"年龄": 18, "标签": []string{"a"}}, "map[string]interface{}"},
+		{`[]map[string]int{{"a": 1}}`, []map[string]int{{"a": 1}}, "[]map[string]int"},
+	}
+	for _, tt := range tests {
+		value, literalType, ok := parseLiteral(tt.text)
+		if !ok || literalType != tt.t || !reflect.DeepEqual(value, tt.value) {
+			t.Errorf("%s: 得到 %#v %s %v，期望 %#v %s", tt.text, value, literalType, ok, tt.value, tt.t)
+		}
+	}
+	for _, text := range []string{`部门`, `a + 1`, `[]string{部门}`, `map[int]string{1: "a"}`} {
+		if _, _, ok := parseLiteral(text); ok {
+			t.Errorf("%s 不是字面量", text)
+		}
+	}
+}
+
+// TestExecutor_TypedVariables 测试变量类型推断和参数按声明的类型转换
+func TestExecutor_TypedVariables(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(typesTestCode)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	for name, want := range map[string]string{"年龄": "string", "标签": "[]string", "会员": "bool", "用户编号": "int"} {
+		if got := result.Variables[name].Type; got != want {
+			t.Errorf("变量 %s 的类型应为 %s，实际为 %s", name, want, got)
+		}
+	}
+	if args := result.MainFunc.Statements[3].Args; args[2].Type != "int" || !args[2].IsLiteral {
+		t.Errorf("字面量参数类型不正确: %+v", args[2])
+	}
+
+	h := NewHarness()
+	// 返回值为JSON解码的结果
+	h.On("step1", MockResult{Output: map[string]interface{}{"userId": 1001.0, "profile": map[string]interface{}{"等级": "A"}}})
+	if _, err := h.Run(context.Background(), typesTestCode); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	want := map[string]interface{}{"phone": 13800138000, "age": 18, "score": 4.0, "tags": []string{"新用户", "广告"}, "vip": true}
+	if input := h.Calls()[0].Input; !reflect.DeepEqual(input, want) {
+		t.Errorf("输入参数应按声明的类型转换: %#v", input)
+	}
+	h.AssertVariable(t, "标签", []string{"新用户", "广告"})
+	h.AssertVariable(t, "用户编号", 1001)
+}
+
+// TestExecutor_TypeMismatch 测试参数或返回值无法转换为声明的类型时步骤失败
+func TestExecutor_TypeMismatch(t *testing.T) {
+	h := NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"userId": "U1001"}})
+	_, err := h.Run(context.Background(), typesTestCode)
+	var mismatch *TypeMismatchError
+	if !errors.As(err, &mismatch) || !mismatch.Output || mismatch.Param != "userId" || mismatch.Line != 11 {
+		t.Fatalf("应返回返回值类型错误: %v", err)
+	}
+	h.AssertStatus(t, 11, StatusFailed)
+
+	h = NewHarness()
+	h.On("step1", MockResult{Output: map[string]interface{}{"userId": 1001}})
+	_, err = h.Run(context.Background(), strings.Replace(typesTestCode, `年龄 := "18"`, `年龄 := "十八"`, 1))
+	if !errors.As(err, &mismatch) || mismatch.Output || mismatch.Param != "age" || mismatch.Value != "十八" {
+		t.Fatalf("应返回参数类型错误: %v", err)
+	}
+	if len(h.Calls()) != 0 {
+		t.Errorf("参数类型错误时不应调用步骤: %v", h.Path())
+	}
+}
@@ -0,0 +1,417 @@
+package workflow
+
+import (
+	"fmt"
+	"go/ast"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 诊断级别
+const (
+	SeverityError   = "error"   // 执行时必然失败或结果不符合预期
+	SeverityWarning = "warning" // 可以执行，但很可能是写错了
+)
+
+// 诊断类型
+const (
+	DiagSyntax            = "syntax"             // 条件或表达式语法错误
+	DiagUndefinedStep     = "undefined-step"     // 调用了未定义的步骤
+	DiagUndefinedVariable = "undefined-variable" // 使用了未赋值的变量或不存在的输入参数
+	DiagArity             = "arity"              // 参数或返回值数量与步骤定义不一致
+	DiagTypeMismatch      = "type-mismatch"      // 参数类型与步骤定义不一致
+	DiagUnusedOutput      = "unused-output"      // 步骤返回值未被使用
+	DiagUnreachable       = "unreachable"        // return/break/continue 之后的语句不会执行
+)
+
+// Diagnostic 静态检查发现的问题
+type Diagnostic struct {
+	Line     int    `json:"line"`     // 行号
+	Severity string `json:"severity"` // 级别：error、warning
+	Code     string `json:"code"`     // 诊断类型
+	Message  string `json:"message"`  // 问题描述
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("第%d行: [%s] %s", d.Line, d.Severity, d.Message)
+}
+
+// HasErrors 诊断结果中是否有错误级别的问题
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// 模板中引用的变量：{{变量名}}
+var templateRefRegex = regexp.MustCompile(`\{\{\s*([\p{L}_][\p{L}\p{N}_]*)`)
+
+// Validate 对解析结果做静态检查，返回按行号排序的全部问题
+//
+// 检查调用的步骤和补偿步骤是否定义、参数和返回值数量、参数类型与步骤定义是否一致、
+// 变量在使用前是否赋值、步骤返回值是否被使用，以及 return/break/continue 之后的不可达语句。
+// 变量在 if 分支内赋值后，分支之后的语句也可以使用；range 的循环变量只在循环体内可用。
+func Validate(result *SimpleParseResult) []Diagnostic {
+	v := &validator{
+		result: result,
+		steps:  make(map[string]*SimpleStep),
+		used:   make(map[string]bool),
+	}
+	for _, step := range result.Steps {
+		v.steps[step.Name] = step
+	}
+	if result.MainFunc == nil {
+		return nil
+	}
+	for _, step := range result.Steps {
+		if name := step.GetCompensate(); name != "" && v.steps[name] == nil {
+			v.report(step.LineNumber, SeverityError, DiagUndefinedStep, "%s 的补偿步骤未定义: %s", step.Name, name)
+		}
+	}
+
+	v.walk(result.MainFunc.Statements, make(map[string]string))
+	v.checkUnused()
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		return v.diagnostics[i].Line < v.diagnostics[j].Line
+	})
+	return v.diagnostics
+}
+
+// validator 静态检查的状态，变量作用域以 变量名 -> 类型 的map传递
+type validator struct {
+	result      *SimpleParseResult
+	steps       map[string]*SimpleStep
+	outputs     []*ArgumentInfo // 所有步骤返回值，用于检查是否被使用
+	used        map[string]bool // 被读取过的变量
+	diagnostics []Diagnostic
+}
+
+func (v *validator) report(line int, severity, code, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		Line:     line,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// walk 按顺序检查语句列表，defined 为当前可见的变量，语句赋值的变量会加入其中
+func (v *validator) walk(statements []*SimpleStatement, defined map[string]string) {
+	for i, stmt := range statements {
+		v.walkStatement(stmt, defined)
+		if isTerminator(stmt) && i+1 < len(statements) {
+			// 同一语句块只报告第一条不可达语句，后面的语句不再检查
+			v.report(statements[i+1].LineNumber, SeverityWarning, DiagUnreachable, "%s 之后的语句不会执行", stmt.Type)
+			return
+		}
+	}
+}
+
+func isTerminator(stmt *SimpleStatement) bool {
+	return stmt.Type == "return" || stmt.Type == "break" || stmt.Type == "continue"
+}
+
+func (v *validator) walkStatement(stmt *SimpleStatement, defined map[string]string) {
+	switch stmt.Type {
+	case "function-call":
+		v.checkCall(stmt, defined)
+	case "print":
+		v.checkPrint(stmt, defined)
+	case "var":
+		if name, value, ok := strings.Cut(stmt.Content, ":="); ok {
+			v.useTemplate(value)
+			_, varType, _ := parseLiteral(value)
+			defined[strings.TrimSpace(name)] = varType
+		}
+	case "return":
+		if expr := strings.TrimSpace(strings.TrimPrefix(stmt.Content, "return")); expr != "" {
+			v.checkExpr(expr, stmt.LineNumber, defined)
+		}
+	case "if":
+		v.checkIf(stmt, defined)
+	case "for":
+		v.checkFor(stmt, defined)
+	case "wait":
+		// 信号内容的类型由 Signal 的调用方决定，第二个返回值为是否收到信号
+		for i, name := range stmt.Wait.Vars {
+			switch {
+			case name == "_":
+			case i == 0:
+				defined[name] = "unknown"
+			default:
+				defined[name] = "bool"
+			}
+		}
+	}
+}
+
+// checkCall 检查步骤调用：步骤是否定义、参数和返回值数量、参数类型、参数变量是否已赋值
+func (v *validator) checkCall(stmt *SimpleStatement, defined map[string]string) {
+	step, exists := v.steps[stmt.Function]
+	if !exists {
+		v.report(stmt.LineNumber, SeverityError, DiagUndefinedStep, "未定义的步骤: %s", stmt.Function)
+	}
+
+	for i, arg := range stmt.Args {
+		argType := v.argType(arg, stmt.LineNumber, defined)
+		if step == nil || step.IsStatic || i >= len(step.InputParams) {
+			continue
+		}
+		param := step.InputParams[i]
+		if !typeCompatible(param.Type, argType) {
+			v.report(stmt.LineNumber, SeverityError, DiagTypeMismatch, "%s 的第%d个参数 %s 类型应为 %s，实际为 %s",
+				stmt.Function, i+1, param.Name, param.Type, argType)
+		}
+	}
+
+	if step != nil {
+		if !step.IsStatic && len(stmt.Args) != len(step.InputParams) {
+			v.report(stmt.LineNumber, SeverityError, DiagArity, "%s 需要%d个参数，实际传入%d个",
+				stmt.Function, len(step.InputParams), len(stmt.Args))
+		}
+		if len(stmt.Returns) > 0 && len(stmt.Returns) != len(step.OutputParams) {
+			v.report(stmt.LineNumber, SeverityError, DiagArity, "%s 返回%d个值，实际接收%d个",
+				stmt.Function, len(step.OutputParams), len(stmt.Returns))
+		}
+	}
+
+	// 返回值在参数之后赋值，step1(x) 中的 x 不能是本语句的返回值
+	for _, ret := range stmt.Returns {
+		if ret.Value == "" || ret.Value == "_" {
+			continue
+		}
+		defined[ret.Value] = ret.Type
+		if ret.Value == stmt.Function+"Err" {
+			defined["err"] = ret.Type
+		}
+		v.outputs = append(v.outputs, ret)
+	}
+}
+
+// argType 返回参数的类型，变量参数检查是否已赋值
+func (v *validator) argType(arg *ArgumentInfo, line int, defined map[string]string) string {
+	switch {
+	case arg.IsInput:
+		key := strings.Trim(strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(arg.Value, "input["), "]")), `"`)
+		value, exists := v.result.InputVars[key]
+		if !exists {
+			v.report(line, SeverityError, DiagUndefinedVariable, "未定义的输入参数: %s", key)
+			return ""
+		}
+		return inputType(value)
+	case arg.IsLiteral:
+		return arg.Type
+	default:
+		return v.use(arg.Value, line, defined)
+	}
+}
+
+// use 记录变量被读取，未赋值时报告错误，返回变量类型
+func (v *validator) use(name string, line int, defined map[string]string) string {
+	v.used[name] = true
+	varType, exists := defined[name]
+	if !exists {
+		v.report(line, SeverityError, DiagUndefinedVariable, "变量 %s 在赋值前使用", name)
+	}
+	return varType
+}
+
+func (v *validator) useTemplate(text string) {
+	for _, matches := range templateRefRegex.FindAllStringSubmatch(text, -1) {
+		v.used[matches[1]] = true
+	}
+}
+
+// checkPrint 检查打印语句的接收者和参数
+func (v *validator) checkPrint(stmt *SimpleStatement, defined map[string]string) {
+	call, ok := parseCallExpr(stmt.Content)
+	if !ok {
+		v.report(stmt.LineNumber, SeverityError, DiagSyntax, "无法解析的打印语句: %s", stmt.Content)
+		return
+	}
+	if selector, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if receiver, ok := selector.X.(*ast.Ident); ok && receiver.Name != "sys" {
+			if _, exists := v.steps[receiver.Name]; !exists {
+				v.report(stmt.LineNumber, SeverityError, DiagUndefinedStep, "未定义的步骤: %s", receiver.Name)
+			}
+		}
+	}
+	for _, arg := range call.Args {
+		if lit, ok := arg.(*ast.BasicLit); ok {
+			v.useTemplate(lit.Value)
+		}
+		v.checkNode(arg, stmt.LineNumber, defined)
+	}
+}
+
+// checkExpr 检查表达式的语法和其中使用的变量
+func (v *validator) checkExpr(expr string, line int, defined map[string]string) {
+	node, err := parseExpression(expr, line)
+	if err != nil {
+		v.report(line, SeverityError, DiagSyntax, "%s", err.(*ExprError).Msg)
+		return
+	}
+	v.checkNode(node, line, defined)
+}
+
+// checkNode 检查表达式中使用的变量，字段名、类型名和内置标识符除外
+func (v *validator) checkNode(node ast.Expr, line int, defined map[string]string) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			v.checkNode(n.X, line, defined)
+			return false
+		case *ast.TypeAssertExpr:
+			v.checkNode(n.X, line, defined)
+			return false
+		case *ast.CallExpr:
+			for _, arg := range n.Args {
+				v.checkNode(arg, line, defined)
+			}
+			if _, ok := n.Fun.(*ast.Ident); !ok {
+				v.checkNode(n.Fun, line, defined)
+			}
+			return false
+		case *ast.Ident:
+			switch n.Name {
+			case "true", "false", "nil", "input":
+			default:
+				v.use(n.Name, line, defined)
+			}
+		}
+		return true
+	})
+}
+
+// checkIf 检查条件和各分支，分支内赋值的变量在if之后可见
+func (v *validator) checkIf(stmt *SimpleStatement, defined map[string]string) {
+	v.checkExpr(stmt.Condition, stmt.LineNumber, defined)
+
+	after := copyScope(defined)
+	branch := copyScope(defined)
+	v.walk(stmt.Children, branch)
+	mergeScope(after, branch)
+
+	for _, b := range stmt.Branches {
+		if b.Condition != "" {
+			v.checkExpr(b.Condition, b.LineNumber, defined)
+		}
+		branch := copyScope(defined)
+		v.walk(b.Children, branch)
+		mergeScope(after, branch)
+	}
+	mergeScope(defined, after)
+}
+
+// checkFor 检查循环，range的循环变量只在循环体内可见
+func (v *validator) checkFor(stmt *SimpleStatement, defined map[string]string) {
+	body := copyScope(defined)
+	if stmt.Loop != nil && stmt.Loop.Kind == LoopKindRange {
+		v.checkExpr(stmt.Loop.RangeExpr, stmt.LineNumber, defined)
+		rangeType := ""
+		if node, err := parseExpression(stmt.Loop.RangeExpr, stmt.LineNumber); err == nil {
+			if ident, ok := node.(*ast.Ident); ok {
+				rangeType = defined[ident.Name]
+			}
+		}
+		keyType, valueType := "int", ""
+		if strings.HasPrefix(rangeType, "[]") {
+			valueType = rangeType[2:]
+		} else if strings.HasPrefix(rangeType, "map[") {
+			keyType = ""
+		}
+		for name, varType := range map[string]string{stmt.Loop.KeyVar: keyType, stmt.Loop.ValueVar: valueType} {
+			if name != "" && name != "_" {
+				body[name] = varType
+				// 循环变量不要求被使用
+				v.used[name] = true
+			}
+		}
+	} else if stmt.Condition != "" {
+		v.checkExpr(stmt.Condition, stmt.LineNumber, defined)
+	}
+
+	v.walk(stmt.Children, body)
+	for name, varType := range body {
+		if stmt.Loop != nil && (name == stmt.Loop.KeyVar || name == stmt.Loop.ValueVar) {
+			continue
+		}
+		if _, exists := defined[name]; !exists {
+			defined[name] = varType
+		}
+	}
+}
+
+// checkUnused 报告从未被读取的步骤返回值
+func (v *validator) checkUnused() {
+	for _, ret := range v.outputs {
+		if v.used[ret.Value] || (ret.Value == ret.Source+"Err" && v.used["err"]) {
+			continue
+		}
+		v.report(ret.LineNum, SeverityWarning, DiagUnusedOutput, "%s 的返回值 %s 未被使用", ret.Source, ret.Value)
+		// 同名变量被多次赋值时只报告一次
+		v.used[ret.Value] = true
+	}
+}
+
+func copyScope(scope map[string]string) map[string]string {
+	copied := make(map[string]string, len(scope))
+	for name, varType := range scope {
+		copied[name] = varType
+	}
+	return copied
+}
+
+func mergeScope(dst, src map[string]string) {
+	for name, varType := range src {
+		if _, exists := dst[name]; !exists {
+			dst[name] = varType
+		}
+	}
+}
+
+// inputType 返回输入参数值的类型
+func inputType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case int:
+		return "int"
+	case bool:
+		return "bool"
+	default:
+		return ""
+	}
+}
+
+// typeCompatible 判断实际类型能否传给声明的参数类型，任一方类型未知时视为兼容
+func typeCompatible(want, got string) bool {
+	want, got = normalizeType(want), normalizeType(got)
+	if want == "" || got == "" || want == got {
+		return true
+	}
+	// 整数可以传给浮点参数
+	return want == "float" && got == "int"
+}
+
+// normalizeType 统一类型名，无法静态确定的类型返回空
+func normalizeType(t string) string {
+	switch t = strings.TrimSpace(t); t {
+	case "string", "bool", "error":
+		return t
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "int"
+	case "float", "float32", "float64", "number":
+		return "float"
+	}
+	if strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") {
+		return t
+	}
+	// variable、input、unknown、interface{}、any 等
+	return ""
+}
@@ -0,0 +1,146 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidate_Valid 测试正确的工作流没有错误
+func TestValidate_Valid(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "用户名": "张三",
+    "手机号": 13800138000,
+}
+
+step1 = beiluo.test1.devops.devops_script_create(username: string "用户名", phone: int "手机号") -> (workId: string "工号", err: error "是否失败");
+step2 = beiluo.test1.crm.list_candidates(workId: string "工号") -> (candidates: []string "候选人列表", err: error "是否失败");
+step3 = beiluo.test1.crm.schedule_interview(name: string "候选人") -> (time: string "面试时间", err: error "是否失败");
+
+func main() {
+    工号, err := step1(input["用户名"], input["手机号"])
+    if err != nil {
+        step1.Printf("创建失败: %v", err)
+        return
+    }
+    候选人列表, step2Err := step2(工号)
+    if step2Err != nil {
+        return
+    }
+    for i, 候选人 := range 候选人列表 {
+        面试时间, step3Err := step3(候选人)
+        if step3Err != nil {
+            continue
+        }
+        step3.Printf("%d: %s %s", i, 候选人, 面试时间)
+    }
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if diagnostics := Validate(result); len(diagnostics) != 0 {
+		t.Errorf("不应有诊断信息: %v", diagnostics)
+	}
+}
+
+// TestValidate_Problems 测试一次报告全部问题及其行号
+func TestValidate_Problems(t *testing.T) {
+	code := `var input = map[string]interface{}{
+    "用户名": "张三",
+}
+
+step1 = beiluo.test1.devops.devops_script_create(username: string "用户名", phone: int "手机号") -> (workId: string "工号", err: error "是否失败");
+step2 = beiluo.test1.crm.schedule_interview(name: string "候选人") -> (time: string "面试时间", err: error "是否失败");
+
+func main() {
+    工号, step1Err := step1(input["用户名"], "13800138000")
+    面试时间, step2Err := step2(工号, "额外参数")
+    结果, step9Err := step9(工号)
+    if 部门 == "研发" {
+        sys.Println(工号)
+    }
+    编号, step1Err := step1(input["邮箱"])
+    if step1Err != nil {
+        return
+        step2.Printf("不会执行: %s", 面试时间)
+    }
+    step7.Printf("完成: %v", 结果)
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	want := []struct {
+		line int
+		code string
+		text string
+	}{
+		{9, DiagTypeMismatch, "phone 类型应为 int，实际为 string"},
+		{10, DiagArity, "step2 需要1个参数，实际传入2个"},
+		{10, DiagUnusedOutput, "step2 的返回值 面试时间 未被使用"}, // 只在不可达语句中使用
+		{10, DiagUnusedOutput, "step2 的返回值 step2Err 未被使用"},
+		{11, DiagUndefinedStep, "未定义的步骤: step9"},
+		{11, DiagUnusedOutput, "step9 的返回值 step9Err 未被使用"},
+		{12, DiagUndefinedVariable, "变量 部门 在赋值前使用"},
+		{15, DiagUndefinedVariable, "未定义的输入参数: 邮箱"},
+		{15, DiagArity, "step1 需要2个参数，实际传入1个"},
+		{15, DiagUnusedOutput, "step1 的返回值 编号 未被使用"},
+		{18, DiagUnreachable, "return 之后的语句不会执行"},
+		{20, DiagUndefinedStep, "未定义的步骤: step7"},
+	}
+
+	diagnostics := Validate(result)
+	if len(diagnostics) != len(want) {
+		t.Fatalf("诊断数量不正确: 期望 %d, 实际 %d\n%v", len(want), len(diagnostics), diagnostics)
+	}
+	for i, w := range want {
+		d := diagnostics[i]
+		if d.Line != w.line || d.Code != w.code || !strings.Contains(d.Message, w.text) {
+			t.Errorf("第%d条诊断不正确: 期望 第%d行 %s %q, 实际 %s (%s)", i, w.line, w.code, w.text, d, d.Code)
+		}
+	}
+	if !HasErrors(diagnostics) {
+		t.Error("应包含错误级别的诊断")
+	}
+}
+
+// TestValidate_Scopes 测试if分支和range循环变量的可见范围
+func TestValidate_Scopes(t *testing.T) {
+	code := `step1 = beiluo.test1.crm.list_candidates(position: string "职位") -> (candidates: []string "候选人列表", err: error "是否失败");
+step2 = beiluo.test1.crm.count(total: int "人数") -> (msg: string "消息", err: error "是否失败");
+
+func main() {
+    候选人列表, step1Err := step1("工程师")
+    if step1Err != nil {
+        提示 := "查询失败"
+    } else {
+        sys.Println(提示)
+    }
+    sys.Println(提示)
+    for i, 候选人 := range 候选人列表 {
+        消息, step2Err := step2(候选人)
+    }
+    sys.Println(候选人, 消息, step2Err)
+}`
+
+	result := NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+
+	var got []string
+	for _, d := range Validate(result) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"第9行: [error] 变量 提示 在赋值前使用",
+		"第13行: [error] step2 的第1个参数 total 类型应为 int，实际为 string",
+		"第15行: [error] 变量 候选人 在赋值前使用",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("诊断不正确:\n%s\n期望:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,248 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"time"
+)
+
+// StatusWaiting 语句或工作流正在等待外部信号
+const StatusWaiting StatementStatus = "waiting"
+
+// WaitInfo 等待信号语句的信息
+//
+//	审批结果 := wait("manager_approval", "24h")       // 超时后工作流失败
+//	审批结果, ok := wait("manager_approval", 60000)   // 超时后 ok 为false，继续执行
+type WaitInfo struct {
+	Signal   string        `json:"signal"`   // 信号名
+	Timeout  time.Duration `json:"timeout"`  // 等待超时时间，0表示一直等待
+	Vars     []string      `json:"vars"`     // 接收信号内容的变量，第二个变量为是否收到信号
+	Deadline *time.Time    `json:"deadline"` // 第一次开始等待时计算的截止时间，恢复执行后沿用
+	Received bool          `json:"received"` // 是否已收到信号
+	Payload  interface{}   `json:"payload"`  // 信号内容
+}
+
+// WaitTimeoutError 等待信号超时
+type WaitTimeoutError struct {
+	Signal  string        // 信号名
+	Line    int           // 语句行号
+	Timeout time.Duration // 超时时间
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("第%d行: 等待信号 %s 超时(%v)", e.Line, e.Signal, e.Timeout)
+}
+
+// convertWait 解析 wait(信号名, 超时) 语句，vars 为 := 左边的变量
+func (d *dslParser) convertWait(stmt *SimpleStatement, call *ast.CallExpr, vars []string) {
+	stmt.Type = "wait"
+	stmt.Function = "wait"
+	stmt.Wait = &WaitInfo{Vars: vars}
+
+	if len(vars) > 2 {
+		d.errorf(d.offset(call.Pos()), "wait 最多返回2个值")
+	}
+	if len(call.Args) == 0 || len(call.Args) > 2 {
+		d.errorf(d.offset(call.Pos()), "wait 需要信号名和可选的超时时间两个参数")
+		return
+	}
+	if lit, ok := call.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		stmt.Wait.Signal, _ = strconv.Unquote(lit.Value)
+	}
+	if stmt.Wait.Signal == "" {
+		d.errorf(d.offset(call.Args[0].Pos()), "wait 的信号名应为字符串")
+	}
+	if len(call.Args) == 2 {
+		lit, ok := call.Args[1].(*ast.BasicLit)
+		timeout, valid := time.Duration(0), false
+		if ok && lit.Kind == token.INT {
+			timeout, valid = metadataDuration(mustAtoi(lit.Value))
+		} else if ok && lit.Kind == token.STRING {
+			value, _ := strconv.Unquote(lit.Value)
+			timeout, valid = metadataDuration(value)
+		}
+		if !valid || timeout < 0 {
+			d.errorf(d.offset(call.Args[1].Pos()), "wait 的超时时间应为毫秒数或 \"24h\" 格式的字符串")
+		}
+		stmt.Wait.Timeout = timeout
+	}
+
+	for i, name := range vars {
+		if name == "_" {
+			continue
+		}
+		varType := "unknown"
+		if i == 1 {
+			varType = "bool"
+		}
+		d.result.Variables[name] = VariableInfo{
+			Name:    name,
+			Type:    varType,
+			Source:  "wait",
+			LineNum: stmt.LineNumber,
+			IsInput: false,
+		}
+	}
+}
+
+// mustAtoi 解析整数字面量，溢出时返回0
+func mustAtoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// signalChannel 返回流程接收指定信号的通道，需持有 e.mu
+func (entry *flowEntry) signalChannel(name string) chan interface{} {
+	if entry.signals == nil {
+		entry.signals = make(map[string]chan interface{})
+	}
+	ch, exists := entry.signals[name]
+	if !exists {
+		ch = make(chan interface{}, 1)
+		entry.signals[name] = ch
+	}
+	return ch
+}
+
+// Signal 向流程发送信号，唤醒等待该信号的 wait 语句，payload 赋值给 wait 的接收变量
+//
+// 流程正在运行时，信号在流程执行到对应的 wait 时生效，同名信号在被处理前只能发送一次。
+// 流程不在运行中（如进程重启后）且设置了 Store 时，信号保存到等待中的 wait 语句，
+// 之后调用 Resume 恢复执行时直接使用该信号。
+func (e *Executor) Signal(ctx context.Context, flowID, name string, payload interface{}) error {
+	e.mu.Lock()
+	if entry, exists := e.flows[flowID]; exists {
+		defer e.mu.Unlock()
+		select {
+		case entry.signalChannel(name) <- payload:
+			return nil
+		default:
+			return fmt.Errorf("流程 %s 的信号 %s 已发送，尚未处理", flowID, name)
+		}
+	}
+	e.mu.Unlock()
+
+	if e.Store == nil {
+		return fmt.Errorf("流程 %s 未在运行中", flowID)
+	}
+	workflow, err := e.Store.Load(ctx, flowID)
+	if err != nil {
+		return err
+	}
+	stmt := findWaiting(workflow.MainFunc.Statements, name)
+	if stmt == nil {
+		return fmt.Errorf("流程 %s 没有等待信号 %s 的语句", flowID, name)
+	}
+	stmt.Wait.Received = true
+	stmt.Wait.Payload = payload
+	return e.Store.Save(ctx, workflow)
+}
+
+// findWaiting 查找正在等待指定信号的 wait 语句
+func findWaiting(statements []*SimpleStatement, name string) *SimpleStatement {
+	for _, stmt := range statements {
+		if stmt.Type == "wait" && stmt.Status == StatusWaiting && stmt.Wait.Signal == name {
+			return stmt
+		}
+		for _, group := range [][]*SimpleStatement{stmt.Children, stmt.Branches} {
+			if found := findWaiting(group, name); found != nil {
+				return found
+			}
+		}
+		if stmt.Loop != nil {
+			for _, iteration := range stmt.Loop.Iterations {
+				if found := findWaiting(iteration.Statements, name); found != nil {
+					return found
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// executeWaitStatement 执行 wait 语句：流程进入 waiting 状态，收到信号、超时或被取消后继续
+func (e *Executor) executeWaitStatement(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
+	info := stmt.Wait
+	if !info.Received {
+		e.mu.Lock()
+		entry, exists := e.flows[workflow.FlowID]
+		var ch chan interface{}
+		if exists {
+			ch = entry.signalChannel(info.Signal)
+		}
+		e.mu.Unlock()
+		if ch == nil {
+			return fmt.Errorf("流程 %s 未在运行中，无法等待信号", workflow.FlowID)
+		}
+
+		if info.Timeout > 0 && info.Deadline == nil {
+			deadline := time.Now().Add(info.Timeout)
+			info.Deadline = &deadline
+		}
+		var timeout <-chan time.Time
+		if info.Deadline != nil {
+			timer := time.NewTimer(time.Until(*info.Deadline))
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		// 进入等待状态并保存，等待期间释放运行时锁
+		stmt.Status = StatusWaiting
+		workflow.Status = StatusWaiting
+		if err := e.updateWorkflow(ctx, workflow); err != nil {
+			return err
+		}
+		var payload interface{}
+		received := false
+		flowRunFromContext(ctx).unlocked(func() {
+			select {
+			case payload = <-ch:
+				received = true
+			case <-timeout:
+			case <-ctx.Done():
+			}
+		})
+		workflow.Status = StatusRunning
+
+		// 被取消（如服务关机）时语句保持 waiting，保存后仍可以 Signal，Resume 后继续等待
+		if ctx.Err() != nil && !received {
+			err := interruptedError(ctx, stmt, "等待信号")
+			stmt.EndExecution()
+			if stmt.Status != StatusTimeout {
+				stmt.Status = StatusWaiting
+			}
+			_ = e.updateWorkflow(ctx, workflow)
+			return err
+		}
+		if !received && len(info.Vars) < 2 {
+			stmt.Status = StatusTimeout
+			return &WaitTimeoutError{Signal: info.Signal, Line: stmt.LineNumber, Timeout: info.Timeout}
+		}
+		info.Received = received
+		info.Payload = payload
+	}
+
+	// 信号内容和是否收到信号赋值给接收变量
+	for i, name := range info.Vars {
+		if name == "_" {
+			continue
+		}
+		value, varType := info.Payload, "unknown"
+		if i == 1 {
+			value, varType = info.Received, "bool"
+		}
+		e.setVariable(ctx, workflow, VariableInfo{
+			Name:    name,
+			Type:    varType,
+			Value:   value,
+			Source:  "wait",
+			LineNum: stmt.LineNumber,
+			IsInput: false,
+		})
+	}
+	stmt.Status = StatusCompleted
+	return e.updateWorkflow(ctx, workflow)
+}
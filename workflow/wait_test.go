@@ -0,0 +1,305 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const waitTestCode = `var input = map[string]interface{}{
+    "姓名": "张三",
+}
+
+step1 = beiluo.test1.hr.submit_leave(name: string "姓名") -> (leaveId: string "请假单号", err: error "是否失败");
+step2 = beiluo.test1.hr.approve_leave(leaveId: string "请假单号", comment: string "审批意见") -> (err: error "是否失败");
+
+func main() {
+    请假单号, step1Err := step1(input["姓名"])
+    if step1Err != nil {
+        return
+    }
+    审批意见 := wait("manager_approval")
+    step2Err := step2(请假单号, 审批意见)
+    if step2Err != nil {
+        return
+    }
+    sys.Printf("审批完成: %s", 审批意见)
+}`
+
+// waitTestExecutor 返回记录 step2 审批意见的执行器，waiting 在流程进入 waiting 状态时收到通知
+func waitTestExecutor(comments chan<- interface{}) (*Executor, <-chan *SimpleParseResult) {
+	waiting := make(chan *SimpleParseResult, 1)
+	executor := NewExecutor()
+	executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+		if step.Name == "step1" {
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"leaveId": "L001", "err": nil}}, nil
+		}
+		comments <- in.RealInput["comment"]
+		return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"err": nil}}, nil
+	}
+	executor.OnWorkFlowUpdate = func(ctx context.Context, current *SimpleParseResult) error {
+		if current.Status == StatusWaiting {
+			select {
+			case waiting <- current:
+			default:
+			}
+		}
+		return nil
+	}
+	return executor, waiting
+}
+
+// TestParseWorkflow_Wait 测试 wait 语句的解析
+func TestParseWorkflow_Wait(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(strings.Replace(waitTestCode, `wait("manager_approval")`, `wait("manager_approval", "24h")`, 1))
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	stmt := result.MainFunc.Statements[2]
+	if stmt.Type != "wait" || stmt.Wait.Signal != "manager_approval" || stmt.Wait.Timeout != 24*time.Hour || stmt.Wait.Vars[0] != "审批意见" {
+		t.Errorf("wait 语句不正确: %+v %+v", stmt, stmt.Wait)
+	}
+	if diagnostics := Validate(result); len(diagnostics) != 0 {
+		t.Errorf("不应有诊断: %v", diagnostics)
+	}
+
+	result = NewSimpleParser().ParseWorkflow(strings.Replace(waitTestCode, `wait("manager_approval")`, `wait("manager_approval", 100)`, 1))
+	if result.MainFunc.Statements[2].Wait.Timeout != 100*time.Millisecond {
+		t.Errorf("毫秒超时不正确: %v", result.MainFunc.Statements[2].Wait.Timeout)
+	}
+
+	for _, invalid := range []string{`wait()`, `wait(approval)`, `wait("manager_approval", "一天")`} {
+		result = NewSimpleParser().ParseWorkflow(strings.Replace(waitTestCode, `wait("manager_approval")`, invalid, 1))
+		if result.Success || len(result.Errors) == 0 || result.Errors[0].Line != 13 {
+			t.Errorf("%s 应报告解析错误: %+v", invalid, result.Errors)
+		}
+	}
+}
+
+// TestExecutor_Signal 测试流程等待信号，收到信号后绑定变量继续执行
+func TestExecutor_Signal(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(waitTestCode)
+	comments := make(chan interface{}, 1)
+	executor, waiting := waitTestExecutor(comments)
+
+	done := make(chan error, 1)
+	go func() { done <- executor.Start(context.Background(), result) }()
+	snapshot := <-waiting
+	if status := snapshot.MainFunc.Statements[2].Status; status != StatusWaiting {
+		t.Errorf("wait 语句状态不正确: %s", status)
+	}
+	if current, _ := executor.Get(result.FlowID); current.Status != StatusWaiting {
+		t.Errorf("流程状态不正确: %s", current.Status)
+	}
+
+	if err := executor.Signal(context.Background(), result.FlowID, "manager_approval", "同意"); err != nil {
+		t.Fatalf("发送信号失败: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if comment := <-comments; comment != "同意" {
+		t.Errorf("信号内容未传给后续步骤: %v", comment)
+	}
+	if result.Status != StatusCompleted || result.MainFunc.Statements[2].Status != StatusCompleted {
+		t.Errorf("状态不正确: %s %s", result.Status, result.MainFunc.Statements[2].Status)
+	}
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "审批完成: 同意" {
+		t.Errorf("日志不正确: %+v", result.GlobalLogs)
+	}
+	if err := executor.Signal(context.Background(), result.FlowID, "manager_approval", "同意"); err == nil {
+		t.Error("流程结束后发送信号应失败")
+	}
+}
+
+// TestExecutor_WaitTimeout 测试等待超时：单变量时工作流失败，双变量时 ok 为false继续执行
+func TestExecutor_WaitTimeout(t *testing.T) {
+	result := NewSimpleParser().ParseWorkflow(strings.Replace(waitTestCode, `wait("manager_approval")`, `wait("manager_approval", 10)`, 1))
+	executor, _ := waitTestExecutor(make(chan interface{}, 1))
+	err := executor.Start(context.Background(), result)
+	var timeoutErr *WaitTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Signal != "manager_approval" || timeoutErr.Line != 13 {
+		t.Fatalf("应返回等待超时错误: %v", err)
+	}
+	if result.Status != StatusFailed || result.MainFunc.Statements[2].Status != StatusTimeout {
+		t.Errorf("状态不正确: %s %s", result.Status, result.MainFunc.Statements[2].Status)
+	}
+
+	code := strings.Replace(waitTestCode, `审批意见 := wait("manager_approval")`, `审批意见, 已审批 := wait("manager_approval", "10ms")
+    if !已审批 {
+        sys.Println("审批超时")
+        return
+    }`, 1)
+	result = NewSimpleParser().ParseWorkflow(code)
+	if !result.Success {
+		t.Fatalf("解析失败: %s", result.Error)
+	}
+	if err := executor.Start(context.Background(), result); err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if variable, _ := result.GetVariable("已审批"); variable.Value != false {
+		t.Errorf("已审批应为false: %v", variable.Value)
+	}
+	if len(result.GlobalLogs) != 1 || result.GlobalLogs[0].Message != "审批超时" {
+		t.Errorf("日志不正确: %+v", result.GlobalLogs)
+	}
+}
+
+// TestExecutor_SignalResume 测试流程停止后通过存储发送信号并恢复执行
+func TestExecutor_SignalResume(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	result := NewSimpleParser().ParseWorkflow(waitTestCode)
+	comments := make(chan interface{}, 1)
+	executor, waiting := waitTestExecutor(comments)
+	executor.Store = store
+
+	done := make(chan error, 1)
+	go func() { done <- executor.Start(context.Background(), result) }()
+	<-waiting
+	if err := executor.Stop(context.Background(), result.FlowID); err != nil {
+		t.Fatalf("停止失败: %v", err)
+	}
+	if err := <-done; err == nil {
+		t.Fatal("流程应被取消")
+	}
+
+	// 新的执行器（如进程重启后）收到信号，保存到存储后恢复执行
+	resumed, _ := waitTestExecutor(comments)
+	resumed.Store = store
+	if err := resumed.Signal(context.Background(), result.FlowID, "unknown_signal", nil); err == nil {
+		t.Error("没有等待该信号的语句时应失败")
+	}
+	if err := resumed.Signal(context.Background(), result.FlowID, "manager_approval", "同意"); err != nil {
+		t.Fatalf("发送信号失败: %v", err)
+	}
+	if err := resumed.Resume(context.Background(), result.FlowID); err != nil {
+		t.Fatalf("恢复执行失败: %v", err)
+	}
+	if comment := <-comments; comment != "同意" {
+		t.Errorf("信号内容未传给后续步骤: %v", comment)
+	}
+	final, err := store.Load(context.Background(), result.FlowID)
+	if err != nil || final.Status != StatusCompleted {
+		t.Errorf("工作流状态不正确: %v %v", final, err)
+	}
+}
+
+// TestExecutor_SignalResumeInLoop 测试循环中等待信号的流程恢复执行时，已完成的迭代不再执行，信号在当前迭代生效
+func TestExecutor_SignalResumeInLoop(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建存储失败: %v", err)
+	}
+	code := `var input = map[string]interface{}{
+    "名单": []string{"张三", "李四"},
+}
+
+step1 = beiluo.test1.hr.submit_leave(name: string "姓名") -> (leaveId: string "请假单号", err: error "是否失败");
+step2 = beiluo.test1.hr.approve_leave(leaveId: string "请假单号", comment: string "审批意见") -> (err: error "是否失败");
+
+func main() {
+    for _, 姓名 := range input["名单"] {
+        请假单号, step1Err := step1(姓名)
+        审批意见 := wait("manager_approval")
+        step2Err := step2(请假单号, 审批意见)
+    }
+    sys.Println("全部完成")
+}`
+	var mu sync.Mutex
+	var calls []string
+	newExecutor := func() *Executor {
+		executor := NewExecutor()
+		executor.Store = store
+		executor.OnFunctionCall = func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if step.Name == "step1" {
+				calls = append(calls, fmt.Sprintf("step1(%v)", in.RealInput["name"]))
+				return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"leaveId": fmt.Sprintf("L-%v", in.RealInput["name"]), "err": nil}}, nil
+			}
+			calls = append(calls, fmt.Sprintf("step2(%v, %v)", in.RealInput["leaveId"], in.RealInput["comment"]))
+			return &ExecutorOut{Success: true, WantOutput: map[string]interface{}{"err": nil}}, nil
+		}
+		return executor
+	}
+	// waitIteration 等待流程在第 n 次迭代中等待信号
+	waitIteration := func(executor *Executor, flowID string, n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			snapshot, err := executor.Get(flowID)
+			if err == nil && snapshot.Status == StatusWaiting && len(snapshot.MainFunc.Statements[0].Loop.Iterations) == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("流程应在第%d次迭代中等待信号", n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// 第一次迭代收到信号，第二次迭代等待时进程停止
+	result := NewSimpleParser().ParseWorkflow(code)
+	executor := newExecutor()
+	done := make(chan error, 1)
+	go func() { done <- executor.Start(context.Background(), result) }()
+	waitIteration(executor, result.FlowID, 1)
+	if err := executor.Signal(context.Background(), result.FlowID, "manager_approval", "同意"); err != nil {
+		t.Fatalf("发送信号失败: %v", err)
+	}
+	waitIteration(executor, result.FlowID, 2)
+	if err := executor.Stop(context.Background(), result.FlowID); err != nil {
+		t.Fatalf("停止失败: %v", err)
+	}
+	if err := <-done; err == nil {
+		t.Fatal("流程应被取消")
+	}
+
+	// 信号保存到第二次迭代的 wait 语句，恢复后从该迭代继续
+	resumed := newExecutor()
+	if err := resumed.Signal(context.Background(), result.FlowID, "manager_approval", "驳回"); err != nil {
+		t.Fatalf("发送信号失败: %v", err)
+	}
+	mu.Lock()
+	calls = nil
+	mu.Unlock()
+	go func() { done <- resumed.Resume(context.Background(), result.FlowID) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("恢复执行失败: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("恢复执行时应使用保存的信号")
+	}
+	if got := strings.Join(calls, ", "); got != "step2(L-李四, 驳回)" {
+		t.Errorf("恢复执行的步骤不正确: %s", got)
+	}
+
+	final, err := store.Load(context.Background(), result.FlowID)
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	loop := final.MainFunc.Statements[0].Loop
+	if final.Status != StatusCompleted || len(loop.Iterations) != 2 || loop.Completed != 2 {
+		t.Fatalf("循环状态不正确: %s %d %d", final.Status, len(loop.Iterations), loop.Completed)
+	}
+	for i, iteration := range loop.Iterations {
+		if iteration.Status != StatusCompleted || iteration.Statements[2].Status != StatusCompleted {
+			t.Errorf("第%d次迭代状态不正确: %s", i, iteration.Status)
+		}
+	}
+	if _, exists := final.GetVariable("姓名"); exists {
+		t.Error("循环变量在循环结束后不应保留")
+	}
+	if len(final.GlobalLogs) != 1 || final.GlobalLogs[0].Message != "全部完成" {
+		t.Errorf("日志不正确: %+v", final.GlobalLogs)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,13 +34,20 @@ type ExecutorOut struct {
 	Success    bool                   `json:"success"`     // 执行是否成功
 	WantOutput map[string]interface{} `json:"want_output"` // 输出参数
 	Error      string                 `json:"error"`       // 错误信息
+	ErrorCode  string                 `json:"error_code"`  // 业务错误码，可在 retry_on 中指定需要重试的错误码
 	Logs       []string               `json:"logs"`        // 执行日志
 }
 
 // OnFunctionCall 执行function-call 回调
+//
+// 回调应在 ctx 取消后尽快返回：步骤超时、工作流取消或整体超时后执行器不再等待回调，
+// 忽略 ctx 的回调会继续占用goroutine和下游资源，返回值被丢弃。
 type OnFunctionCall func(ctx context.Context, step SimpleStep, in *ExecutorIn) (*ExecutorOut, error)
 
 // OnWorkFlowUpdate 每次执行后我们需要回调，业务侧需要保存整个工作流的状态
+//
+// current 是工作流在本次更新时的快照，回调返回后仍可以安全地读取或异步保存。
+// 同一流程的状态回调串行触发，不同流程的回调可能并发触发。
 type OnWorkFlowUpdate func(ctx context.Context, current *SimpleParseResult) error
 
 // OnWorkFlowExit 正常结束
@@ -53,104 +62,319 @@ type Executor struct {
 	OnWorkFlowExit   OnWorkFlowExit
 	OnWorkFlowReturn OnWorkFlowReturn
 
-	// 流程管理
-	FlowMap      map[string]*SimpleParseResult
-	RunningFlows map[string]context.CancelFunc // 正在运行的流程
+	// 工作流状态存储，设置后每次状态更新时保存整个工作流，进程重启后可通过 Resume 恢复执行
+	Store WorkflowStore
+
+	// 子工作流注册表，步骤定义为 workflow.名称.版本 时从中加载子工作流
+	Registry WorkflowRegistry
+
+	// 调试模式，开启后设置了 debug: true 的语句作为断点，执行前暂停，见 Pause
+	Debug bool
+
+	// 步骤结果缓存，设置了 cache 元数据的步骤命中缓存时不调用 OnFunctionCall；NewExecutor 默认使用 MemoryCache，为nil时不缓存
+	Cache StepCache
+
+	// 通过 RegisterType 注册的语句处理器，语句类型 -> 处理器
+	handlers map[string]StatementHandler
+
+	// 通过 Subscribe 订阅的执行事件观察者，按订阅顺序通知
+	observers    []observerEntry
+	nextObserver int
+
+	// 流程管理，Start/Resume/Stop/Get 可以在多个goroutine中并发调用
+	mu    sync.RWMutex
+	flows map[string]*flowEntry // 流程ID -> 正在运行的流程状态，流程结束后删除
+}
+
+// flowEntry 执行器中一个流程的状态
+type flowEntry struct {
+	cancel   context.CancelFunc          // 取消函数
+	run      *flowRun                    // 运行时状态，Get 在它的锁下复制工作流
+	workflow *SimpleParseResult          // 正在执行的工作流
+	signals  map[string]chan interface{} // 信号名 -> 尚未被 wait 处理的信号，见 Signal
+	debug    debugState                  // 暂停和单步执行状态，见 Pause
+}
+
+// 正在执行的流程ID在上下文中的键
+type flowIDKey struct{}
+
+// FlowIDFromContext 返回回调所属的流程ID，子工作流中的回调返回子流程的ID
+func FlowIDFromContext(ctx context.Context) string {
+	flowID, _ := ctx.Value(flowIDKey{}).(string)
+	return flowID
 }
 
 type ExecutorResp struct {
 }
 
+// 变量赋值语句：变量名 := 值，支持中文变量名
+var varAssignmentRegex = regexp.MustCompile(`([\w\p{Han}]+)\s*:=\s*(.+)`)
+
+// 控制流信号，通过error在嵌套语句间向上传递，不表示执行失败
+var (
+	errFlowReturn   = errors.New("工作流已返回")
+	errLoopBreak    = errors.New("break")
+	errLoopContinue = errors.New("continue")
+)
+
+// isControlFlow 判断是否为 return/break/continue 控制流信号
+func isControlFlow(err error) bool {
+	return errors.Is(err, errFlowReturn) || errors.Is(err, errLoopBreak) || errors.Is(err, errLoopContinue)
+}
+
 // NewExecutor 创建新的执行器
 func NewExecutor() *Executor {
 	return &Executor{
-		FlowMap:      make(map[string]*SimpleParseResult),
-		RunningFlows: make(map[string]context.CancelFunc),
+		Cache: NewMemoryCache(),
+		flows: make(map[string]*flowEntry),
 	}
 }
 
 // Start 启动工作流执行
 func (e *Executor) Start(ctx context.Context, workflow *SimpleParseResult) error {
-	// 1. 检查流程是否已经在运行
-	if _, exists := e.RunningFlows[workflow.FlowID]; exists {
-		return fmt.Errorf("流程 %s 已在运行中", workflow.FlowID)
+	return e.run(ctx, workflow, false)
+}
+
+// Resume 继续执行暂停的流程，或从 Store 中加载工作流并继续执行
+//
+// 流程在本执行器中因 Pause、Step 或断点暂停时，通知流程继续执行后立即返回；尚未暂停时取消暂停请求。
+//
+// 流程不在运行中时从 Store 加载，执行完成后返回：已完成（或跳过）的语句不再执行，
+// 从第一个未完成的语句继续，变量使用保存时的值。
+// 崩溃时正在执行的步骤会重新执行；正在执行的for循环会从第一次迭代重新开始。
+func (e *Executor) Resume(ctx context.Context, flowID string) error {
+	if running, err := e.command(ctx, flowID, debugCommand{action: debugContinue}); running {
+		return err
 	}
+	if e.Store == nil {
+		return fmt.Errorf("执行器未设置工作流存储")
+	}
+	workflow, err := e.Store.Load(ctx, flowID)
+	if err != nil {
+		return err
+	}
+	if workflow.Status == StatusCompleted {
+		return fmt.Errorf("流程 %s 已执行完成", flowID)
+	}
+	return e.run(ctx, workflow, true)
+}
 
-	// 2. 创建子上下文用于取消
-	flowCtx, cancel := context.WithCancel(ctx)
-	e.RunningFlows[workflow.FlowID] = cancel
+// run 执行工作流，resume 为true时跳过已完成的语句
+func (e *Executor) run(ctx context.Context, workflow *SimpleParseResult, resume bool) error {
+	// 1. 检查流程是否已经在运行，并保存流程到映射表
+	// 执行期间（包括补偿）持有运行时锁，异步步骤与主流程共享运行时状态
+	run := newFlowRun(workflow.GetMaxParallel())
+	run.resuming = resume
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	ctx = context.WithValue(ctx, flowRunKey{}, run)
+	flowCtx, cancel := context.WithCancel(context.WithValue(ctx, flowIDKey{}, workflow.FlowID))
+	defer cancel()
+	// 登记成功前不修改工作流，同一工作流正在运行时由它的执行goroutine持有
+	if err := e.register(workflow, run, cancel); err != nil {
+		return err
+	}
+	workflow.variablesLock() // 异步步骤和业务侧并发读写变量前创建变量锁
+	workflow.Status = StatusRunning
+	workflow.Paused = nil
+	start := time.Now()
+	e.emit(flowCtx, workflow, Event{Type: EventFlowStarted})
+
+	// 2. 流程结束后从映射表删除，最终状态由 Store 保存
 	defer func() {
-		delete(e.RunningFlows, workflow.FlowID)
-		cancel()
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.flows, workflow.FlowID)
 	}()
 
-	// 3. 保存流程到映射表
-	e.FlowMap[workflow.FlowID] = workflow
+	// 工作流整体超时，超时后正在执行的语句标记为timeout
+	if workflow.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		flowCtx, cancelTimeout = context.WithTimeoutCause(flowCtx, workflow.Timeout, &WorkflowTimeoutError{
+			FlowID:  workflow.FlowID,
+			Timeout: workflow.Timeout,
+		})
+		defer cancelTimeout()
+	}
+
+	// 3. 执行主函数语句
+	err := e.executeMainFunction(flowCtx, workflow)
+
+	// 4. 记录工作流最终状态，失败或超时时补偿已完成的步骤
+	// 补偿、结束回调和保存不受取消和整体超时影响，保留流程ID等上下文值
+	doneCtx := context.WithoutCancel(flowCtx)
+	workflow.Status = flowStatus(err)
+	failed := workflow.Status == StatusFailed || workflow.Status == StatusTimeout
+	if failed && len(workflow.Compensations) > 0 {
+		workflow.Status = e.compensate(doneCtx, workflow)
+		if e.OnWorkFlowExit != nil {
+			snapshot := workflow.Snapshot()
+			run.callback(func() { _ = e.OnWorkFlowExit(doneCtx, snapshot) })
+		}
+	}
+	finished := Event{Type: EventFlowFinished, Status: workflow.Status, Duration: time.Since(start)}
+	if err != nil {
+		finished.Error, finished.ErrorCode = failureMessage(err), errorCode(err)
+	}
+	e.emit(doneCtx, workflow, finished)
+	if e.Store != nil {
+		var saveErr error
+		run.callback(func() { saveErr = e.Store.Save(doneCtx, workflow) })
+		if saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return err
+}
+
+// register 登记流程，同一流程ID不能同时运行
+func (e *Executor) register(workflow *SimpleParseResult, run *flowRun, cancel context.CancelFunc) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.flows[workflow.FlowID]; exists {
+		return fmt.Errorf("流程 %s 已在运行中", workflow.FlowID)
+	}
+	if e.flows == nil {
+		e.flows = make(map[string]*flowEntry)
+	}
+	e.flows[workflow.FlowID] = &flowEntry{cancel: cancel, run: run, workflow: workflow}
+	return nil
+}
+
+// flowStatus 根据执行结果得到工作流状态
+func flowStatus(err error) StatementStatus {
+	switch {
+	case err == nil:
+		return StatusCompleted
+	case isTimeout(err) && !errors.As(err, new(*StepTimeoutError)):
+		return StatusTimeout
+	case strings.Contains(err.Error(), "被取消"):
+		return "cancelled"
+	default:
+		return StatusFailed
+	}
+}
 
-	// 4. 执行主函数语句
-	return e.executeMainFunction(flowCtx, workflow)
+// updateWorkflow 保存工作流状态并触发状态更新回调
+func (e *Executor) updateWorkflow(ctx context.Context, workflow *SimpleParseResult) error {
+	run := flowRunFromContext(ctx)
+	if e.Store != nil {
+		// 取消后仍需保存最终状态
+		var err error
+		run.callback(func() { err = e.Store.Save(context.WithoutCancel(ctx), workflow) })
+		if err != nil {
+			return fmt.Errorf("保存工作流状态失败: %w", err)
+		}
+	}
+	// 只在设置了回调时复制工作流，Get 在调用时才复制
+	if e.OnWorkFlowUpdate != nil {
+		snapshot := workflow.Snapshot()
+		var err error
+		run.callback(func() { err = e.OnWorkFlowUpdate(ctx, snapshot) })
+		return err
+	}
+	return nil
 }
 
-// Stop 停止指定流程
+// Stop 停止指定流程，流程在当前语句结束后退出，Start 返回前同一流程ID不能再次启动
 func (e *Executor) Stop(ctx context.Context, flowId string) error {
-	if cancel, exists := e.RunningFlows[flowId]; exists {
-		cancel()
-		delete(e.RunningFlows, flowId)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if entry, exists := e.flows[flowId]; exists {
+		entry.cancel()
 		return nil
 	}
 	return fmt.Errorf("流程 %s 未在运行中", flowId)
 }
 
-// executeMainFunction 执行主函数
+// executeMainFunction 执行主函数，恢复执行时跳过已完成的语句，需持有运行时锁
 func (e *Executor) executeMainFunction(ctx context.Context, workflow *SimpleParseResult) error {
 	if workflow.MainFunc == nil {
 		return fmt.Errorf("工作流没有主函数")
 	}
 
+	// 返回前等待所有异步步骤结束
+	run := flowRunFromContext(ctx)
+	defer run.waitAll()
+
 	// 遍历执行每个语句
-	for _, stmt := range workflow.MainFunc.Statements {
+	for i, stmt := range workflow.MainFunc.Statements {
+		// 恢复执行时跳过已完成的语句
+		if run.skip(stmt) {
+			continue
+		}
+
 		// 检查取消信号（服务侧取消，如服务器关机等）
 		select {
 		case <-ctx.Done():
-			// 标记当前语句为取消状态
-			stmt.Status = "cancelled"
+			// 标记当前语句为取消（整体超时为超时）状态
+			err := interruptedError(ctx, stmt, "工作流执行")
 			stmt.EndExecution()
 			// 触发兜底回调，更新节点状态
-			if e.OnWorkFlowUpdate != nil {
-				_ = e.OnWorkFlowUpdate(ctx, workflow)
-			}
-			return fmt.Errorf("工作流执行被取消: %v", ctx.Err())
+			_ = e.updateWorkflow(ctx, workflow)
+			return err
 		default:
 		}
 
+		// 等待语句依赖的异步步骤完成，异步步骤失败时终止工作流
+		if err := e.waitDependencies(ctx, stmt); err != nil {
+			return err
+		}
+
+		// 暂停、单步执行或断点时在语句执行前暂停
+		if err := e.checkpoint(ctx, stmt, workflow); err != nil {
+			return err
+		}
+
+		// 异步步骤启动后直接执行下一条语句
+		if isAsyncCall(ctx, stmt) {
+			e.startAsync(ctx, stmt, workflow)
+			continue
+		}
+
 		// 开始执行计时
 		stmt.StartExecution()
 
 		// 触发状态更新回调
-		if e.OnWorkFlowUpdate != nil {
-			if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-				stmt.EndExecution()
-				return err
-			}
+		if err := e.updateWorkflow(ctx, workflow); err != nil {
+			stmt.EndExecution()
+			return err
 		}
 
 		// 执行语句
 		if err := e.executeStatement(ctx, stmt, workflow); err != nil {
+			// return 提前结束工作流，后续语句标记为跳过
+			if errors.Is(err, errFlowReturn) {
+				stmt.EndExecution()
+				stmt.Status = "completed"
+				markSkipped(workflow.MainFunc.Statements[i+1:])
+				if err := run.waitAll(); err != nil {
+					return err
+				}
+				if err := e.updateWorkflow(ctx, workflow); err != nil {
+					return err
+				}
+				return nil
+			}
+			if errors.Is(err, errLoopBreak) || errors.Is(err, errLoopContinue) {
+				err = fmt.Errorf("第%d行: %v 只能在for循环中使用", stmt.LineNumber, err)
+			}
+
 			// 检查是否是取消错误
 			if strings.Contains(err.Error(), "被取消") || strings.Contains(err.Error(), "被主动取消") {
 				// 取消错误，状态已经在 executeStatement 中设置
 				return err
 			}
 
-			// 执行失败，设置状态为失败
+			// 执行失败，设置状态为失败，超时的步骤保留timeout状态
 			stmt.EndExecution()
-			stmt.Status = "failed"
+			if stmt.Status != StatusTimeout {
+				stmt.Status = "failed"
+			}
 
 			// 触发状态更新回调
-			if e.OnWorkFlowUpdate != nil {
-				if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-					return err
-				}
+			if err := e.updateWorkflow(ctx, workflow); err != nil {
+				return err
 			}
 
 			return err
@@ -160,26 +384,95 @@ func (e *Executor) executeMainFunction(ctx context.Context, workflow *SimplePars
 		stmt.EndExecution()
 	}
 
+	// 等待所有异步步骤完成
+	if err := run.waitAll(); err != nil {
+		return err
+	}
+
 	// 正常结束
 	if e.OnWorkFlowExit != nil {
-		return e.OnWorkFlowExit(ctx, workflow)
+		snapshot := workflow.Snapshot()
+		var err error
+		run.callback(func() { err = e.OnWorkFlowExit(ctx, snapshot) })
+		return err
 	}
 
 	return nil
 }
 
-// executeStatement 执行单个语句
+// StatementHandler 语句处理器，执行一条语句并设置语句状态，返回错误时语句和工作流失败
+//
+// 处理器在流程的执行锁内调用，可以直接读写 workflow 的变量和日志，返回前不要启动访问 workflow 的goroutine。
+type StatementHandler func(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error
+
+// RegisterType 注册语句类型的处理器
+//
+// 可以为内置引擎跳过的语句类型（如 assign、other）提供实现，也可以替换内置的处理，
+// 替换 function-call 时重试、超时和补偿等元数据需要由处理器自行实现。应在 Start 之前注册。
+func (e *Executor) RegisterType(stmtType string, handler StatementHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.handlers == nil {
+		e.handlers = make(map[string]StatementHandler)
+	}
+	e.handlers[stmtType] = handler
+}
+
+// executeStatement 执行单个语句并发布语句开始和结束事件
 func (e *Executor) executeStatement(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
+	e.emitStatement(ctx, workflow, EventStatementStarted, stmt, nil)
+	err := e.dispatchStatement(ctx, stmt, workflow)
+	// 调试中的步骤失败时暂停，可以修改变量后重新执行
+	for err != nil {
+		retry, pauseErr := e.retryFailed(ctx, stmt, workflow, err)
+		if pauseErr != nil {
+			err = pauseErr
+		}
+		if !retry {
+			break
+		}
+		err = e.dispatchStatement(ctx, stmt, workflow)
+	}
+	e.emitStatement(ctx, workflow, EventStatementFinished, stmt, err)
+	return err
+}
+
+// dispatchStatement 按语句类型执行语句，优先使用 RegisterType 注册的处理器
+func (e *Executor) dispatchStatement(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
+	e.mu.RLock()
+	handler, exists := e.handlers[stmt.Type]
+	e.mu.RUnlock()
+	if exists {
+		if err := handler(ctx, stmt, workflow); err != nil {
+			return err
+		}
+		if stmt.Status == StatusRunning {
+			stmt.Status = StatusCompleted
+		}
+		return e.updateWorkflow(ctx, workflow)
+	}
+
 	switch stmt.Type {
 	case "function-call":
 		return e.executeFunctionCall(ctx, stmt, workflow)
 	case "if":
 		return e.executeIfStatement(ctx, stmt, workflow)
-	// 注意：已移除打印语句支持，执行引擎会自动处理日志记录
+	case "print":
+		return e.executePrintStatement(ctx, stmt, workflow)
 	case "var":
 		return e.executeVarStatement(ctx, stmt, workflow)
 	case "return":
 		return e.executeReturnStatement(ctx, stmt, workflow)
+	case "for":
+		return e.executeForStatement(ctx, stmt, workflow)
+	case "wait":
+		return e.executeWaitStatement(ctx, stmt, workflow)
+	case "break":
+		stmt.Status = "completed"
+		return errLoopBreak
+	case "continue":
+		stmt.Status = "completed"
+		return errLoopContinue
 	default:
 		// 其他类型语句暂时跳过
 		return nil
@@ -189,56 +482,50 @@ func (e *Executor) executeStatement(ctx context.Context, stmt *SimpleStatement,
 // executeFunctionCall 执行函数调用
 func (e *Executor) executeFunctionCall(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
 	// 1. 找到对应的步骤定义
-	var step *SimpleStep
-	for _, s := range workflow.Steps {
-		if s.Name == stmt.Function {
-			step = s
-			break
-		}
+	step, err := stepDefinition(stmt, workflow)
+	if err != nil {
+		return err
 	}
 
-	if step == nil {
-		return fmt.Errorf("未找到步骤定义: %s", stmt.Function)
+	// 2. 构建输入参数，按参数声明的类型转换
+	realInput, err := stepInput(stmt, step, workflow, nil)
+	if err != nil {
+		return err
 	}
+	return e.callFunction(ctx, stmt, step, realInput, workflow)
+}
 
-	// 2. 获取元数据配置
+// stepDefinition 查找语句调用的步骤定义
+func stepDefinition(stmt *SimpleStatement, workflow *SimpleParseResult) (*SimpleStep, error) {
+	for _, step := range workflow.Steps {
+		if step.Name == stmt.Function {
+			return step, nil
+		}
+	}
+	return nil, fmt.Errorf("未找到步骤定义: %s", stmt.Function)
+}
+
+// callFunction 使用已求值的输入参数调用步骤，处理缓存、重试、超时和返回值
+func (e *Executor) callFunction(ctx context.Context, stmt *SimpleStatement, step *SimpleStep, realInput map[string]interface{}, workflow *SimpleParseResult) error {
+	// 3. 获取元数据配置，重试策略中语句元数据覆盖步骤定义元数据
 	timeout := stmt.GetTimeout()
-	retryCount := stmt.GetRetryCount()
+	policy := NewRetryPolicy(step.Metadata, stmt.Metadata)
+	retryCount := policy.MaxRetries
 	isDebug := stmt.IsDebug()
-
-	// 3. 构建输入参数
-	realInput := make(map[string]interface{})
-	for i, arg := range stmt.Args {
-		// 获取对应的输入参数定义
-		if i < len(step.InputParams) {
-			paramDef := step.InputParams[i]
-
-			// 处理不同类型的参数
-			if arg.IsInput {
-				// 输入参数：input["用户名"] -> 从InputVars中获取
-				if strings.HasPrefix(arg.Value, "input[") && strings.HasSuffix(arg.Value, "]") {
-					// 提取键名
-					key := strings.TrimSpace(arg.Value[6 : len(arg.Value)-1])
-					key = strings.Trim(key, "\"")
-					if value, exists := workflow.InputVars[key]; exists {
-						realInput[paramDef.Name] = value
-					} else {
-						realInput[paramDef.Name] = arg.Value
-					}
-				} else {
-					realInput[paramDef.Name] = arg.Value
-				}
-			} else if varInfo, exists := workflow.Variables[arg.Value]; exists {
-				// 从变量映射中获取实际值
-				realInput[paramDef.Name] = varInfo.Value
-			} else {
-				// 如果是字面量，直接使用
-				realInput[paramDef.Name] = arg.Value
+	errContinue := false
+	if step.Metadata != nil {
+		if val, exists := step.Metadata["err_continue"]; exists {
+			if boolVal, ok := val.(bool); ok {
+				errContinue = boolVal
 			}
 		}
 	}
 
-	// 4. 超时控制现在由业务回调自己处理，通过元数据传递超时信息
+	// 设置了 cache 元数据时第一次尝试先查找缓存，成功的结果保存到缓存
+	cacheKey, ttl := e.stepCache(stmt, step, realInput)
+	stmt.Cached = false
+
+	// 4. 超时控制：每次尝试单独计时，超时是否重试由重试策略决定
 
 	// 5. 执行重试逻辑
 	var lastErr error
@@ -247,20 +534,13 @@ func (e *Executor) executeFunctionCall(ctx context.Context, stmt *SimpleStatemen
 		select {
 		case <-ctx.Done():
 			// 触发兜底回调，更新节点状态为取消
-			stmt.Status = "cancelled"
+			err := interruptedError(ctx, stmt, "步骤执行")
 			stmt.EndExecution()
-			if e.OnWorkFlowUpdate != nil {
-				// 异步执行，不阻塞主流程
-				go func() {
-					_ = e.OnWorkFlowUpdate(ctx, workflow)
-				}()
-			}
-			return fmt.Errorf("步骤执行被取消: %v", ctx.Err())
+			_ = e.updateWorkflow(ctx, workflow)
+			return err
 		default:
 		}
 
-		// 超时控制现在由业务回调自己处理
-
 		// 构建执行选项
 		options := &ExecutorOptions{
 			Timeout:    timeout,
@@ -291,78 +571,48 @@ func (e *Executor) executeFunctionCall(ctx context.Context, stmt *SimpleStatemen
 				step.Name, attempt+1, retryCount+1, timeoutStr)
 		}
 
-		executorOut, err := e.OnFunctionCall(ctx, *step, executorIn)
-		if err != nil {
-			// 检查是否是上下文相关错误（取消或超时）
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
-				// 上下文被取消或超时
-				stmt.Status = "cancelled"
-				stmt.EndExecution()
-				if e.OnWorkFlowUpdate != nil {
-					_ = e.OnWorkFlowUpdate(ctx, workflow)
-				}
-				return fmt.Errorf("步骤执行被取消: %v", err)
-			}
+		// 子工作流每次尝试作为新的子流程执行
+		call := e.OnFunctionCall
+		if step.SubWorkflow != nil {
+			childID := generateFlowID()
+			workflow.ChildFlowIDs = append(workflow.ChildFlowIDs, childID)
+			call = e.subWorkflowCall(workflow.FlowID, childID)
+		}
 
-			// 业务逻辑错误，检查err_continue元数据
-			errContinue := false
-			if step.Metadata != nil {
-				if val, exists := step.Metadata["err_continue"]; exists {
-					if boolVal, ok := val.(bool); ok {
-						errContinue = boolVal
-					}
-				}
+		// 调用业务回调期间释放运行时锁，异步步骤的回调可以并发执行
+		var executorOut *ExecutorOut
+		var err, cacheErr error
+		stepCopy := *step
+		flowRunFromContext(ctx).unlocked(func() {
+			if cacheKey != "" && attempt == 0 {
+				executorOut, cacheErr = e.Cache.Get(ctx, cacheKey)
 			}
-
-			lastErr = err
-
-			if errContinue {
-				// err_continue: true - 记录错误但继续执行
-				stmt.Status = "failed_continue"
-
-				// 记录错误日志
-				log := &StepLog{
-					Timestamp: time.Now(),
-					Level:     "error",
-					Message:   fmt.Sprintf("步骤执行失败但继续执行: %v", err),
-					Source:    step.Name + ".Error",
-				}
-				step.Logs = append(step.Logs, log)
-
-				// 触发状态更新回调
-				if e.OnWorkFlowUpdate != nil {
-					if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-						return err
-					}
-				}
-
-				// 继续执行，不返回错误
-				return nil
+			if executorOut == nil {
+				executorOut, err = e.callStep(ctx, call, stmt, stepCopy, executorIn, timeout, attempt)
 			} else {
-				// err_continue: false 或不设置 - 执行失败时终止工作流
-				if attempt < retryCount {
-					// 还有重试机会，等待一段时间后重试
-					time.Sleep(time.Duration(attempt+1) * time.Second)
-					continue
-				}
-				return err
+				stmt.Cached = true
 			}
+		})
+		if cacheErr != nil {
+			e.addStepLog(ctx, step, &StepLog{Timestamp: time.Now(), Level: "warn", Message: fmt.Sprintf("读取缓存失败: %v", cacheErr), Source: step.Name + ".Cache"})
 		}
 
-		// 业务回调执行后，再次检查原始上下文是否被取消
-		select {
-		case <-ctx.Done():
-			stmt.Status = "cancelled"
+		// 工作流被取消或整体超时（包括业务回调执行后才被取消的情况）
+		if ctx.Err() != nil {
+			err := interruptedError(ctx, stmt, "步骤执行")
 			stmt.EndExecution()
-			if e.OnWorkFlowUpdate != nil {
-				_ = e.OnWorkFlowUpdate(ctx, workflow)
-			}
-			return fmt.Errorf("步骤执行被取消: %v", ctx.Err())
-		default:
+			_ = e.updateWorkflow(ctx, workflow)
+			return err
+		}
+
+		// 返回值的类型与步骤定义不一致时按失败处理
+		var outputs map[string]interface{}
+		if err == nil && executorOut != nil && executorOut.Success {
+			outputs, err = stepOutput(stmt, step, executorOut.WantOutput)
 		}
 
-		// 6. 处理输出参数
-		if executorOut.Success {
+		if err == nil && executorOut != nil && executorOut.Success {
+			// 6. 处理输出参数
 			// 将输出参数存储到变量映射中
 			// 需要将形参名映射到实例名
 			for i, returnVar := range stmt.Returns {
@@ -370,91 +620,176 @@ func (e *Executor) executeFunctionCall(ctx context.Context, stmt *SimpleStatemen
 				if i < len(step.OutputParams) {
 					paramDef := step.OutputParams[i]
 					// 从WantOutput中获取形参名对应的值
-					if value, exists := executorOut.WantOutput[paramDef.Name]; exists {
+					if value, exists := outputs[paramDef.Name]; exists {
 						// 使用实例名作为变量名，而不是形参名
-						workflow.Variables[returnVar.Value] = VariableInfo{
+						e.setVariable(ctx, workflow, VariableInfo{
 							Name:    returnVar.Value, // 实例名：工号、用户名、step1Err
 							Type:    paramDef.Type,   // 从步骤定义获取类型
 							Value:   value,           // 实际值
 							Source:  stmt.Function,
 							LineNum: stmt.LineNumber,
 							IsInput: false,
+						})
+						// err 在解析时被重命名为 stepXErr，执行时让别名指向最近一次调用的结果
+						if returnVar.Value == stmt.Function+"Err" {
+							if alias, ok := workflow.GetVariable("err"); ok {
+								alias.Name = returnVar.Value
+								workflow.SetVariable("err", alias)
+							}
 						}
 					}
 				}
 			}
 
+			if stmt.Cached {
+				e.addStepLog(ctx, step, &StepLog{Timestamp: time.Now(), Level: "info", Message: "completed (cached)", Source: step.Name + ".Cache"})
+			} else {
+				// 定义了补偿步骤时记录补偿，工作流失败后倒序执行；使用缓存的结果没有执行步骤，不需要补偿
+				e.recordCompensation(workflow, stmt, step, realInput, outputs)
+				if cacheKey != "" {
+					if err := e.Cache.Set(ctx, cacheKey, executorOut, ttl); err != nil {
+						e.addStepLog(ctx, step, &StepLog{Timestamp: time.Now(), Level: "warn", Message: fmt.Sprintf("保存缓存失败: %v", err), Source: step.Name + ".Cache"})
+					}
+				}
+			}
+
+			// 经过重试才成功时记录成功的尝试
+			if attempt > 0 {
+				e.addStepLog(ctx, step, &StepLog{
+					Timestamp: time.Now(),
+					Level:     "info",
+					Message:   fmt.Sprintf("第%d次尝试成功", attempt+1),
+					Source:    step.Name + ".Retry",
+					Attempt:   attempt + 1,
+				})
+			}
+
 			// 执行成功，更新状态为完成
 			stmt.Status = "completed"
 
 			// 触发状态更新回调
-			if e.OnWorkFlowUpdate != nil {
-				if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-					return err
-				}
+			if err := e.updateWorkflow(ctx, workflow); err != nil {
+				return err
 			}
 
 			return nil
-		} else {
-			// 执行失败，检查err_continue元数据
-			errContinue := false
-			if step.Metadata != nil {
-				if val, exists := step.Metadata["err_continue"]; exists {
-					if boolVal, ok := val.(bool); ok {
-						errContinue = boolVal
-					}
-				}
-			}
-
-			lastErr = fmt.Errorf("步骤执行失败: %s", executorOut.Error)
+		}
 
-			if errContinue {
-				// err_continue: true - 记录错误但继续执行
-				stmt.Status = "failed_continue"
+		if err == nil {
+			// 业务回调返回执行失败
+			message := ""
+			code := ""
+			if executorOut != nil {
+				message = executorOut.Error
+				code = executorOut.ErrorCode
+			}
+			err = &stepError{code: code, message: message}
+		} else if !isTimeout(err) && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+			// 业务回调自行返回的上下文错误视为取消
+			stmt.Status = "cancelled"
+			stmt.EndExecution()
+			_ = e.updateWorkflow(ctx, workflow)
+			return fmt.Errorf("步骤执行被取消: %v", err)
+		}
+		lastErr = err
+
+		// 配置了重试时记录每次失败的尝试，执行结束后可查看完整的尝试历史
+		retry := attempt < retryCount && policy.ShouldRetry(err)
+		failed := Event{
+			Type:          EventStepAttemptFailed,
+			Line:          stmt.LineNumber,
+			StatementType: stmt.Type,
+			Content:       firstLine(stmt.Content),
+			Step:          step.Name,
+			Status:        StatusFailed,
+			Error:         failureMessage(err),
+			ErrorCode:     errorCode(err),
+			Attempt:       attempt + 1,
+			Retry:         retry,
+		}
+		if isTimeout(err) {
+			failed.Status = StatusTimeout
+		}
+		e.emit(ctx, workflow, failed)
+		if retryCount > 0 {
+			level := "warn"
+			if !retry {
+				level = "error"
+			}
+			e.addStepLog(ctx, step, &StepLog{
+				Timestamp: time.Now(),
+				Level:     level,
+				Message:   fmt.Sprintf("第%d次尝试失败: %s", attempt+1, failureMessage(err)),
+				Source:    step.Name + ".Retry",
+				Attempt:   attempt + 1,
+				Error:     err.Error(),
+			})
+		}
+		if !retry {
+			break
+		}
 
-				// 记录错误日志
-				log := &StepLog{
-					Timestamp: time.Now(),
-					Level:     "error",
-					Message:   fmt.Sprintf("步骤执行失败但继续执行: %s", executorOut.Error),
-					Source:    step.Name + ".Error",
-				}
-				step.Logs = append(step.Logs, log)
+		// 按重试策略等待后重试，等待期间工作流被取消则立即结束
+		stmt.IncrementRetry()
+		if err := e.updateWorkflow(ctx, workflow); err != nil {
+			return err
+		}
+		var sleepErr error
+		flowRunFromContext(ctx).unlocked(func() {
+			sleepErr = sleepContext(ctx, policy.Delay(attempt+1))
+		})
+		if sleepErr != nil {
+			err := interruptedError(ctx, stmt, "步骤执行")
+			stmt.EndExecution()
+			_ = e.updateWorkflow(ctx, workflow)
+			return err
+		}
+	}
 
-				// 触发状态更新回调
-				if e.OnWorkFlowUpdate != nil {
-					if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-						return err
-					}
-				}
+	// 7. 所有重试都失败了，超时的步骤标记为timeout
+	switch {
+	case isTimeout(lastErr):
+		stmt.Status = StatusTimeout
+	case errContinue:
+		stmt.Status = "failed_continue"
+	default:
+		stmt.Status = "failed"
+	}
 
-				// 继续执行，不返回错误
-				return nil
-			} else {
-				// err_continue: false 或不设置 - 执行失败时终止工作流
-				if attempt < retryCount {
-					// 还有重试机会，等待一段时间后重试
-					time.Sleep(time.Duration(attempt+1) * time.Second)
-					continue
-				}
-			}
+	// err_continue: true - 记录错误但继续执行
+	if errContinue {
+		action := "失败"
+		if isTimeout(lastErr) {
+			action = "超时"
 		}
+		e.addStepLog(ctx, step, &StepLog{
+			Timestamp: time.Now(),
+			Level:     "error",
+			Message:   fmt.Sprintf("步骤执行%s但继续执行: %s", action, failureMessage(lastErr)),
+			Source:    step.Name + ".Error",
+		})
 	}
 
-	// 所有重试都失败了
-	stmt.Status = "failed"
-
 	// 触发状态更新回调
-	if e.OnWorkFlowUpdate != nil {
-		if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-			return err
-		}
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return err
 	}
 
+	if errContinue {
+		return nil
+	}
 	return lastErr
 }
 
-// executeIfStatement 执行if语句
+// addStepLog 记录步骤日志，循环内同时记录到当前迭代
+func (e *Executor) addStepLog(ctx context.Context, step *SimpleStep, log *StepLog) {
+	step.Logs = append(step.Logs, log)
+	if iteration := iterationFromContext(ctx); iteration != nil {
+		iteration.Logs = append(iteration.Logs, log)
+	}
+}
+
+// executeIfStatement 执行if语句，在 if / else if / else 中只执行第一个条件为真的分支，其余分支标记为跳过
 func (e *Executor) executeIfStatement(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
 	// 1. 解析条件表达式
 	condition := stmt.Condition
@@ -463,39 +798,194 @@ func (e *Executor) executeIfStatement(ctx context.Context, stmt *SimpleStatement
 	}
 
 	// 2. 评估条件
-	result, err := e.evaluateCondition(condition, workflow.Variables)
+	result, err := e.evaluateCondition(stmt, workflow)
 	if err != nil {
-		return fmt.Errorf("条件评估失败: %v", err)
+		return fmt.Errorf("条件评估失败: %w", err)
+	}
+
+	// 3. 选择要执行的分支
+	var taken *SimpleStatement
+	if !result {
+		for _, branch := range stmt.Branches {
+			// else 分支没有条件
+			if branch.Condition != "" {
+				ok, err := e.evaluateCondition(branch, workflow)
+				if err != nil {
+					return fmt.Errorf("条件评估失败: %w", err)
+				}
+				if !ok {
+					continue
+				}
+			}
+			taken = branch
+			break
+		}
+	}
+
+	// 4. 未选中的分支标记为跳过
+	if !result {
+		markSkipped(stmt.Children)
+	}
+	for _, branch := range stmt.Branches {
+		if branch != taken {
+			markSkipped([]*SimpleStatement{branch})
+		}
 	}
 
-	// 3. 根据条件结果执行子语句
+	// 5. 执行选中分支的子语句，return/break/continue 继续向上传递
+	var flow error
 	if result {
-		// 条件为真，执行子语句
-		for _, child := range stmt.Children {
-			// 为子语句开始计时
-			child.StartExecution()
-			if err := e.executeStatement(ctx, child, workflow); err != nil {
-				child.EndExecution()
+		if err := e.executeChildren(ctx, stmt.Children, workflow); err != nil {
+			if !isControlFlow(err) {
 				return err
 			}
-			child.EndExecution()
+			flow = err
+		}
+	} else if taken != nil {
+		taken.StartExecution()
+		err := e.executeChildren(ctx, taken.Children, workflow)
+		taken.EndExecution()
+		if err != nil && !isControlFlow(err) {
+			taken.Status = StatusFailed
+			return err
 		}
+		taken.Status = StatusCompleted
+		flow = err
 	}
 
-	// 4. 更新语句状态为完成
+	// 6. 更新语句状态为完成
 	stmt.Status = "completed"
 
-	// 5. 触发状态更新回调
-	if e.OnWorkFlowUpdate != nil {
-		if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
+	// 7. 触发状态更新回调
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return err
+	}
+
+	return flow
+}
+
+// executeChildren 依次执行嵌套语句，遇到 return/break/continue 时后续语句标记为跳过
+func (e *Executor) executeChildren(ctx context.Context, children []*SimpleStatement, workflow *SimpleParseResult) error {
+	for i, child := range children {
+		// 恢复执行时跳过已完成的语句
+		if flowRunFromContext(ctx).skip(child) {
+			continue
+		}
+
+		// 等待依赖的异步步骤，异步步骤启动后直接执行下一条语句
+		if err := e.waitDependencies(ctx, child); err != nil {
+			return err
+		}
+		if err := e.checkpoint(ctx, child, workflow); err != nil {
+			return err
+		}
+		if isAsyncCall(ctx, child) {
+			e.startAsync(ctx, child, workflow)
+			continue
+		}
+
+		// 为子语句开始计时
+		child.StartExecution()
+		if err := e.executeStatement(ctx, child, workflow); err != nil {
+			child.EndExecution()
+			if isControlFlow(err) {
+				child.Status = StatusCompleted
+				markSkipped(children[i+1:])
+				return err
+			}
+			if child.Status == StatusRunning {
+				child.Status = StatusFailed
+			}
 			return err
 		}
+		child.EndExecution()
+	}
+	return nil
+}
+
+// markSkipped 将语句及其嵌套语句、分支标记为跳过
+func markSkipped(statements []*SimpleStatement) {
+	for _, stmt := range statements {
+		stmt.Status = StatusSkipped
+		markSkipped(stmt.Children)
+		markSkipped(stmt.Branches)
+	}
+}
+
+// executePrintStatement 执行打印语句，stepX.Printf 记录到步骤日志，sys/fmt 打印记录到全局日志
+func (e *Executor) executePrintStatement(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
+	// 1. 解析接收者、方法和格式化后的内容
+	receiver, method, message := e.formatPrint(stmt, workflow)
+	source := receiver + "." + method
+
+	// 2. 记录日志
+	var step *SimpleStep
+	for _, s := range workflow.Steps {
+		if s.Name == receiver {
+			step = s
+			break
+		}
+	}
+	if step != nil {
+		step.AddLog("info", message, source)
+	} else {
+		workflow.AddGlobalLog("info", message, source)
+	}
+	// 循环内的打印同时记录到当前迭代
+	if iteration := iterationFromContext(ctx); iteration != nil {
+		iteration.Logs = append(iteration.Logs, &StepLog{
+			Timestamp: time.Now(),
+			Level:     "info",
+			Message:   message,
+			Source:    source,
+		})
+	}
+
+	// 3. 更新语句状态为完成
+	stmt.Status = "completed"
+
+	// 4. 触发状态更新回调
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// 注意：已移除 executePrintStatement 函数，执行引擎会自动处理日志记录
+// formatPrint 按Go的Printf/Println语义格式化打印语句，参数无法求值时保留原始内容
+func (e *Executor) formatPrint(stmt *SimpleStatement, workflow *SimpleParseResult) (string, string, string) {
+	receiver, method := "sys", "Println"
+	if matches := printStatementRegex.FindStringSubmatch(stmt.Content); len(matches) == 3 {
+		receiver, method = matches[1], matches[2]
+	}
+
+	call, ok := parseCallExpr(stmt.Content)
+	if !ok {
+		return receiver, method, stmt.Content
+	}
+
+	args := make([]interface{}, 0, len(call.Args))
+	ev := &exprEvaluator{src: stmt.Content, line: stmt.LineNumber, env: workflowEnv{workflow: workflow}}
+	for _, arg := range call.Args {
+		value, err := ev.eval(arg)
+		if err != nil {
+			return receiver, method, stmt.Content
+		}
+		args = append(args, value)
+	}
+
+	if method == "Printf" {
+		if len(args) == 0 {
+			return receiver, method, stmt.Content
+		}
+		format, ok := args[0].(string)
+		if !ok {
+			return receiver, method, stmt.Content
+		}
+		return receiver, method, strings.TrimSuffix(fmt.Sprintf(format, args[1:]...), "\n")
+	}
+	return receiver, method, strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
 
 // executeVarStatement 执行var语句
 func (e *Executor) executeVarStatement(ctx context.Context, stmt *SimpleStatement, workflow *SimpleParseResult) error {
@@ -511,33 +1001,41 @@ func (e *Executor) executeVarStatement(ctx context.Context, stmt *SimpleStatemen
 		return fmt.Errorf("变量赋值解析失败: %v", err)
 	}
 
-	// 3. 处理模板变量替换
-	processedValue, err := e.processTemplate(varValue, workflow.Variables)
-	if err != nil {
-		return fmt.Errorf("模板处理失败: %v", err)
+	// 3. 计算值：字面量按值推断类型，字符串处理模板变量替换，其他按表达式求值
+	value, varType, isLiteral := parseLiteral(varValue)
+	if !isLiteral {
+		value, err = EvaluateExpression(varValue, stmt.LineNumber, workflow)
+		if err != nil {
+			return err
+		}
+		varType = valueType(value)
+	}
+	if text, ok := value.(string); ok {
+		value, err = e.processTemplate(text, workflow.VariablesSnapshot())
+		if err != nil {
+			return fmt.Errorf("模板处理失败: %v", err)
+		}
 	}
 
 	// 4. 创建变量信息
 	varInfo := VariableInfo{
 		Name:    varName,
-		Type:    "string", // 默认为string类型，后续可以改进类型推断
-		Value:   processedValue,
+		Type:    varType,
+		Value:   value,
 		Source:  "assignment",
 		LineNum: stmt.LineNumber,
 		IsInput: false,
 	}
 
 	// 5. 存储到变量映射
-	workflow.Variables[varName] = varInfo
+	e.setVariable(ctx, workflow, varInfo)
 
 	// 6. 更新语句状态为完成
 	stmt.Status = "completed"
 
 	// 7. 触发状态更新回调
-	if e.OnWorkFlowUpdate != nil {
-		if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-			return err
-		}
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return err
 	}
 
 	return nil
@@ -549,62 +1047,27 @@ func (e *Executor) executeReturnStatement(ctx context.Context, stmt *SimpleState
 	stmt.Status = "completed"
 
 	// 2. 触发状态更新回调
-	if e.OnWorkFlowUpdate != nil {
-		if err := e.OnWorkFlowUpdate(ctx, workflow); err != nil {
-			return err
-		}
+	if err := e.updateWorkflow(ctx, workflow); err != nil {
+		return err
 	}
 
 	// 3. 触发return回调
 	if e.OnWorkFlowReturn != nil {
-		return e.OnWorkFlowReturn(ctx, workflow)
-	}
-	return nil
-}
-
-// evaluateCondition 评估条件表达式
-func (e *Executor) evaluateCondition(condition string, variables map[string]VariableInfo) (bool, error) {
-	// 简单的条件评估，支持常见的比较操作
-	// 例如: "step1Err != nil", "验证通过 == true", "count > 0"
-
-	// 处理 != nil 条件
-	if strings.Contains(condition, "!= nil") {
-		varName := strings.TrimSpace(strings.Split(condition, "!=")[0])
-		if varInfo, exists := variables[varName]; exists {
-			return varInfo.Value == nil, nil
-		}
-		return false, nil
-	}
-
-	// 处理 == true 条件
-	if strings.Contains(condition, "== true") {
-		varName := strings.TrimSpace(strings.Split(condition, "==")[0])
-		if varInfo, exists := variables[varName]; exists {
-			return varInfo.Value == true, nil
-		}
-		return false, nil
-	}
-
-	// 处理 == false 条件
-	if strings.Contains(condition, "== false") {
-		varName := strings.TrimSpace(strings.Split(condition, "==")[0])
-		if varInfo, exists := variables[varName]; exists {
-			return varInfo.Value == false, nil
+		snapshot := workflow.Snapshot()
+		var err error
+		flowRunFromContext(ctx).callback(func() { err = e.OnWorkFlowReturn(ctx, snapshot) })
+		if err != nil {
+			return err
 		}
-		return true, nil
 	}
 
-	// 处理 != true 条件
-	if strings.Contains(condition, "!= true") {
-		varName := strings.TrimSpace(strings.Split(condition, "!=")[0])
-		if varInfo, exists := variables[varName]; exists {
-			return varInfo.Value != true, nil
-		}
-		return true, nil
-	}
+	// 4. 通知上层停止执行后续语句
+	return errFlowReturn
+}
 
-	// 默认返回false
-	return false, nil
+// evaluateCondition 评估if语句的条件表达式，变量未定义或类型不匹配时返回带行号的 *ExprError
+func (e *Executor) evaluateCondition(stmt *SimpleStatement, workflow *SimpleParseResult) (bool, error) {
+	return EvaluateCondition(stmt.Condition, stmt.LineNumber, workflow)
 }
 
 // processTemplate 处理模板变量替换
@@ -633,31 +1096,41 @@ func (e *Executor) processTemplate(content string, variables map[string]Variable
 // parseVarAssignment 解析变量赋值语句
 func (e *Executor) parseVarAssignment(content string) (string, string, error) {
 	// 匹配 变量名 := "值" 格式，支持中文变量名
-	re := regexp.MustCompile(`([\w\p{Han}]+)\s*:=\s*(.+)`)
-	matches := re.FindStringSubmatch(content)
+	matches := varAssignmentRegex.FindStringSubmatch(content)
 	if len(matches) < 3 {
 		return "", "", fmt.Errorf("无法解析变量赋值语句: %s", content)
 	}
 
-	varName := strings.TrimSpace(matches[1])
-	varValue := strings.TrimSpace(matches[2])
-
-	// 去掉引号
-	if strings.HasPrefix(varValue, `"`) && strings.HasSuffix(varValue, `"`) {
-		varValue = varValue[1 : len(varValue)-1]
-	}
-
-	return varName, varValue, nil
+	// 值保留引号，由 parseLiteral 解析
+	return strings.TrimSpace(matches[1]), strings.TrimSpace(matches[2]), nil
 }
 
 // Get 获取指定工作流的全部信息
+//
+// 运行中的流程返回调用时的快照，可以安全读取，修改返回值不影响正在执行的流程；
+// 快照在业务回调、等待或状态回调期间复制，调用时可能需要等待当前语句执行到这些位置。
+// 已结束的流程不再保留在执行器中，设置了 Store 时从 Store 加载。
 func (e *Executor) Get(flowID string) (*SimpleParseResult, error) {
-	// 检查工作流是否存在
-	workflow, exists := e.FlowMap[flowID]
+	e.mu.RLock()
+	entry, exists := e.flows[flowID]
+	e.mu.RUnlock()
 	if !exists {
+		if e.Store != nil {
+			return e.Store.Load(context.Background(), flowID)
+		}
 		return nil, fmt.Errorf("工作流不存在: %s", flowID)
 	}
+	return entry.run.snapshot(entry.workflow), nil
+}
 
-	// 直接返回工作流信息
-	return workflow, nil
+// Running 返回正在运行的流程ID
+func (e *Executor) Running() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var flowIDs []string
+	for flowID := range e.flows {
+		flowIDs = append(flowIDs, flowID)
+	}
+	sort.Strings(flowIDs)
+	return flowIDs
 }
@@ -0,0 +1,11 @@
+//go:build linux
+
+package osx
+
+import "golang.org/x/sys/unix"
+
+// SyncFS Linux 上的 sync(2) 没有返回值，总是返回 nil
+func SyncFS() error {
+	unix.Sync()
+	return nil
+}
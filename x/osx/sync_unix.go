@@ -1,4 +1,4 @@
-//go:build linux || darwin || freebsd || openbsd || netbsd
+//go:build darwin || freebsd || openbsd || netbsd
 
 package osx
 